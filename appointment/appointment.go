@@ -4,25 +4,39 @@ package appointment
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"reflect"
+	"sync"
 	"time"
 
 	bst "github.com/shiweii/binarysearchtree"
+	"github.com/shiweii/config"
 	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/integrity"
 	"github.com/shiweii/logger"
 	"github.com/shiweii/user"
 	util "github.com/shiweii/utility"
 )
 
 // Appointment struct stores application data.
+// SeriesID links appointments created as part of the same recurring series.
+// Duration is the number of consecutive sessions taken starting from Session.
+// Clinic and Chair are the codes of the branch and chair reserved for the appointment.
+// NoShow is set when the patient did not attend a past appointment.
 type Appointment struct {
-	ID      int         `json:"id"`
-	Dentist interface{} `json:"dentist"`
-	Patient interface{} `json:"patient"`
-	Date    string      `json:"date"`
-	Session int         `json:"session"`
+	ID       int         `json:"id"`
+	Dentist  interface{} `json:"dentist"`
+	Patient  interface{} `json:"patient"`
+	Date     string      `json:"date"`
+	Session  int         `json:"session"`
+	SeriesID int         `json:"seriesId,omitempty"`
+	Type     string      `json:"type,omitempty"`
+	Duration int         `json:"duration,omitempty"`
+	Clinic   string      `json:"clinic,omitempty"`
+	Chair    string      `json:"chair,omitempty"`
+	NoShow   bool        `json:"noShow,omitempty"`
 }
 
 // AppSession struct stores application session data.
@@ -38,6 +52,10 @@ type BinarySearchTree struct {
 	*bst.BinarySearchTree
 }
 
+// bookingMutex serialises the collision check and insertion of appointments
+// so that the same slot cannot be booked twice by concurrent requests.
+var bookingMutex sync.Mutex
+
 // New will return a newly created instance of an appointment.
 func New(id int, patient, dentist interface{}, date string, session int) *Appointment {
 	return &Appointment{
@@ -49,10 +67,55 @@ func New(id int, patient, dentist interface{}, date string, session int) *Appoin
 	}
 }
 
+// SetType sets the type and duration of the appointment.
+func (a *Appointment) SetType(appointmentType *AppointmentType) {
+	if appointmentType == nil {
+		a.Type, a.Duration = "", 0
+		return
+	}
+	a.Type = appointmentType.Code
+	a.Duration = appointmentType.GetDuration()
+}
+
+// GetDuration returns the number of sessions taken by the appointment, appointments without duration takes 1 session.
+func (a *Appointment) GetDuration() int {
+	if a.Duration < 1 {
+		return 1
+	}
+	return a.Duration
+}
+
+// GetLastSession returns the last session taken by the appointment.
+func (a *Appointment) GetLastSession() int {
+	return a.Session + a.GetDuration() - 1
+}
+
+// Covers checks if the appointment takes a session.
+func (a *Appointment) Covers(session int) bool {
+	return session >= a.Session && session <= a.GetLastSession()
+}
+
+// Overlaps checks if the appointment takes any session within a number of sessions starting from session.
+func (a *Appointment) Overlaps(session, duration int) bool {
+	return session <= a.GetLastSession() && session+duration-1 >= a.Session
+}
+
+// GetJSONData returns a copy of the appointment with dentist and patient replaced by username for JSON storage.
+func (a *Appointment) GetJSONData() *Appointment {
+	appointment := *a
+	if dentist, ok := a.Dentist.(*user.User); ok {
+		appointment.Dentist = dentist.Username
+	}
+	if patient, ok := a.Patient.(*user.User); ok {
+		appointment.Patient = patient.Username
+	}
+	return &appointment
+}
+
 // GetAppointmentData will open, read and unmarshal application data from JSON file.
 func GetAppointmentData() []*Appointment {
 	var appointments []*Appointment
-	JSONData, _ := ioutil.ReadFile(util.GetEnvVar("APPOINTMENT_DATA"))
+	JSONData, _ := ioutil.ReadFile(config.Get().Data.Appointments)
 	err := json.Unmarshal(JSONData, &appointments)
 	if err != nil {
 		fmt.Println(err)
@@ -66,7 +129,7 @@ func AddAppointmentData(a *Appointment) {
 	appointments = GetAppointmentData()
 	appointments = append(appointments, a)
 	JSONData, _ := json.MarshalIndent(appointments, "", " ")
-	err := ioutil.WriteFile(util.GetEnvVar("APPOINTMENT_DATA"), JSONData, 0644)
+	err := integrity.WriteFile(config.Get().Data.Appointments, JSONData, 0644)
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -81,7 +144,7 @@ func UpdateAppointmentData(oldAppointment *Appointment, editedAppointment *Appoi
 		}
 	}
 	JSONData, _ := json.MarshalIndent(appointments, "", " ")
-	err := ioutil.WriteFile(util.GetEnvVar("APPOINTMENT_DATA"), JSONData, 0644)
+	err := integrity.WriteFile(config.Get().Data.Appointments, JSONData, 0644)
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -90,45 +153,206 @@ func UpdateAppointmentData(oldAppointment *Appointment, editedAppointment *Appoi
 // DeleteAppointmentData will open, marshal and delete application data using application id from JSON file.
 func DeleteAppointmentData(id int) {
 	var appointments []*Appointment
-	var idx int
+	idx := -1
 	appointments = GetAppointmentData()
 	for k, v := range appointments {
 		if v.ID == id {
 			idx = k
 		}
 	}
+	if idx < 0 {
+		return
+	}
 	appointments = append(appointments[:idx], appointments[idx+1:]...)
 	JSONData, _ := json.MarshalIndent(appointments, "", " ")
-	err := ioutil.WriteFile(util.GetEnvVar("APPOINTMENT_DATA"), JSONData, 0644)
+	err := integrity.WriteFile(config.Get().Data.Appointments, JSONData, 0644)
 	if err != nil {
 		fmt.Println(err)
 	}
 }
 
 // CreateNewAppointment run as Go routine to block users from booking the same dentist on the same date and session.
+// Booking request must not break any rule of the engine, rules are evaluated in the same step as the collision check.
+// Clinic must be opened and dentist must also be working on the date and session according to the rota.
 // Application Data will then be inserted into the binary search tree then append into JSON for persistence storage.
-func CreateNewAppointment(id int, date string, session int, dentist *user.User, patient *user.User, appointmentTree *BinarySearchTree, chn chan bool) {
-	var sessionBooked = false
-	// Check if appointment is booked
-	appointments := (*appointmentTree).GetAppointmentByDate(date, "dentist", dentist)
-	for _, v := range appointments {
-		if v.Session == session {
-			sessionBooked = true
-			chn <- false
+// All sessions covered by the appointment type are reserved, a nil type takes a single session.
+// A chair is reserved at the branch, or at any branch the dentist is working at when branch is empty.
+// An EventBooked event is published when the appointment is created.
+func CreateNewAppointment(id int, req *BookingRequest, branch string, engine *RulesEngine, appointmentTree *BinarySearchTree, rota *Rota, calendar *Calendar, clinics Clinics, chn chan bool) {
+	created := false
+	appointment := New(id, req.Patient, req.Dentist, req.Date, req.Session.Num)
+	appointment.SetType(req.Type)
+	appointment.Clinic = branch
+	bookingMutex.Lock()
+	if len(engine.evaluate(req, appointmentTree)) == 0 {
+		created = appointmentTree.createAppointment(appointment, rota, calendar, clinics)
+	}
+	bookingMutex.Unlock()
+	if created {
+		Publish(Event{Type: EventBooked, Appointment: appointment})
+	}
+	chn <- created
+}
+
+// createAppointment inserts an appointment if all sessions are open and a chair is available at the appointment's branch,
+// the branch and chair reserved are set on the appointment. Caller must hold bookingMutex.
+func (appBst *BinarySearchTree) createAppointment(appointment *Appointment, rota *Rota, calendar *Calendar, clinics Clinics) bool {
+	dentist := appointment.Dentist.(*user.User)
+	if appBst.getSlotConflict(appointment.Date, appointment.Session, appointment.GetDuration(), dentist, "", rota, calendar, nil, nil) != "" {
+		return false
+	}
+	if clinics != nil {
+		clinic, chair, _ := appBst.getResource(appointment.Date, appointment.Session, appointment.GetDuration(), dentist, appointment.Clinic, clinics, nil)
+		if chair == nil {
+			return false
+		}
+		appointment.Clinic, appointment.Chair = clinic.Code, chair.Code
+	}
+	appBst.addAppointment(appointment)
+	return true
+}
+
+// GetSlotConflict checks if clinic is opened, dentist is working, none of the sessions
+// starting from session are booked and a chair is available at the branch (any branch if empty),
+// returns the reason the slot cannot be booked or empty if slot is open.
+// Appointments in ignore are not counted as booked. Chairs are not checked when clinics is nil.
+func (appBst *BinarySearchTree) GetSlotConflict(date string, session, duration int, dentist *user.User, branch string, rota *Rota, calendar *Calendar, clinics Clinics, ignore []*Appointment) string {
+	bookingMutex.Lock()
+	defer bookingMutex.Unlock()
+	return appBst.getSlotConflict(date, session, duration, dentist, branch, rota, calendar, clinics, ignore)
+}
+
+// IsBooked checks if any appointment of the dentist covers the session on date.
+// Appointments are read while holding bookingMutex so that it is safe to call from background routines.
+func (appBst *BinarySearchTree) IsBooked(date string, session int, dentist *user.User) bool {
+	bookingMutex.Lock()
+	defer bookingMutex.Unlock()
+	for _, v := range appBst.GetAppointmentByDate(date, "dentist", dentist) {
+		if v.Covers(session) {
+			return true
+		}
+	}
+	return false
+}
+
+// getSlotConflict is the unlocked version of GetSlotConflict, caller must hold bookingMutex.
+func (appBst *BinarySearchTree) getSlotConflict(date string, session, duration int, dentist *user.User, branch string, rota *Rota, calendar *Calendar, clinics Clinics, ignore []*Appointment) string {
+	// Check if clinic is opened and dentist is working
+	appointmentDate, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "Appointment date is invalid."
+	}
+	if holiday := calendar.Get(date); holiday != nil {
+		return fmt.Sprintf("Clinic is closed (%v).", holiday.Name)
+	}
+	for i := session; i < session+duration; i++ {
+		if !rota.IsWorking(dentist.Username, appointmentDate, i) {
+			return "Dentist is not available."
+		}
+	}
+	// Check if any of the sessions are booked
+	for _, v := range appBst.GetAppointmentByDate(date, "dentist", dentist) {
+		if v.Overlaps(session, duration) && !isIgnored(ignore, v) {
+			return "Appointment slot is already booked."
+		}
+	}
+	// Check if a chair is available
+	if clinics != nil {
+		if _, _, reason := appBst.getResource(date, session, duration, dentist, branch, clinics, ignore); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// addAppointment inserts an appointment into the binary search tree then append into JSON.
+func (appBst *BinarySearchTree) addAppointment(appointment *Appointment) {
+	appBst.Add(appointment.Date, appointment)
+	AddAppointmentData(appointment.GetJSONData())
+}
+
+// RescheduleAppointment moves an appointment to another dentist, date or session.
+// A new appointment is created using the same collision check before the existing appointment is deleted,
+// the new appointment keeps the series, type and duration of the existing appointment.
+// The existing branch is kept when a chair is available, otherwise any branch the dentist is working at is used.
+// An EventRescheduled event with the reason of the change is published when the appointment is moved.
+func (appBst *BinarySearchTree) RescheduleAppointment(appointment *Appointment, date string, session int, dentist *user.User, rota *Rota, calendar *Calendar, clinics Clinics, reason string) (*Appointment, error) {
+	bookingMutex.Lock()
+	newAppointment, err := appBst.rescheduleAppointment(appointment, date, session, dentist, rota, calendar, clinics)
+	bookingMutex.Unlock()
+	if err == nil {
+		Publish(Event{Type: EventRescheduled, Appointment: newAppointment, Previous: appointment, Reason: reason})
+	}
+	return newAppointment, err
+}
+
+// rescheduleAppointment is the unlocked version of RescheduleAppointment, caller must hold bookingMutex.
+func (appBst *BinarySearchTree) rescheduleAppointment(appointment *Appointment, date string, session int, dentist *user.User, rota *Rota, calendar *Calendar, clinics Clinics) (*Appointment, error) {
+	newAppointment := New(util.GenerateID(), appointment.Patient, dentist, date, session)
+	newAppointment.SeriesID = appointment.SeriesID
+	newAppointment.Type = appointment.Type
+	newAppointment.Duration = appointment.Duration
+	if clinic := clinics.GetForAppointment(appointment); clinic != nil {
+		if clinic, _, _ = appBst.getPreferredResource(date, session, newAppointment.GetDuration(), dentist, clinic.Code, clinics, nil); clinic != nil {
+			newAppointment.Clinic = clinic.Code
 		}
 	}
-	// If slot is not booked, proceed.
-	if !sessionBooked {
-		appointment := New(id, patient, dentist, date, session)
-		appointmentTree.Add(date, appointment)
-		appointmentJSON := New(id, patient.Username, dentist.Username, date, session)
-		AddAppointmentData(appointmentJSON)
-		chn <- true
+	if !appBst.createAppointment(newAppointment, rota, calendar, clinics) {
+		return nil, errors.New("error: appointment slot is not available")
+	}
+	if err := appBst.deleteAppointment(appointment); err != nil {
+		return newAppointment, err
+	}
+	return newAppointment, nil
+}
+
+// RescheduleInPlace moves an appointment to another dentist or session on the same date, the appointment is changed in place.
+// The slot is checked, changed and saved while holding bookingMutex, returns the reason the slot cannot be booked or empty if moved.
+// The existing branch is kept when a chair is available, otherwise any branch the dentist is working at is used.
+// An EventRescheduled event is published when the dentist or session is changed.
+func (appBst *BinarySearchTree) RescheduleInPlace(appointment *Appointment, session int, dentist *user.User, rota *Rota, calendar *Calendar, clinics Clinics) string {
+	bookingMutex.Lock()
+	previous := *appointment
+	conflict := appBst.rescheduleInPlace(appointment, session, dentist, rota, calendar, clinics)
+	bookingMutex.Unlock()
+	if conflict == "" && (previous.Dentist.(*user.User).Username != dentist.Username || previous.Session != session) {
+		Publish(Event{Type: EventRescheduled, Appointment: appointment, Previous: &previous})
+	}
+	return conflict
+}
+
+// rescheduleInPlace is the unlocked version of RescheduleInPlace, caller must hold bookingMutex.
+func (appBst *BinarySearchTree) rescheduleInPlace(appointment *Appointment, session int, dentist *user.User, rota *Rota, calendar *Calendar, clinics Clinics) string {
+	ignore := []*Appointment{appointment}
+	if conflict := appBst.getSlotConflict(appointment.Date, session, appointment.GetDuration(), dentist, "", rota, calendar, clinics, ignore); conflict != "" {
+		return conflict
+	}
+	oldAppointment := appointment.GetJSONData()
+	appointment.Dentist, appointment.Session = dentist, session
+	// Keep the branch when a chair is available, the chair may change with the session
+	if clinic := clinics.GetForAppointment(appointment); clinic != nil {
+		if clinic, chair, _ := appBst.getPreferredResource(appointment.Date, session, appointment.GetDuration(), dentist, clinic.Code, clinics, ignore); chair != nil {
+			appointment.Clinic, appointment.Chair = clinic.Code, chair.Code
+		}
+	}
+	UpdateAppointmentData(oldAppointment, appointment.GetJSONData())
+	return ""
+}
+
+// DeleteAppointment cancels an appointment, an EventCancelled event with the reason of the cancellation is published
+// when the appointment is deleted.
+func (appBst *BinarySearchTree) DeleteAppointment(appointment *Appointment, reason string) error {
+	bookingMutex.Lock()
+	err := appBst.deleteAppointment(appointment)
+	bookingMutex.Unlock()
+	if err == nil {
+		Publish(Event{Type: EventCancelled, Appointment: appointment, Reason: reason})
 	}
+	return err
 }
 
-// DeleteAppointment deletes an appointment from both binary search tree and JSON
-func (appBst *BinarySearchTree) DeleteAppointment(application *Appointment) error {
+// deleteAppointment deletes an appointment from both binary search tree and JSON, caller must hold bookingMutex.
+func (appBst *BinarySearchTree) deleteAppointment(application *Appointment) error {
 	var node *bst.BinaryNode
 	appID := application.ID
 	getBinaryNodeTraversal(appBst.GetRootNode(), application, &node)
@@ -216,7 +440,11 @@ func (appBst *BinarySearchTree) searchInOrderTraversal(t *bst.BinaryNode, field
 				*list = append(*list, t.Data.(*Appointment))
 			}
 		case "session":
-			if t.Data.(*Appointment).Session == value.(int) {
+			if t.Data.(*Appointment).Covers(value.(int)) {
+				*list = append(*list, t.Data.(*Appointment))
+			}
+		case "clinic":
+			if value.(*Clinic).HasAppointment(t.Data.(*Appointment)) {
 				*list = append(*list, t.Data.(*Appointment))
 			}
 		}
@@ -250,25 +478,75 @@ func GetDuplicate(list []*Appointment, count int) []*Appointment {
 	return temp
 }
 
-// GetDentistAvailability retrieve all dentist's appointment by date and set availability flag
-func GetDentistAvailability(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *BinarySearchTree, appointmentDate time.Time, Dentist *user.User) []AppSession {
+// GetDentistAvailability retrieve all dentist's appointment by date and set availability flag,
+// sessions which the dentist is not working according to the rota
+// or when clinic is closed are set as not available.
+// A session is only available when it and the following sessions covering the duration are all available
+// and a chair is free for all sessions at the branch (any branch the dentist is working at if empty),
+// EndTime of the session returned is the end of the last session covered. Chairs are not checked when clinics is nil.
+func GetDentistAvailability(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *BinarySearchTree, rota *Rota, calendar *Calendar, clinics Clinics, appointmentDate time.Time, Dentist *user.User, branch string, duration int) []AppSession {
 	var sessionList []AppSession
-	appointments := (*appointmentTree).GetAppointmentByDate(appointmentDate.Format("2006-01-02"), Dentist.Role, Dentist)
+	formattedDate := appointmentDate.Format("2006-01-02")
+	appointments := (*appointmentTree).GetAppointmentByDate(formattedDate, Dentist.Role, Dentist)
 	retSessionList := (**appointmentSessionList).GetList()
+	clinicClosed := calendar.IsClosed(formattedDate)
 	// Loop Session list and set dentist availability
 	for _, v := range retSessionList {
 		session := v.(AppSession)
+		if clinicClosed || !rota.IsWorking(Dentist.Username, appointmentDate, session.Num) {
+			session.Available = false
+		}
 		for _, data := range appointments {
-			if data.Session == session.Num {
+			if data.Covers(session.Num) {
 				session.Available = false
 			}
 		}
+		if session.Available && duration <= 1 && clinics != nil {
+			_, chair, _ := (*appointmentTree).getResource(formattedDate, session.Num, 1, Dentist, branch, clinics, nil)
+			session.Available = chair != nil
+		}
 		sessionList = append(sessionList, session)
 	}
-	return sessionList
+	if duration <= 1 {
+		return sessionList
+	}
+	// Combine consecutive sessions covering the duration
+	var durationList []AppSession
+	for k, v := range sessionList {
+		session := v
+		if k+duration > len(sessionList) {
+			session.Available = false
+		} else {
+			for _, next := range sessionList[k : k+duration] {
+				if !next.Available {
+					session.Available = false
+				}
+			}
+			if session.Available && clinics != nil {
+				_, chair, _ := (*appointmentTree).getResource(formattedDate, session.Num, duration, Dentist, branch, clinics, nil)
+				session.Available = chair != nil
+			}
+			session.EndTime = sessionList[k+duration-1].EndTime
+		}
+		durationList = append(durationList, session)
+	}
+	return durationList
+}
+
+// GetNextAvailableSlot searches for dentist's earliest available session within maxDays starting from a given date.
+func GetNextAvailableSlot(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *BinarySearchTree, rota *Rota, calendar *Calendar, clinics Clinics, from time.Time, maxDays int, Dentist *user.User, branch string, duration int) (string, int, bool) {
+	for i := 0; i < maxDays; i++ {
+		date := from.AddDate(0, 0, i)
+		for _, session := range GetDentistAvailability(appointmentSessionList, appointmentTree, rota, calendar, clinics, date, Dentist, branch, duration) {
+			if session.Available {
+				return date.Format("2006-01-02"), session.Num, true
+			}
+		}
+	}
+	return "", 0, false
 }
 
-// GetAppointmentByDate returns a list of elements based on date field.
+// GetAppointmentByDate returns a list of elements based on date field, all elements on the date if role is empty.
 func (appBst *BinarySearchTree) GetAppointmentByDate(date, role string, searchUser *user.User) []*Appointment {
 	defer func() {
 		if r := recover(); r != nil {
@@ -297,6 +575,9 @@ func (appBst *BinarySearchTree) searchAppointmentByDate(t *bst.BinaryNode, date,
 						*list = append(*list, t.Data.(*Appointment))
 					}
 				}
+				if role == "" {
+					*list = append(*list, t.Data.(*Appointment))
+				}
 				return appBst.searchAppointmentByDate(t.Right, date, role, searchUser, list)
 			} else {
 				if role == "dentist" {
@@ -309,6 +590,9 @@ func (appBst *BinarySearchTree) searchAppointmentByDate(t *bst.BinaryNode, date,
 						*list = append(*list, t.Data.(*Appointment))
 					}
 				}
+				if role == "" {
+					*list = append(*list, t.Data.(*Appointment))
+				}
 				return *list
 			}
 		} else {
@@ -0,0 +1,141 @@
+package appointment
+
+import (
+	"sync"
+	"testing"
+
+	bst "github.com/shiweii/binarysearchtree"
+	"github.com/shiweii/user"
+)
+
+func TestRemoveSameDate(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	appointments := []*Appointment{
+		New(1, patient, dentist, "2022-06-01", 1),
+		New(2, patient, dentist, "2022-06-13", 1),
+		New(3, patient, dentist, "2022-06-10", 1),
+		New(4, patient, dentist, "2022-06-10", 2),
+		New(5, patient, dentist, "2022-06-13", 2),
+		New(6, patient, dentist, "2022-06-13", 3),
+		New(7, patient, dentist, "2022-06-10", 3),
+	}
+
+	tests := []struct {
+		name   string
+		remove []int
+		res    map[string]int
+	}{
+		// Appointment 2 is an inner node with 2 children, appointments on the same date are kept on the right
+		{"inner node", []int{2}, map[string]int{"2022-06-10": 3, "2022-06-13": 2}},
+		{"inner node then same date", []int{2, 5}, map[string]int{"2022-06-10": 3, "2022-06-13": 1}},
+		{"inner node then left subtree", []int{2, 3, 4}, map[string]int{"2022-06-10": 1, "2022-06-13": 2}},
+		{"root", []int{1, 2}, map[string]int{"2022-06-01": 0, "2022-06-10": 3, "2022-06-13": 2}},
+	}
+	for _, tt := range tests {
+		appointmentTree := &BinarySearchTree{bst.New()}
+		for _, v := range appointments {
+			appointmentTree.Add(v.Date, v)
+		}
+		for _, id := range tt.remove {
+			var node *bst.BinaryNode
+			getBinaryNodeTraversal(appointmentTree.GetRootNode(), appointmentTree.GetAppointmentByID(id), &node)
+			if err := appointmentTree.Remove(node); err != nil {
+				t.Errorf("Remove(%v, %d) = %v; want nil", tt.name, id, err)
+			}
+			// Removing the same node again is reported
+			if err := appointmentTree.Remove(node); err == nil {
+				t.Errorf("Remove(%v, %d) again = nil; want error", tt.name, id)
+			}
+		}
+		for date, n := range tt.res {
+			if got := appointmentTree.GetAppointmentByDate(date, "", nil); len(got) != n {
+				t.Errorf("GetAppointmentByDate(%v) after %v = %d appointments; want %d", date, tt.name, len(got), n)
+			}
+		}
+		if got := len(appointmentTree.GetAllAppointments(nil, "")); got != len(appointments)-len(tt.remove) {
+			t.Errorf("GetAllAppointments() after %v = %d appointments; want %d", tt.name, got, len(appointments)-len(tt.remove))
+		}
+	}
+}
+
+func TestRescheduleInPlace(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist1 := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	dentist2 := user.New("dentist2", "", "dentist", "Naomi", "Nagata", 0)
+	appointmentTree := &BinarySearchTree{bst.New()}
+	appointment := New(1, patient, dentist1, "2022-06-06", 1)
+	appointment.Clinic, appointment.Chair = "north", "n1"
+	booked := New(2, patient, dentist2, "2022-06-06", 2)
+	booked.Clinic, booked.Chair = "north", "n1"
+	appointmentTree.Add(appointment.Date, appointment)
+	appointmentTree.Add(booked.Date, booked)
+	clinics := Clinics{
+		{Code: "north", Name: "North", Chairs: []*Chair{{Code: "n1", Name: "Chair 1"}}, Default: true},
+		{Code: "east", Name: "East", Chairs: []*Chair{{Code: "e1", Name: "Chair 1"}}, Dentists: []string{"dentist2"}},
+	}
+
+	tests := []struct {
+		name    string
+		dentist *user.User
+		session int
+		res     string
+		clinic  string
+	}{
+		{"chair taken", dentist1, 2, "No chair is available at North.", "north"},
+		{"dentist booked", dentist2, 2, "Appointment slot is already booked.", "north"},
+		{"same slot", dentist1, 1, "", "north"},
+		{"other dentist keeps branch", dentist2, 1, "", "north"},
+	}
+	for _, tt := range tests {
+		previous := *appointment
+		got := appointmentTree.RescheduleInPlace(appointment, tt.session, tt.dentist, NewRota(), NewCalendar(), clinics)
+		if got != tt.res {
+			t.Errorf("RescheduleInPlace(%v) = %q; want %q", tt.name, got, tt.res)
+		}
+		if tt.res != "" && (appointment.Dentist != previous.Dentist || appointment.Session != previous.Session) {
+			t.Errorf("RescheduleInPlace(%v) changed appointment to %v session %d; want unchanged", tt.name, appointment.Dentist, appointment.Session)
+		}
+		if tt.res == "" && (appointment.Dentist != tt.dentist || appointment.Session != tt.session) {
+			t.Errorf("RescheduleInPlace(%v) = %v session %d; want %v session %d", tt.name, appointment.Dentist, appointment.Session, tt.dentist.Username, tt.session)
+		}
+		if appointment.Clinic != tt.clinic {
+			t.Errorf("RescheduleInPlace(%v).Clinic = %v; want %v", tt.name, appointment.Clinic, tt.clinic)
+		}
+	}
+}
+
+func TestCreateNewAppointment(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist1 := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	dentist2 := user.New("dentist2", "", "dentist", "Naomi", "Nagata", 0)
+
+	tests := []struct {
+		name  string
+		rules BookingRules
+		res   int
+	}{
+		{"without rules", BookingRules{}, 2},
+		// Concurrent bookings are evaluated against the rules one at a time
+		{"no double booking", BookingRules{NoDoubleBooking: true}, 1},
+		{"max active per patient", BookingRules{MaxActivePerPatient: 1}, 1},
+	}
+	for _, tt := range tests {
+		appointmentTree := &BinarySearchTree{bst.New()}
+		engine := NewRulesEngine(tt.rules)
+		var wg sync.WaitGroup
+		for k, dentist := range []*user.User{dentist1, dentist2} {
+			wg.Add(1)
+			go func(id int, dentist *user.User) {
+				defer wg.Done()
+				chn := make(chan bool, 1)
+				CreateNewAppointment(id, getTestBookingRequest(patient, dentist, "2022-06-06", 1), "", engine, appointmentTree, NewRota(), NewCalendar(), nil, chn)
+				<-chn
+			}(k+1, dentist)
+		}
+		wg.Wait()
+		if got := appointmentTree.GetAppointmentByDate("2022-06-06", "patient", patient); len(got) != tt.res {
+			t.Errorf("CreateNewAppointment(%v) = %d appointments; want %d", tt.name, len(got), tt.res)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package appointment
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shiweii/config"
+	"github.com/shiweii/integrity"
+	"github.com/shiweii/logger"
+)
+
+// DentistAvailability struct stores a dentist's weekly rota, leave dates and blocked slots.
+// WeeklyRota is keyed by weekday name (Monday, Tuesday...) and holds the session numbers worked.
+type DentistAvailability struct {
+	Dentist      string           `json:"dentist"`
+	WeeklyRota   map[string][]int `json:"weeklyRota"`
+	LeaveDates   []string         `json:"leaveDates"`
+	BlockedSlots []BlockedSlot    `json:"blockedSlots"`
+}
+
+// BlockedSlot struct stores an ad-hoc session which a dentist is not available.
+type BlockedSlot struct {
+	Date    string `json:"date"`
+	Session int    `json:"session"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Rota stores the availability of all dentists using username as key, safe for concurrent use.
+// Availabilities stored are not changed in place, changes are stored with Set.
+type Rota struct {
+	mu             sync.RWMutex
+	availabilities map[string]*DentistAvailability
+}
+
+// Weekdays in the order displayed to users.
+var Weekdays = []time.Weekday{
+	time.Monday,
+	time.Tuesday,
+	time.Wednesday,
+	time.Thursday,
+	time.Friday,
+	time.Saturday,
+	time.Sunday,
+}
+
+// NewDentistAvailability will return a newly created availability,
+// dentist will be working all sessions from Monday to Friday by default.
+func NewDentistAvailability(dentist string, sessions []int) *DentistAvailability {
+	availability := &DentistAvailability{
+		Dentist:    dentist,
+		WeeklyRota: make(map[string][]int),
+	}
+	for _, day := range Weekdays {
+		if day == time.Saturday || day == time.Sunday {
+			continue
+		}
+		availability.WeeklyRota[day.String()] = append([]int(nil), sessions...)
+	}
+	return availability
+}
+
+// NewRota will return a newly created rota without availability data.
+func NewRota() *Rota {
+	return &Rota{availabilities: make(map[string]*DentistAvailability)}
+}
+
+// GetRotaData will open, read and unmarshal dentist availability data from JSON file.
+func GetRotaData() *Rota {
+	var availabilities []*DentistAvailability
+	rota := NewRota()
+	JSONData, err := ioutil.ReadFile(config.Get().Data.Availability)
+	if err != nil {
+		logger.Warning.Println(err)
+		return rota
+	}
+	if err = json.Unmarshal(JSONData, &availabilities); err != nil {
+		logger.Error.Println(err)
+	}
+	for _, v := range availabilities {
+		rota.availabilities[v.Dentist] = v
+	}
+	return rota
+}
+
+// SaveRotaData will marshal and write all dentist availability data into JSON file.
+func SaveRotaData(rota *Rota) {
+	var availabilities []*DentistAvailability
+	rota.mu.RLock()
+	for _, v := range rota.availabilities {
+		availabilities = append(availabilities, v)
+	}
+	rota.mu.RUnlock()
+	sort.Slice(availabilities, func(i, j int) bool {
+		return availabilities[i].Dentist < availabilities[j].Dentist
+	})
+	JSONData, _ := json.MarshalIndent(availabilities, "", " ")
+	err := integrity.WriteFile(config.Get().Data.Availability, JSONData, 0644)
+	if err != nil {
+		logger.Error.Println(err)
+	}
+}
+
+// Get returns a copy of the availability of a dentist to be changed and stored with Set,
+// a default availability is returned without being stored if dentist does not have one.
+func (rota *Rota) Get(dentist string, sessions []int) *DentistAvailability {
+	rota.mu.RLock()
+	defer rota.mu.RUnlock()
+	availability, ok := rota.availabilities[dentist]
+	if !ok {
+		return NewDentistAvailability(dentist, sessions)
+	}
+	return availability.copy()
+}
+
+// Set stores a copy of the availability of a dentist, replacing the existing availability.
+func (rota *Rota) Set(availability *DentistAvailability) {
+	rota.mu.Lock()
+	defer rota.mu.Unlock()
+	rota.availabilities[availability.Dentist] = availability.copy()
+}
+
+// IsWorking checks if dentist is working on a given date and session.
+// Dentists without availability data works on weekdays only.
+func (rota *Rota) IsWorking(dentist string, date time.Time, session int) bool {
+	rota.mu.RLock()
+	defer rota.mu.RUnlock()
+	availability, ok := rota.availabilities[dentist]
+	if !ok {
+		return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday
+	}
+	return availability.IsWorking(date, session)
+}
+
+// copy returns a deep copy of an availability.
+func (a *DentistAvailability) copy() *DentistAvailability {
+	availability := &DentistAvailability{
+		Dentist:      a.Dentist,
+		WeeklyRota:   make(map[string][]int, len(a.WeeklyRota)),
+		LeaveDates:   append([]string(nil), a.LeaveDates...),
+		BlockedSlots: append([]BlockedSlot(nil), a.BlockedSlots...),
+	}
+	for k, v := range a.WeeklyRota {
+		availability.WeeklyRota[k] = append([]int(nil), v...)
+	}
+	return availability
+}
+
+// IsWorking checks against weekly rota, leave dates and blocked slots
+// to determine if dentist is working on a given date and session.
+func (a *DentistAvailability) IsWorking(date time.Time, session int) bool {
+	formattedDate := date.Format("2006-01-02")
+	if a.IsOnLeave(formattedDate) {
+		return false
+	}
+	for _, v := range a.BlockedSlots {
+		if v.Date == formattedDate && v.Session == session {
+			return false
+		}
+	}
+	return a.WorksSession(date.Weekday().String(), session)
+}
+
+// WorksSession checks if session is in dentist's weekly rota.
+func (a *DentistAvailability) WorksSession(weekday string, session int) bool {
+	for _, v := range a.WeeklyRota[weekday] {
+		if v == session {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOnLeave checks if dentist is on leave on a given date (YYYY-MM-DD).
+func (a *DentistAvailability) IsOnLeave(date string) bool {
+	for _, v := range a.LeaveDates {
+		if v == date {
+			return true
+		}
+	}
+	return false
+}
+
+// SetWeeklyRota replaces the sessions worked on a given weekday.
+func (a *DentistAvailability) SetWeeklyRota(weekday string, sessions []int) {
+	if len(sessions) == 0 {
+		delete(a.WeeklyRota, weekday)
+		return
+	}
+	sort.Ints(sessions)
+	a.WeeklyRota[weekday] = sessions
+}
+
+// AddLeave adds a leave date, duplicated dates are ignored.
+func (a *DentistAvailability) AddLeave(date string) {
+	if a.IsOnLeave(date) {
+		return
+	}
+	a.LeaveDates = append(a.LeaveDates, date)
+	sort.Strings(a.LeaveDates)
+}
+
+// RemoveLeave removes a leave date.
+func (a *DentistAvailability) RemoveLeave(date string) {
+	for k, v := range a.LeaveDates {
+		if v == date {
+			a.LeaveDates = append(a.LeaveDates[:k], a.LeaveDates[k+1:]...)
+			return
+		}
+	}
+}
+
+// AddBlockedSlot blocks a session on a given date, duplicated slots are ignored.
+func (a *DentistAvailability) AddBlockedSlot(date string, session int, reason string) {
+	for _, v := range a.BlockedSlots {
+		if v.Date == date && v.Session == session {
+			return
+		}
+	}
+	a.BlockedSlots = append(a.BlockedSlots, BlockedSlot{Date: date, Session: session, Reason: reason})
+	sort.Slice(a.BlockedSlots, func(i, j int) bool {
+		if a.BlockedSlots[i].Date == a.BlockedSlots[j].Date {
+			return a.BlockedSlots[i].Session < a.BlockedSlots[j].Session
+		}
+		return a.BlockedSlots[i].Date < a.BlockedSlots[j].Date
+	})
+}
+
+// RemoveBlockedSlot removes a blocked session on a given date.
+func (a *DentistAvailability) RemoveBlockedSlot(date string, session int) {
+	for k, v := range a.BlockedSlots {
+		if v.Date == date && v.Session == session {
+			a.BlockedSlots = append(a.BlockedSlots[:k], a.BlockedSlots[k+1:]...)
+			return
+		}
+	}
+}
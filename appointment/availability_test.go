@@ -0,0 +1,104 @@
+package appointment
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotaIsWorking(t *testing.T) {
+	rota := NewRota()
+	availability := rota.Get("dentist1", []int{1, 2, 3})
+	availability.SetWeeklyRota("Monday", []int{3, 1})
+	availability.SetWeeklyRota("Tuesday", nil)
+	availability.SetWeeklyRota("Saturday", []int{2})
+	availability.AddLeave("2022-06-15")
+	availability.AddLeave("2022-06-15")
+	availability.AddBlockedSlot("2022-06-16", 2, "Training")
+	availability.AddBlockedSlot("2022-06-16", 2, "Training")
+	rota.Set(availability)
+
+	tests := []struct {
+		dentist string
+		date    string
+		session int
+		res     bool
+	}{
+		// Weekly rota
+		{"dentist1", "2022-06-13", 1, true},
+		{"dentist1", "2022-06-13", 2, false},
+		{"dentist1", "2022-06-14", 1, false},
+		{"dentist1", "2022-06-18", 2, true},
+		{"dentist1", "2022-06-19", 1, false},
+		// Leave
+		{"dentist1", "2022-06-15", 1, false},
+		{"dentist1", "2022-06-22", 1, true},
+		// Blocked time
+		{"dentist1", "2022-06-16", 2, false},
+		{"dentist1", "2022-06-16", 1, true},
+		{"dentist1", "2022-06-23", 2, true},
+		// Dentists without availability work on weekdays
+		{"dentist2", "2022-06-13", 1, true},
+		{"dentist2", "2022-06-18", 1, false},
+	}
+	for _, tt := range tests {
+		date, _ := time.Parse("2006-01-02", tt.date)
+		if got := rota.IsWorking(tt.dentist, date, tt.session); got != tt.res {
+			t.Errorf("IsWorking(%v, %v, %d) = %t; want %t", tt.dentist, tt.date, tt.session, got, tt.res)
+		}
+	}
+
+	availability.RemoveLeave("2022-06-15")
+	availability.RemoveBlockedSlot("2022-06-16", 2)
+	if date := time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC); rota.IsWorking("dentist1", date, 1) {
+		t.Errorf("IsWorking() = true; want false until the availability is stored")
+	}
+	rota.Set(availability)
+	if date := time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC); !rota.IsWorking("dentist1", date, 1) {
+		t.Errorf("IsWorking() = false after removing leave; want true")
+	}
+	if date := time.Date(2022, 6, 16, 0, 0, 0, 0, time.UTC); !rota.IsWorking("dentist1", date, 2) {
+		t.Errorf("IsWorking() = false after removing blocked time; want true")
+	}
+}
+
+func TestRotaGet(t *testing.T) {
+	rota := NewRota()
+	got := rota.Get("dentist1", []int{1, 2})
+	if len(got.WeeklyRota) != 5 || len(got.WeeklyRota["Monday"]) != 2 || got.WeeklyRota["Sunday"] != nil {
+		t.Errorf("Get() = %v; want sessions 1 and 2 from Monday to Friday", got.WeeklyRota)
+	}
+	if _, ok := rota.availabilities["dentist1"]; ok {
+		t.Errorf("Get() stored the default availability; want not stored")
+	}
+
+	got.SetWeeklyRota("Monday", []int{1})
+	rota.Set(got)
+	got.SetWeeklyRota("Monday", []int{2})
+	if res := rota.Get("dentist1", nil).WeeklyRota["Monday"]; len(res) != 1 || res[0] != 1 {
+		t.Errorf("Get().WeeklyRota[Monday] = %v; want [1]", res)
+	}
+}
+
+func TestRotaConcurrentAccess(t *testing.T) {
+	rota := NewRota()
+	date := time.Date(2022, 6, 13, 0, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			availability := rota.Get("dentist1", []int{1, 2})
+			availability.AddLeave("2022-06-13")
+			rota.Set(availability)
+		}()
+		go func() {
+			defer wg.Done()
+			rota.IsWorking("dentist1", date, 1)
+		}()
+	}
+	wg.Wait()
+	if rota.IsWorking("dentist1", date, 1) {
+		t.Errorf("IsWorking() = true; want false on leave")
+	}
+}
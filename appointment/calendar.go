@@ -0,0 +1,346 @@
+package appointment
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shiweii/config"
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/integrity"
+	"github.com/shiweii/logger"
+	"github.com/shiweii/user"
+)
+
+const (
+	// calendarProductID identifies the application in iCalendar objects.
+	calendarProductID = "-//GoSchool Dental Clinic//Appointments//EN"
+	// calendarUIDDomain is the right-hand side of event UIDs, UIDs must never change once published.
+	calendarUIDDomain = "appointments.goschool-dental"
+	// calendarCancelledRetention is how long cancelled appointments remain in calendar feeds.
+	calendarCancelledRetention = 90 * 24 * time.Hour
+	// calendarLineLength is the maximum length of a content line in octets (RFC 5545 section 3.1).
+	calendarLineLength = 75
+)
+
+// CalendarEvent struct stores an appointment as an iCalendar (RFC 5545) VEVENT.
+// Sequence is increased every time the appointment is changed so that calendars replace the previous version.
+type CalendarEvent struct {
+	UID         string
+	Sequence    int
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+	Cancelled   bool
+}
+
+// CancelledAppointment struct stores an appointment removed from the binary search tree,
+// cancelled appointments remain in calendar feeds so that calendars remove them.
+type CancelledAppointment struct {
+	Appointment *Appointment `json:"appointment"`
+	Sequence    int          `json:"sequence"`
+	CancelledAt time.Time    `json:"cancelledAt"`
+}
+
+// CalendarFeeds holds the secret token of each user's calendar feed, the sequence of appointments
+// changed in place and recently cancelled appointments, every change is written into JSON file.
+// Changes are kept in memory only when path is empty.
+type CalendarFeeds struct {
+	mu   sync.Mutex
+	path string
+	data calendarFeedData
+}
+
+// calendarFeedData struct stores the persisted data of calendar feeds.
+type calendarFeedData struct {
+	Tokens    map[string]string       `json:"tokens"`
+	Sequences map[int]int             `json:"sequences"`
+	Cancelled []*CancelledAppointment `json:"cancelled"`
+}
+
+// NewCalendarFeeds will return newly created calendar feeds persisted into path.
+func NewCalendarFeeds(path string) *CalendarFeeds {
+	return &CalendarFeeds{
+		path: path,
+		data: calendarFeedData{
+			Tokens:    make(map[string]string),
+			Sequences: make(map[int]int),
+		},
+	}
+}
+
+// GetCalendarFeedData will open, read and unmarshal calendar feed data from JSON file declared in .env.
+func GetCalendarFeedData() *CalendarFeeds {
+	return LoadCalendarFeeds(config.Get().Data.CalendarFeeds)
+}
+
+// LoadCalendarFeeds will open, read and unmarshal calendar feed data from JSON file.
+func LoadCalendarFeeds(path string) *CalendarFeeds {
+	feeds := NewCalendarFeeds(path)
+	JSONData, err := ioutil.ReadFile(feeds.path)
+	if err != nil {
+		logger.Warning.Println(err)
+		return feeds
+	}
+	if err = json.Unmarshal(JSONData, &feeds.data); err != nil {
+		logger.Error.Println(err)
+	}
+	if feeds.data.Tokens == nil {
+		feeds.data.Tokens = make(map[string]string)
+	}
+	if feeds.data.Sequences == nil {
+		feeds.data.Sequences = make(map[int]int)
+	}
+	return feeds
+}
+
+// saveCalendarFeedData will marshal and write calendar feed data into JSON file.
+func (f *CalendarFeeds) saveCalendarFeedData() {
+	if f.path == "" {
+		return
+	}
+	JSONData, _ := json.MarshalIndent(f.data, "", " ")
+	if err := integrity.WriteFile(f.path, JSONData, 0600); err != nil {
+		logger.Error.Println(err)
+	}
+}
+
+// GetToken returns the calendar feed token of a user, a new token is created when the user has none.
+func (f *CalendarFeeds) GetToken(username string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if token, ok := f.data.Tokens[username]; ok {
+		return token
+	}
+	return f.newToken(username)
+}
+
+// ResetToken replaces the calendar feed token of a user, the previous feed URL stops working.
+func (f *CalendarFeeds) ResetToken(username string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.newToken(username)
+}
+
+// newToken creates and saves a random token for a user, caller must hold mu.
+func (f *CalendarFeeds) newToken(username string) string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		logger.Error.Println(err)
+		return ""
+	}
+	token := hex.EncodeToString(b)
+	f.data.Tokens[username] = token
+	f.saveCalendarFeedData()
+	return token
+}
+
+// GetUsername returns the user a calendar feed token belongs to, empty if token is not valid.
+func (f *CalendarFeeds) GetUsername(token string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found string
+	for username, v := range f.data.Tokens {
+		if subtle.ConstantTimeCompare([]byte(v), []byte(token)) == 1 {
+			found = username
+		}
+	}
+	return found
+}
+
+// GetSequence returns the number of times an appointment was changed in place.
+func (f *CalendarFeeds) GetSequence(id int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.data.Sequences[id]
+}
+
+// GetCancelled returns the cancelled appointments of a patient or dentist.
+func (f *CalendarFeeds) GetCancelled(username string) []*CancelledAppointment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []*CancelledAppointment
+	for _, v := range f.data.Cancelled {
+		if v.Appointment.Patient == username || v.Appointment.Dentist == username {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Record is subscribed to appointment events to keep calendar feeds up to date.
+// Appointments changed in place get a new sequence, appointments cancelled or
+// rescheduled into a new appointment are kept as cancelled. Old cancellations are removed.
+func (f *CalendarFeeds) Record(event Event) {
+	if event.Type == EventBooked {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	removed := event.Appointment
+	if event.Type == EventRescheduled {
+		if event.Previous.ID == event.Appointment.ID {
+			f.data.Sequences[event.Appointment.ID]++
+			f.saveCalendarFeedData()
+			return
+		}
+		removed = event.Previous
+	}
+	f.data.Cancelled = append(f.data.Cancelled, &CancelledAppointment{
+		Appointment: removed.GetJSONData(),
+		Sequence:    f.data.Sequences[removed.ID] + 1,
+		CancelledAt: event.Time,
+	})
+	delete(f.data.Sequences, removed.ID)
+
+	var cancelled []*CancelledAppointment
+	for _, v := range f.data.Cancelled {
+		if event.Time.Sub(v.CancelledAt) < calendarCancelledRetention {
+			cancelled = append(cancelled, v)
+		}
+	}
+	f.data.Cancelled = cancelled
+	f.saveCalendarFeedData()
+}
+
+// GetCalendarUID returns the UID of the calendar event of an appointment.
+func GetCalendarUID(id int) string {
+	return fmt.Sprintf("appointment-%d@%v", id, calendarUIDDomain)
+}
+
+// GetCalendarEvent returns the calendar event of an appointment as seen by a patient, dentist or admin (role).
+// Dentist and patient may be users or usernames.
+func GetCalendarEvent(appointmentSessionList **dll.DoublyLinkedList, clinics Clinics, types AppointmentTypes, appointment *Appointment, role string, sequence int) (CalendarEvent, error) {
+	event := CalendarEvent{
+		UID:      GetCalendarUID(appointment.ID),
+		Sequence: sequence,
+	}
+	if appointment.Session < 1 || appointment.GetLastSession() > (**appointmentSessionList).GetSize() {
+		return event, fmt.Errorf("error: appointment [%v] has invalid session", appointment.ID)
+	}
+	var err error
+	firstSession := (**appointmentSessionList).Get(appointment.Session).(AppSession)
+	lastSession := (**appointmentSessionList).Get(appointment.GetLastSession()).(AppSession)
+	if event.Start, err = GetStartTime(appointment.Date, firstSession); err != nil {
+		return event, err
+	}
+	if event.End, err = time.ParseInLocation("2006-01-02 15:04", appointment.Date+" "+lastSession.EndTime, time.Local); err != nil {
+		return event, err
+	}
+
+	dentist, patient := getDisplayName(appointment.Dentist), getDisplayName(appointment.Patient)
+	switch role {
+	case "patient":
+		event.Summary = fmt.Sprintf("Dental appointment with Dr. %v", dentist)
+	case "dentist":
+		event.Summary = fmt.Sprintf("Appointment with %v", patient)
+	default:
+		event.Summary = fmt.Sprintf("%v with Dr. %v", patient, dentist)
+	}
+	var description []string
+	if appointmentType := types.GetForAppointment(appointment); appointmentType != nil {
+		description = append(description, "Type: "+appointmentType.Name)
+	}
+	if clinic := clinics.GetForAppointment(appointment); clinic != nil {
+		event.Location = clinic.Name
+		if clinic.Address != "" {
+			event.Location += ", " + clinic.Address
+		}
+		if chair := clinic.GetChair(appointment.Chair); chair != nil {
+			description = append(description, "Chair: "+chair.Name)
+		}
+	}
+	event.Description = strings.Join(description, "\n")
+	return event, nil
+}
+
+// getDisplayName returns the full name of a user, or the username when user is not loaded.
+func getDisplayName(v interface{}) string {
+	switch u := v.(type) {
+	case *user.User:
+		if u != nil {
+			return u.FirstName + " " + u.LastName
+		}
+	case string:
+		return u
+	}
+	return ""
+}
+
+// WriteCalendar writes events as an iCalendar object named name, stamp is the time the object is created.
+// Times are written in UTC and long lines are folded as required by RFC 5545.
+func WriteCalendar(w io.Writer, name string, events []CalendarEvent, stamp time.Time) error {
+	const timeFormat = "20060102T150405Z"
+	var b strings.Builder
+	writeLine := func(property, value string) {
+		b.WriteString(foldLine(property + ":" + value))
+	}
+	writeLine("BEGIN", "VCALENDAR")
+	writeLine("VERSION", "2.0")
+	writeLine("PRODID", calendarProductID)
+	writeLine("CALSCALE", "GREGORIAN")
+	writeLine("METHOD", "PUBLISH")
+	if name != "" {
+		writeLine("X-WR-CALNAME", escapeText(name))
+	}
+	for _, v := range events {
+		writeLine("BEGIN", "VEVENT")
+		writeLine("UID", v.UID)
+		writeLine("SEQUENCE", fmt.Sprint(v.Sequence))
+		writeLine("DTSTAMP", stamp.UTC().Format(timeFormat))
+		writeLine("DTSTART", v.Start.UTC().Format(timeFormat))
+		writeLine("DTEND", v.End.UTC().Format(timeFormat))
+		writeLine("SUMMARY", escapeText(v.Summary))
+		if v.Description != "" {
+			writeLine("DESCRIPTION", escapeText(v.Description))
+		}
+		if v.Location != "" {
+			writeLine("LOCATION", escapeText(v.Location))
+		}
+		if v.Cancelled {
+			writeLine("STATUS", "CANCELLED")
+		} else {
+			writeLine("STATUS", "CONFIRMED")
+		}
+		writeLine("END", "VEVENT")
+	}
+	writeLine("END", "VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeText escapes backslashes, semicolons, commas and line breaks in TEXT values.
+func escapeText(text string) string {
+	return strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n", "\r", "").Replace(text)
+}
+
+// foldLine splits a content line longer than 75 octets into lines starting with a space, ended by CRLF.
+// Lines are never split within a UTF-8 character.
+func foldLine(line string) string {
+	var b strings.Builder
+	limit := calendarLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space which counts toward the limit
+		limit = calendarLineLength - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package appointment
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/user"
+)
+
+func TestWriteCalendar(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	sessionList := dll.New()
+	sessionList.Add(AppSession{1, "09:00", "10:00", true})
+	sessionList.Add(AppSession{2, "10:00", "11:00", true})
+	clinics := Clinics{{Code: "main", Name: "Main Clinic", Address: "1 Orchard Road, #02-01; Singapore", Chairs: []*Chair{{Code: "c1", Name: "Chair 1"}}, Default: true}}
+	types := AppointmentTypes{{Code: "filling", Name: "Filling", Sessions: 2}}
+	appointment := New(42, patient, dentist, "2022-06-06", 1)
+	appointment.SetType(types[0])
+	appointment.Chair = "c1"
+
+	event, err := GetCalendarEvent(&sessionList, clinics, types, appointment, "patient", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.End.Sub(event.Start) != 2*time.Hour || event.Summary != "Dental appointment with Dr. James Holden" {
+		t.Errorf("GetCalendarEvent() = %+v", event)
+	}
+	var b strings.Builder
+	if err = WriteCalendar(&b, "Appointments", []CalendarEvent{event}, time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:appointment-42@" + calendarUIDDomain + "\r\n",
+		"SEQUENCE:2\r\n",
+		"DTSTAMP:20220601T000000Z\r\n",
+		"LOCATION:Main Clinic\\, 1 Orchard Road\\, #02-01\\; Singapore\r\n",
+		"DESCRIPTION:Type: Filling\\nChair: Chair 1\r\n",
+		"STATUS:CONFIRMED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteCalendar() missing %q in\n%v", want, got)
+		}
+	}
+
+	folded := foldLine("SUMMARY:" + strings.Repeat("é", 50))
+	for _, line := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+		if len(line) > calendarLineLength {
+			t.Errorf("foldLine() line of %d octets; want at most %d", len(line), calendarLineLength)
+		}
+	}
+}
+
+func TestCalendarFeeds(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	feeds := NewCalendarFeeds("")
+	token := feeds.GetToken("patient1")
+	if token == "" || feeds.GetToken("patient1") != token || feeds.GetUsername(token) != "patient1" {
+		t.Errorf("GetToken(patient1) = %q; want same token on every call", token)
+	}
+	if feeds.ResetToken("patient1") == token || feeds.GetUsername(token) != "" {
+		t.Errorf("ResetToken(patient1) kept the previous token valid")
+	}
+
+	now := time.Now()
+	appointment := New(1, patient, dentist, "2022-06-06", 1)
+	edited := *appointment
+	edited.Session = 2
+	feeds.Record(Event{Type: EventRescheduled, Appointment: &edited, Previous: appointment, Time: now})
+	if got := feeds.GetSequence(1); got != 1 {
+		t.Errorf("GetSequence(1) after edit = %d; want 1", got)
+	}
+	moved := New(2, patient, dentist, "2022-06-07", 1)
+	feeds.Record(Event{Type: EventRescheduled, Appointment: moved, Previous: &edited, Time: now})
+	feeds.Record(Event{Type: EventCancelled, Appointment: moved, Time: now})
+	cancelled := feeds.GetCancelled("dentist1")
+	if len(cancelled) != 2 || cancelled[0].Appointment.ID != 1 || cancelled[0].Sequence != 2 || cancelled[1].Appointment.ID != 2 {
+		t.Errorf("GetCancelled(dentist1) = %v; want appointments 1 (sequence 2) and 2", cancelled)
+	}
+	if got := feeds.GetCancelled("patient2"); len(got) != 0 {
+		t.Errorf("GetCancelled(patient2) = %v; want none", got)
+	}
+}
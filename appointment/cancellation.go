@@ -0,0 +1,104 @@
+package appointment
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/shiweii/config"
+	"github.com/shiweii/integrity"
+	"github.com/shiweii/logger"
+)
+
+// Cancellation struct stores an appointment removed from the binary search tree
+// and the reason it was cancelled, empty if cancelled by the patient.
+type Cancellation struct {
+	Appointment *Appointment `json:"appointment"`
+	Reason      string       `json:"reason,omitempty"`
+	CancelledAt time.Time    `json:"cancelledAt"`
+}
+
+// CancellationLog holds all cancelled appointments for reporting and writes every change into JSON file,
+// changes are kept in memory only when path is empty.
+type CancellationLog struct {
+	mu            sync.Mutex
+	path          string
+	cancellations []*Cancellation
+}
+
+// NewCancellationLog will return a newly created cancellation log persisted into path.
+func NewCancellationLog(path string) *CancellationLog {
+	return &CancellationLog{path: path}
+}
+
+// GetCancellationData will open, read and unmarshal cancellation data from JSON file declared in .env.
+func GetCancellationData() *CancellationLog {
+	return LoadCancellationLog(config.Get().Data.Cancellations)
+}
+
+// LoadCancellationLog will open, read and unmarshal cancellation data from JSON file.
+func LoadCancellationLog(path string) *CancellationLog {
+	cancellationLog := NewCancellationLog(path)
+	JSONData, err := ioutil.ReadFile(cancellationLog.path)
+	if err != nil {
+		logger.Warning.Println(err)
+		return cancellationLog
+	}
+	if err = json.Unmarshal(JSONData, &cancellationLog.cancellations); err != nil {
+		logger.Error.Println(err)
+	}
+	return cancellationLog
+}
+
+// saveCancellationData will marshal and write all cancellations into JSON file.
+func (c *CancellationLog) saveCancellationData() {
+	if c.path == "" {
+		return
+	}
+	JSONData, _ := json.MarshalIndent(c.cancellations, "", " ")
+	if err := integrity.WriteFile(c.path, JSONData, 0644); err != nil {
+		logger.Error.Println(err)
+	}
+}
+
+// Record is subscribed to appointment events to keep cancelled appointments,
+// appointments rescheduled into a new appointment are not cancellations.
+func (c *CancellationLog) Record(event Event) {
+	if event.Type != EventCancelled {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancellations = append(c.cancellations, &Cancellation{
+		Appointment: event.Appointment.GetJSONData(),
+		Reason:      event.Reason,
+		CancelledAt: event.Time,
+	})
+	c.saveCancellationData()
+}
+
+// GetCancellations returns cancelled appointments which were scheduled between from and to (YYYY-MM-DD) inclusive.
+func (c *CancellationLog) GetCancellations(from, to string) []*Cancellation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var list []*Cancellation
+	for _, v := range c.cancellations {
+		if v.Appointment.Date >= from && v.Appointment.Date <= to {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// SetNoShow marks a past appointment as attended or not attended by the patient and updates JSON.
+func (appBst *BinarySearchTree) SetNoShow(appointment *Appointment, noShow bool) {
+	bookingMutex.Lock()
+	defer bookingMutex.Unlock()
+	if appointment.NoShow == noShow {
+		return
+	}
+	oldAppointment := appointment.GetJSONData()
+	appointment.NoShow = noShow
+	UpdateAppointmentData(oldAppointment, appointment.GetJSONData())
+}
@@ -0,0 +1,227 @@
+package appointment
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/shiweii/config"
+	"github.com/shiweii/logger"
+	"github.com/shiweii/user"
+)
+
+// Clinic struct stores a branch of the clinic and the chairs (rooms) available for appointments.
+// Dentists holds the usernames of dentists working at the branch, empty if all dentists.
+// Appointments created before branches were introduced belong to the Default branch.
+type Clinic struct {
+	Code     string   `json:"code"`
+	Name     string   `json:"name"`
+	Address  string   `json:"address,omitempty"`
+	Chairs   []*Chair `json:"chairs"`
+	Dentists []string `json:"dentists,omitempty"`
+	Default  bool     `json:"default,omitempty"`
+}
+
+// Chair struct stores a chair (room) of a branch, only one appointment can take a chair at a time.
+type Chair struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// Clinics stores all branches in the order displayed to users.
+type Clinics []*Clinic
+
+// defaultClinics are used when clinic data is not available.
+var defaultClinics = Clinics{
+	{
+		Code:    "main",
+		Name:    "Main Clinic",
+		Chairs:  []*Chair{{Code: "chair1", Name: "Chair 1"}, {Code: "chair2", Name: "Chair 2"}, {Code: "chair3", Name: "Chair 3"}},
+		Default: true,
+	},
+}
+
+// GetClinicData will open, read and unmarshal clinic branch data from JSON file.
+// The first branch is used as default branch when none is set.
+func GetClinicData() Clinics {
+	var clinics Clinics
+	JSONData, err := ioutil.ReadFile(config.Get().Data.Clinics)
+	if err != nil {
+		logger.Warning.Println(err)
+		return defaultClinics
+	}
+	if err = json.Unmarshal(JSONData, &clinics); err != nil || len(clinics) == 0 {
+		logger.Error.Println(err)
+		return defaultClinics
+	}
+	if clinics.GetDefault() == clinics[0] {
+		clinics[0].Default = true
+	}
+	return clinics
+}
+
+// Get returns the branch by code, nil if not found.
+func (clinics Clinics) Get(code string) *Clinic {
+	for _, v := range clinics {
+		if v.Code == code {
+			return v
+		}
+	}
+	return nil
+}
+
+// GetDefault returns the default branch, the first branch if none is set.
+func (clinics Clinics) GetDefault() *Clinic {
+	for _, v := range clinics {
+		if v.Default {
+			return v
+		}
+	}
+	if len(clinics) > 0 {
+		return clinics[0]
+	}
+	return nil
+}
+
+// GetForDentist returns the branches a dentist is working at.
+func (clinics Clinics) GetForDentist(dentist string) Clinics {
+	var list Clinics
+	for _, v := range clinics {
+		if v.HasDentist(dentist) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// GetForAppointment returns the branch of an appointment,
+// appointments without branch or with a removed branch belong to the default branch.
+func (clinics Clinics) GetForAppointment(appointment *Appointment) *Clinic {
+	if clinic := clinics.Get(appointment.Clinic); clinic != nil {
+		return clinic
+	}
+	return clinics.GetDefault()
+}
+
+// FilterDentists returns the dentists working at a branch, all dentists if branch is nil.
+func (c *Clinic) FilterDentists(dentists []*user.User) []*user.User {
+	if c == nil {
+		return dentists
+	}
+	var list []*user.User
+	for _, v := range dentists {
+		if c.HasDentist(v.Username) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// HasDentist checks if dentist is working at the branch.
+func (c *Clinic) HasDentist(dentist string) bool {
+	if len(c.Dentists) == 0 {
+		return true
+	}
+	for _, v := range c.Dentists {
+		if v == dentist {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAppointment checks if an appointment is at the branch,
+// appointments without branch are at the default branch.
+func (c *Clinic) HasAppointment(appointment *Appointment) bool {
+	if appointment.Clinic == "" {
+		return c.Default
+	}
+	return appointment.Clinic == c.Code
+}
+
+// GetChair returns the chair by code, nil if not found.
+func (c *Clinic) GetChair(code string) *Chair {
+	for _, v := range c.Chairs {
+		if v.Code == code {
+			return v
+		}
+	}
+	return nil
+}
+
+// getFreeChair returns a chair of the branch which is not taken by any appointment within a number of sessions
+// starting from session, nil if all chairs are taken. Appointments without chair take one of the chairs
+// and appointments in ignore are not counted. Caller must hold bookingMutex when booking.
+func (appBst *BinarySearchTree) getFreeChair(clinic *Clinic, date string, session, duration int, ignore []*Appointment) *Chair {
+	var taken = make(map[string]bool)
+	count := 0
+	for _, v := range appBst.GetAppointmentByDate(date, "", nil) {
+		if !clinic.HasAppointment(v) || !v.Overlaps(session, duration) || isIgnored(ignore, v) {
+			continue
+		}
+		count++
+		taken[v.Chair] = true
+	}
+	if count >= len(clinic.Chairs) {
+		return nil
+	}
+	for _, v := range clinic.Chairs {
+		if !taken[v.Code] {
+			return v
+		}
+	}
+	return nil
+}
+
+// getResource returns the branch and chair for an appointment of a dentist, or the reason no chair is available.
+// When branch is empty all branches the dentist is working at are searched in order.
+func (appBst *BinarySearchTree) getResource(date string, session, duration int, dentist *user.User, branch string, clinics Clinics, ignore []*Appointment) (*Clinic, *Chair, string) {
+	candidates := clinics.GetForDentist(dentist.Username)
+	if branch != "" {
+		clinic := clinics.Get(branch)
+		if clinic == nil || !clinic.HasDentist(dentist.Username) {
+			return nil, nil, "Dentist is not working at the selected branch."
+		}
+		candidates = Clinics{clinic}
+	}
+	if len(candidates) == 0 {
+		return nil, nil, "Dentist is not working at any branch."
+	}
+	for _, clinic := range candidates {
+		if chair := appBst.getFreeChair(clinic, date, session, duration, ignore); chair != nil {
+			return clinic, chair, ""
+		}
+	}
+	if len(candidates) == 1 {
+		return nil, nil, fmt.Sprintf("No chair is available at %v.", candidates[0].Name)
+	}
+	return nil, nil, "No chair is available at any branch."
+}
+
+// GetPreferredResource returns the branch and chair for an appointment of a dentist, the preferred branch is used
+// when a chair is available there, otherwise any branch the dentist is working at. Appointments in ignore are not counted.
+func (appBst *BinarySearchTree) GetPreferredResource(date string, session, duration int, dentist *user.User, preferred string, clinics Clinics, ignore []*Appointment) (*Clinic, *Chair, string) {
+	bookingMutex.Lock()
+	defer bookingMutex.Unlock()
+	return appBst.getPreferredResource(date, session, duration, dentist, preferred, clinics, ignore)
+}
+
+// getPreferredResource is the unlocked version of GetPreferredResource, caller must hold bookingMutex.
+func (appBst *BinarySearchTree) getPreferredResource(date string, session, duration int, dentist *user.User, preferred string, clinics Clinics, ignore []*Appointment) (*Clinic, *Chair, string) {
+	if clinic := clinics.Get(preferred); clinic != nil && clinic.HasDentist(dentist.Username) {
+		if chair := appBst.getFreeChair(clinic, date, session, duration, ignore); chair != nil {
+			return clinic, chair, ""
+		}
+	}
+	return appBst.getResource(date, session, duration, dentist, "", clinics, ignore)
+}
+
+// isIgnored checks if an appointment is in the list of appointments to ignore.
+func isIgnored(ignore []*Appointment, appointment *Appointment) bool {
+	for _, v := range ignore {
+		if v == appointment {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package appointment
+
+import (
+	"testing"
+	"time"
+
+	bst "github.com/shiweii/binarysearchtree"
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/user"
+)
+
+func TestClinicChairs(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist1 := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	dentist2 := user.New("dentist2", "", "dentist", "Naomi", "Nagata", 0)
+	dentist3 := user.New("dentist3", "", "dentist", "Amos", "Burton", 0)
+	sessionList := dll.New()
+	sessionList.Add(AppSession{1, "09:00", "10:00", true})
+	sessionList.Add(AppSession{2, "10:00", "11:00", true})
+	appointmentTree := &BinarySearchTree{bst.New()}
+	// Appointment without branch belongs to the default branch
+	legacy := New(1, patient, dentist1, "2022-06-06", 1)
+	appointmentTree.Add(legacy.Date, legacy)
+	rota := NewRota()
+	calendar := NewCalendar()
+	clinics := Clinics{
+		{Code: "north", Name: "North", Chairs: []*Chair{{Code: "n1", Name: "Chair 1"}}, Default: true},
+		{Code: "east", Name: "East", Chairs: []*Chair{{Code: "e1", Name: "Chair 1"}}, Dentists: []string{"dentist2"}},
+	}
+
+	tests := []struct {
+		dentist *user.User
+		branch  string
+		session int
+		res     string
+	}{
+		{dentist2, "north", 1, "No chair is available at North."},
+		{dentist2, "north", 2, ""},
+		{dentist2, "east", 1, ""},
+		{dentist2, "", 1, ""},
+		{dentist3, "east", 1, "Dentist is not working at the selected branch."},
+		{dentist3, "", 1, "No chair is available at North."},
+	}
+	for _, tt := range tests {
+		if got := appointmentTree.GetSlotConflict("2022-06-06", tt.session, 1, tt.dentist, tt.branch, rota, calendar, clinics, nil); got != tt.res {
+			t.Errorf("GetSlotConflict(%v, %q, session %d) = %q; want %q", tt.dentist.Username, tt.branch, tt.session, got, tt.res)
+		}
+	}
+
+	date := time.Date(2022, 6, 6, 0, 0, 0, 0, time.UTC)
+	got := GetDentistAvailability(&sessionList, appointmentTree, rota, calendar, clinics, date, dentist3, "", 1)
+	if got[0].Available || !got[1].Available {
+		t.Errorf("GetDentistAvailability(dentist3) = %v; want session 1 not available", got)
+	}
+	if clinic, chair, _ := appointmentTree.getResource("2022-06-06", 1, 1, dentist2, "", clinics, nil); clinic.Code != "east" || chair.Code != "e1" {
+		t.Errorf("getResource(dentist2) = %v %v; want east e1", clinic.Code, chair.Code)
+	}
+	if clinic := clinics.GetForAppointment(legacy); clinic.Code != "north" {
+		t.Errorf("GetForAppointment(legacy) = %v; want north", clinic.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package appointment
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shiweii/metrics"
+)
+
+// Event types published when an appointment is booked, moved or cancelled.
+const (
+	EventBooked      = "booked"
+	EventRescheduled = "rescheduled"
+	EventCancelled   = "cancelled"
+)
+
+// Event struct stores a change made to an appointment.
+// Appointment is the appointment after the change, or the removed appointment when cancelled.
+// Previous is a copy of the appointment before it was rescheduled, nil for other events.
+// Reason is set when the change was not requested by the patient, e.g. clinic closure.
+type Event struct {
+	Type        string
+	Appointment *Appointment
+	Previous    *Appointment
+	Reason      string
+	Time        time.Time
+}
+
+// EventHandler is called with every published event, handlers must not block.
+type EventHandler func(event Event)
+
+var (
+	eventMutex    sync.RWMutex
+	eventHandlers []EventHandler
+)
+
+// Subscribe registers a handler to be called with every appointment event.
+func Subscribe(handler EventHandler) {
+	eventMutex.Lock()
+	defer eventMutex.Unlock()
+	eventHandlers = append(eventHandlers, handler)
+}
+
+// Publish calls all subscribed handlers with an appointment event, events are counted by type in metrics.
+// Events are published after bookingMutex is released so that handlers are able to read appointments.
+func Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	metrics.CountAppointmentEvent(event.Type)
+	eventMutex.RLock()
+	defer eventMutex.RUnlock()
+	for _, handler := range eventHandlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,215 @@
+package appointment
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shiweii/config"
+	"github.com/shiweii/integrity"
+	"github.com/shiweii/logger"
+)
+
+// Holiday struct stores a clinic closure date.
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// Calendar stores all clinic closure dates using date (YYYY-MM-DD) as key, safe for concurrent use.
+// Closures stored are not changed in place, changes are made with Add and Remove.
+type Calendar struct {
+	mu       sync.RWMutex
+	holidays map[string]*Holiday
+}
+
+// NewCalendar will return a newly created calendar without closure dates.
+func NewCalendar() *Calendar {
+	return &Calendar{holidays: make(map[string]*Holiday)}
+}
+
+// GetHolidayData will open, read and unmarshal clinic closure data from JSON file.
+func GetHolidayData() *Calendar {
+	var holidays []*Holiday
+	calendar := NewCalendar()
+	JSONData, err := ioutil.ReadFile(config.Get().Data.Holidays)
+	if err != nil {
+		logger.Warning.Println(err)
+		return calendar
+	}
+	if err = json.Unmarshal(JSONData, &holidays); err != nil {
+		logger.Error.Println(err)
+	}
+	for _, v := range holidays {
+		calendar.holidays[v.Date] = v
+	}
+	return calendar
+}
+
+// SaveHolidayData will marshal and write all clinic closure data into JSON file.
+func SaveHolidayData(calendar *Calendar) {
+	JSONData, _ := json.MarshalIndent(calendar.GetHolidays(""), "", " ")
+	err := integrity.WriteFile(config.Get().Data.Holidays, JSONData, 0644)
+	if err != nil {
+		logger.Error.Println(err)
+	}
+}
+
+// IsClosed checks if clinic is closed on a given date (YYYY-MM-DD).
+func (calendar *Calendar) IsClosed(date string) bool {
+	calendar.mu.RLock()
+	defer calendar.mu.RUnlock()
+	_, ok := calendar.holidays[date]
+	return ok
+}
+
+// Get returns the closure on a given date (YYYY-MM-DD), nil if clinic is open.
+func (calendar *Calendar) Get(date string) *Holiday {
+	calendar.mu.RLock()
+	defer calendar.mu.RUnlock()
+	return calendar.holidays[date]
+}
+
+// Add adds a closure date, returns false if clinic is already closed on the date.
+func (calendar *Calendar) Add(date, name string) bool {
+	calendar.mu.Lock()
+	defer calendar.mu.Unlock()
+	if _, ok := calendar.holidays[date]; ok {
+		return false
+	}
+	calendar.holidays[date] = &Holiday{Date: date, Name: name}
+	return true
+}
+
+// Remove removes a closure date and returns the closure removed, nil if clinic was open.
+func (calendar *Calendar) Remove(date string) *Holiday {
+	calendar.mu.Lock()
+	defer calendar.mu.Unlock()
+	holiday := calendar.holidays[date]
+	delete(calendar.holidays, date)
+	return holiday
+}
+
+// Range calls f for each closure in no particular order, stops when f returns false.
+// Calendar must not be changed by f.
+func (calendar *Calendar) Range(f func(holiday *Holiday) bool) {
+	calendar.mu.RLock()
+	defer calendar.mu.RUnlock()
+	for _, v := range calendar.holidays {
+		if !f(v) {
+			return
+		}
+	}
+}
+
+// GetHolidays returns all closures on or after a given date sorted by date.
+func (calendar *Calendar) GetHolidays(from string) []*Holiday {
+	var holidays []*Holiday
+	calendar.Range(func(holiday *Holiday) bool {
+		if holiday.Date >= from {
+			holidays = append(holidays, holiday)
+		}
+		return true
+	})
+	sort.Slice(holidays, func(i, j int) bool {
+		return holidays[i].Date < holidays[j].Date
+	})
+	return holidays
+}
+
+// ParseICalendar reads VEVENT components from an iCalendar (RFC 5545) file and returns
+// a closure for each day covered by the event. DTEND is exclusive as defined by the RFC.
+func ParseICalendar(r io.Reader) ([]*Holiday, error) {
+	var (
+		holidays []*Holiday
+		lines    []string
+		inEvent  bool
+		start    time.Time
+		end      time.Time
+		summary  string
+	)
+
+	// Unfold lines, continuation lines begin with a space or tab
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || lines[0] != "BEGIN:VCALENDAR" {
+		return nil, errors.New("error: not an iCalendar file")
+	}
+
+	for _, line := range lines {
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		// Remove parameters such as DTSTART;VALUE=DATE
+		name, _, _ = strings.Cut(name, ";")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if value == "VEVENT" {
+				inEvent = true
+				start, end, summary = time.Time{}, time.Time{}, ""
+			}
+		case "DTSTART":
+			if inEvent {
+				start = parseICalendarDate(value)
+			}
+		case "DTEND":
+			if inEvent {
+				end = parseICalendarDate(value)
+			}
+		case "SUMMARY":
+			if inEvent {
+				summary = unescapeICalendarText(value)
+			}
+		case "END":
+			if value == "VEVENT" && inEvent {
+				inEvent = false
+				if start.IsZero() {
+					continue
+				}
+				if !end.After(start) {
+					end = start.AddDate(0, 0, 1)
+				}
+				for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+					holidays = append(holidays, &Holiday{Date: d.Format("2006-01-02"), Name: summary})
+				}
+			}
+		}
+	}
+	return holidays, nil
+}
+
+// parseICalendarDate parse DATE (YYYYMMDD) or DATE-TIME (YYYYMMDDTHHMMSS) value and return the date.
+func parseICalendarDate(value string) time.Time {
+	if len(value) < 8 {
+		return time.Time{}
+	}
+	td, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		logger.Error.Println(err)
+		return time.Time{}
+	}
+	return td
+}
+
+// unescapeICalendarText removes escape characters from iCalendar text values.
+func unescapeICalendarText(value string) string {
+	replacer := strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return replacer.Replace(value)
+}
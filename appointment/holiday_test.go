@@ -0,0 +1,100 @@
+package appointment
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseICalendar(t *testing.T) {
+	ics := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART;VALUE=DATE:20221225\r\n" +
+		"DTEND;VALUE=DATE:20221226\r\n" +
+		"SUMMARY:Christmas Day\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART;VALUE=DATE:20230122\r\n" +
+		"DTEND;VALUE=DATE:20230124\r\n" +
+		"SUMMARY:Chinese New\r\n" +
+		"  Year\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART:20220809T000000Z\r\n" +
+		"SUMMARY:National Day\\, Singapore\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	got, err := ParseICalendar(strings.NewReader(ics))
+	if err != nil {
+		t.Fatalf("ParseICalendar() returned error: %v", err)
+	}
+	res := []Holiday{
+		{"2022-12-25", "Christmas Day"},
+		{"2023-01-22", "Chinese New Year"},
+		{"2023-01-23", "Chinese New Year"},
+		{"2022-08-09", "National Day, Singapore"},
+	}
+	if len(got) != len(res) {
+		t.Fatalf("ParseICalendar() = %d holidays; want %d", len(got), len(res))
+	}
+	for k, v := range res {
+		if *got[k] != v {
+			t.Errorf("ParseICalendar()[%d] = %v; want %v", k, *got[k], v)
+		}
+	}
+
+	_, err = ParseICalendar(strings.NewReader("Hello World"))
+	if err == nil {
+		t.Errorf("ParseICalendar(Hello World) = nil; want error")
+	}
+}
+
+func TestCalendarIsClosed(t *testing.T) {
+	calendar := NewCalendar()
+	calendar.Add("2022-12-25", "Christmas Day")
+
+	got := calendar.IsClosed("2022-12-25")
+	res := true
+	if got != res {
+		t.Errorf("IsClosed(2022-12-25) = %t; want %t got %t", got, res, got)
+	}
+
+	got = calendar.Add("2022-12-25", "Christmas")
+	res = false
+	if got != res {
+		t.Errorf("Add(2022-12-25) = %t; want %t got %t", got, res, got)
+	}
+
+	if holiday := calendar.Remove("2022-12-25"); holiday == nil || holiday.Name != "Christmas Day" {
+		t.Errorf("Remove(2022-12-25) = %v; want Christmas Day", holiday)
+	}
+	got = calendar.IsClosed("2022-12-25")
+	res = false
+	if got != res {
+		t.Errorf("IsClosed(2022-12-25) = %t; want %t got %t", got, res, got)
+	}
+}
+
+func TestCalendarConcurrentAccess(t *testing.T) {
+	calendar := NewCalendar()
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			calendar.Add(fmt.Sprintf("2022-12-%02d", i), "Closure")
+		}(i)
+		go func() {
+			defer wg.Done()
+			calendar.IsClosed("2022-12-01")
+			calendar.GetHolidays("")
+		}()
+	}
+	wg.Wait()
+	if got := calendar.GetHolidays("2022-12-05"); len(got) != 6 || got[0].Date != "2022-12-05" {
+		t.Errorf("GetHolidays(2022-12-05) = %d closures; want 6 from 2022-12-05", len(got))
+	}
+}
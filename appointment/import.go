@@ -0,0 +1,94 @@
+package appointment
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bst "github.com/shiweii/binarysearchtree"
+	"github.com/shiweii/config"
+	"github.com/shiweii/integrity"
+	"github.com/shiweii/user"
+)
+
+// ImportAppointments checks imported appointments against existing appointments, the rota, clinic closures,
+// chairs and each other in the given order. Dry run (apply is false) or any rejected appointment leaves all data unchanged,
+// otherwise all appointments are inserted into the binary search tree and appended into JSON in a single step.
+// Returns the reason each rejected appointment cannot be imported by index. Events are not published for imports.
+func ImportAppointments(appointments []*Appointment, appointmentTree *BinarySearchTree, rota *Rota, calendar *Calendar, clinics Clinics, apply bool) map[int]string {
+	var (
+		rejected = make(map[int]string)
+		accepted []*Appointment
+	)
+	bookingMutex.Lock()
+	defer bookingMutex.Unlock()
+	for k, v := range appointments {
+		dentist := v.Dentist.(*user.User)
+		// Appointments are checked against the tree and the appointments accepted before them
+		dateTree := appointmentTree.getDateTree(v.Date, accepted)
+		if dateTree.isDuplicate(v) {
+			rejected[k] = "Appointment already exists."
+			continue
+		}
+		if reason := dateTree.getSlotConflict(v.Date, v.Session, v.GetDuration(), dentist, v.Clinic, rota, calendar, clinics, nil); reason != "" {
+			rejected[k] = reason
+			continue
+		}
+		if clinics != nil {
+			clinic, chair, reason := dateTree.getResource(v.Date, v.Session, v.GetDuration(), dentist, v.Clinic, clinics, nil)
+			if chair == nil {
+				rejected[k] = reason
+				continue
+			}
+			v.Clinic, v.Chair = clinic.Code, chair.Code
+		}
+		accepted = append(accepted, v)
+	}
+	if len(rejected) > 0 || !apply {
+		return rejected
+	}
+	for _, v := range accepted {
+		appointmentTree.Add(v.Date, v)
+	}
+	addAppointmentDataList(accepted)
+	return rejected
+}
+
+// getDateTree returns a separate binary search tree of the appointments on a date and the appointments
+// in list on the same date, so that appointments are able to be checked without changing the tree.
+func (appBst *BinarySearchTree) getDateTree(date string, list []*Appointment) *BinarySearchTree {
+	dateTree := &BinarySearchTree{bst.New()}
+	for _, v := range appBst.GetAppointmentByDate(date, "", nil) {
+		dateTree.Add(date, v)
+	}
+	for _, v := range list {
+		if v.Date == date {
+			dateTree.Add(date, v)
+		}
+	}
+	return dateTree
+}
+
+// isDuplicate checks if the patient already has an appointment with the dentist on the same date and session.
+func (appBst *BinarySearchTree) isDuplicate(appointment *Appointment) bool {
+	patient := appointment.Patient.(*user.User)
+	dentist := appointment.Dentist.(*user.User)
+	for _, v := range appBst.GetAppointmentByDate(appointment.Date, "dentist", dentist) {
+		if v.Session == appointment.Session && v.Patient.(*user.User).Username == patient.Username {
+			return true
+		}
+	}
+	return false
+}
+
+// addAppointmentDataList will open, marshal and append a list of new appointments into JSON file in a single write.
+func addAppointmentDataList(list []*Appointment) {
+	appointments := GetAppointmentData()
+	for _, v := range list {
+		appointments = append(appointments, v.GetJSONData())
+	}
+	JSONData, _ := json.MarshalIndent(appointments, "", " ")
+	err := integrity.WriteFile(config.Get().Data.Appointments, JSONData, 0644)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,70 @@
+package appointment
+
+import (
+	"testing"
+
+	bst "github.com/shiweii/binarysearchtree"
+	"github.com/shiweii/user"
+)
+
+func TestImportAppointmentsDryRun(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	other := user.New("patient2", "", "patient", "Rory", "Williams", 98765432)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	appointmentTree := &BinarySearchTree{bst.New()}
+	appointmentTree.Add("2022-06-13", New(1, patient, dentist, "2022-06-13", 1))
+	rota := NewRota()
+	calendar := NewCalendar()
+	calendar.Add("2022-06-20", "Holiday")
+
+	appointments := []*Appointment{
+		New(2, patient, dentist, "2022-06-13", 1),
+		New(3, patient, dentist, "2022-06-14", 1),
+		New(4, other, dentist, "2022-06-14", 1),
+		New(5, other, dentist, "2022-06-20", 1),
+	}
+	got := ImportAppointments(appointments, appointmentTree, rota, calendar, nil, false)
+	if got[0] != "Appointment already exists." {
+		t.Errorf("ImportAppointments()[0] = %q; want duplicate", got[0])
+	}
+	if _, ok := got[1]; ok {
+		t.Errorf("ImportAppointments()[1] = %q; want accepted", got[1])
+	}
+	if got[2] == "" || got[3] == "" {
+		t.Errorf("ImportAppointments() = %v; want rows 2 and 3 rejected", got)
+	}
+	if n := len(appointmentTree.GetAllAppointments(dentist, "dentist")); n != 1 {
+		t.Errorf("GetAllAppointments() after dry run = %d appointments; want 1", n)
+	}
+}
+
+func TestImportAppointmentsApply(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	other := user.New("patient2", "", "patient", "Rory", "Williams", 98765432)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	rota := NewRota()
+	calendar := NewCalendar()
+
+	tests := []struct {
+		name         string
+		appointments []*Appointment
+		rejected     int
+		res          int
+	}{
+		{"all accepted", []*Appointment{New(2, patient, dentist, "2022-06-13", 2), New(3, other, dentist, "2022-06-13", 3)}, 0, 3},
+		// Rows are checked against earlier rows of the same import
+		{"earlier row", []*Appointment{New(2, patient, dentist, "2022-06-13", 2), New(3, other, dentist, "2022-06-13", 2)}, 1, 1},
+		{"existing appointment", []*Appointment{New(2, other, dentist, "2022-06-13", 1)}, 1, 1},
+	}
+	for _, tt := range tests {
+		appointmentTree := &BinarySearchTree{bst.New()}
+		appointmentTree.Add("2022-06-13", New(1, patient, dentist, "2022-06-13", 1))
+		got := ImportAppointments(tt.appointments, appointmentTree, rota, calendar, nil, true)
+		if len(got) != tt.rejected {
+			t.Errorf("ImportAppointments(%v) = %v; want %d rejected", tt.name, got, tt.rejected)
+		}
+		if n := len(appointmentTree.GetAllAppointments(dentist, "dentist")); n != tt.res {
+			t.Errorf("GetAllAppointments() after ImportAppointments(%v) = %d appointments; want %d", tt.name, n, tt.res)
+		}
+	}
+}
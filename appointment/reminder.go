@@ -0,0 +1,192 @@
+package appointment
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/shiweii/config"
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/integrity"
+	"github.com/shiweii/logger"
+)
+
+// Reminder delivery status of an appointment on a channel.
+// A delivery is marked as sending before the message is sent so that a reminder
+// interrupted by a restart is never sent twice.
+const (
+	ReminderSending = "sending"
+	ReminderSent    = "sent"
+	ReminderFailed  = "failed"
+	ReminderSkipped = "skipped" // patient has no contact for the channel
+)
+
+// MaxReminderAttempts is the number of times a failed reminder is retried.
+const MaxReminderAttempts = 3
+
+// ReminderDelivery struct stores the delivery state of a reminder sent for an appointment through a channel.
+// Date and Session are kept so that a new reminder is sent when an appointment is moved.
+type ReminderDelivery struct {
+	AppointmentID int       `json:"appointmentId"`
+	Date          string    `json:"date"`
+	Session       int       `json:"session"`
+	Channel       string    `json:"channel"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"lastError,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// ReminderLog holds the delivery state of all reminders and writes every change into JSON file,
+// changes are kept in memory only when path is empty.
+type ReminderLog struct {
+	mu         sync.Mutex
+	path       string
+	deliveries []*ReminderDelivery
+}
+
+// NewReminderLog will return a newly created reminder log persisted into path.
+func NewReminderLog(path string) *ReminderLog {
+	return &ReminderLog{path: path}
+}
+
+// GetReminderData will open, read and unmarshal reminder delivery data from JSON file declared in .env.
+func GetReminderData() *ReminderLog {
+	return LoadReminderLog(config.Get().Data.Reminders)
+}
+
+// LoadReminderLog will open, read and unmarshal reminder delivery data from JSON file.
+func LoadReminderLog(path string) *ReminderLog {
+	reminderLog := NewReminderLog(path)
+	JSONData, err := ioutil.ReadFile(reminderLog.path)
+	if err != nil {
+		logger.Warning.Println(err)
+		return reminderLog
+	}
+	if err = json.Unmarshal(JSONData, &reminderLog.deliveries); err != nil {
+		logger.Error.Println(err)
+	}
+	return reminderLog
+}
+
+// GetReminderLeadTime returns how long before an appointment reminders are sent.
+func GetReminderLeadTime() time.Duration {
+	return config.Get().ReminderLeadTime
+}
+
+// saveReminderData will marshal and write all reminder deliveries into JSON file.
+func (r *ReminderLog) saveReminderData() {
+	if r.path == "" {
+		return
+	}
+	JSONData, _ := json.MarshalIndent(r.deliveries, "", " ")
+	err := integrity.WriteFile(r.path, JSONData, 0644)
+	if err != nil {
+		logger.Error.Println(err)
+	}
+}
+
+// get returns the delivery of a reminder for an appointment through a channel, nil if none.
+func (r *ReminderLog) get(appointment *Appointment, channel string) *ReminderDelivery {
+	for _, v := range r.deliveries {
+		if v.AppointmentID == appointment.ID && v.Date == appointment.Date && v.Session == appointment.Session && v.Channel == channel {
+			return v
+		}
+	}
+	return nil
+}
+
+// Start marks the reminder of an appointment through a channel as sending, returns false
+// if the reminder was already sent, skipped, interrupted or failed too many times.
+func (r *ReminderLog) Start(appointment *Appointment, channel string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery := r.get(appointment, channel)
+	if delivery == nil {
+		delivery = &ReminderDelivery{
+			AppointmentID: appointment.ID,
+			Date:          appointment.Date,
+			Session:       appointment.Session,
+			Channel:       channel,
+		}
+		r.deliveries = append(r.deliveries, delivery)
+	} else if delivery.Status != ReminderFailed || delivery.Attempts >= MaxReminderAttempts {
+		return false
+	}
+	delivery.Status = ReminderSending
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+	r.saveReminderData()
+	return true
+}
+
+// Finish records the result of a reminder started for an appointment through a channel.
+func (r *ReminderLog) Finish(appointment *Appointment, channel, status string, err error, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery := r.get(appointment, channel)
+	if delivery == nil {
+		return
+	}
+	delivery.Status = status
+	delivery.LastError = ""
+	if err != nil {
+		delivery.LastError = err.Error()
+	}
+	delivery.UpdatedAt = now
+	r.saveReminderData()
+}
+
+// GetDeliveries returns a copy of the reminder deliveries of an appointment.
+func (r *ReminderLog) GetDeliveries(appointment *Appointment) []ReminderDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var list []ReminderDelivery
+	for _, v := range r.deliveries {
+		if v.AppointmentID == appointment.ID && v.Date == appointment.Date && v.Session == appointment.Session {
+			list = append(list, *v)
+		}
+	}
+	return list
+}
+
+// Prune removes deliveries of appointments before a given date (YYYY-MM-DD).
+func (r *ReminderLog) Prune(before string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deliveries []*ReminderDelivery
+	for _, v := range r.deliveries {
+		if v.Date >= before {
+			deliveries = append(deliveries, v)
+		}
+	}
+	if len(deliveries) != len(r.deliveries) {
+		r.deliveries = deliveries
+		r.saveReminderData()
+	}
+}
+
+// GetDueReminders returns copies of appointments starting after now and within the lead time, sorted by date.
+// Appointments are read while holding bookingMutex so that it is safe to call from background routines.
+func (appBst *BinarySearchTree) GetDueReminders(appointmentSessionList **dll.DoublyLinkedList, now time.Time, lead time.Duration) []*Appointment {
+	var list []*Appointment
+	var appointments []*Appointment
+	bookingMutex.Lock()
+	defer bookingMutex.Unlock()
+	appBst.searchAppointments(appBst.GetRootNode(), now.Format("2006-01-02"), nil, "", &appointments)
+	for _, v := range appointments {
+		if v.Session < 1 || v.Session > (**appointmentSessionList).GetSize() {
+			continue
+		}
+		startTime, err := GetStartTime(v.Date, (**appointmentSessionList).Get(v.Session).(AppSession))
+		if err != nil {
+			continue
+		}
+		if startTime.After(now) && !startTime.After(now.Add(lead)) {
+			appointment := *v
+			list = append(list, &appointment)
+		}
+	}
+	return list
+}
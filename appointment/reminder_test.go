@@ -0,0 +1,73 @@
+package appointment
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bst "github.com/shiweii/binarysearchtree"
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/user"
+)
+
+func TestReminderLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reminders.json")
+	now := time.Date(2022, 6, 5, 9, 0, 0, 0, time.Local)
+	appointment := New(1, nil, nil, "2022-06-06", 1)
+	reminderLog := NewReminderLog(path)
+
+	if !reminderLog.Start(appointment, "sms", now) {
+		t.Fatal("Start() = false; want true for new reminder")
+	}
+	reminderLog.Finish(appointment, "sms", ReminderFailed, errors.New("gateway down"), now)
+	if !reminderLog.Start(appointment, "sms", now) {
+		t.Fatal("Start() = false; want true to retry failed reminder")
+	}
+	// Reminder interrupted while sending is not sent again after restart
+	reminderLog = LoadReminderLog(path)
+	if reminderLog.Start(appointment, "sms", now) {
+		t.Error("Start() after restart = true; want false for reminder being sent")
+	}
+	if !reminderLog.Start(appointment, "email", now) {
+		t.Error("Start(email) = false; want true for another channel")
+	}
+	reminderLog.Finish(appointment, "email", ReminderSent, nil, now)
+	if got := reminderLog.GetDeliveries(appointment); len(got) != 2 || got[0].Attempts != 2 || got[1].Status != ReminderSent {
+		t.Errorf("GetDeliveries() = %v; want sms with 2 attempts and email sent", got)
+	}
+	// Moved appointment is reminded again
+	appointment.Session = 2
+	if !reminderLog.Start(appointment, "sms", now) {
+		t.Error("Start() after moving appointment = false; want true")
+	}
+	reminderLog.Prune("2022-06-07")
+	if got := LoadReminderLog(path).GetDeliveries(appointment); len(got) != 0 {
+		t.Errorf("GetDeliveries() after Prune() = %v; want none", got)
+	}
+}
+
+func TestGetDueReminders(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	sessionList := dll.New()
+	sessionList.Add(AppSession{1, "09:00", "10:00", true})
+	sessionList.Add(AppSession{2, "10:00", "11:00", true})
+	appointmentTree := &BinarySearchTree{bst.New()}
+	for k, v := range []struct {
+		date    string
+		session int
+	}{{"2022-06-05", 1}, {"2022-06-06", 1}, {"2022-06-06", 2}, {"2022-06-07", 1}} {
+		a := New(k+1, patient, dentist, v.date, v.session)
+		appointmentTree.Add(a.Date, a)
+	}
+	now := time.Date(2022, 6, 5, 9, 30, 0, 0, time.Local)
+	got := appointmentTree.GetDueReminders(&sessionList, now, 24*time.Hour)
+	if len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("GetDueReminders() = %v; want appointment 2", got)
+	}
+	// Appointments returned are copies which are safe to read after the tree is changed
+	if len(got) == 1 && got[0] == appointmentTree.GetAppointmentByID(2) {
+		t.Errorf("GetDueReminders() = appointment in the tree; want a copy")
+	}
+}
@@ -0,0 +1,223 @@
+package appointment
+
+import (
+	"sort"
+	"time"
+
+	bst "github.com/shiweii/binarysearchtree"
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/user"
+)
+
+// MaxReportDays is the longest date range a report is computed for.
+const MaxReportDays = 366
+
+// busiestSlotCount is the number of busiest slots listed in a report.
+const busiestSlotCount = 5
+
+// Report struct stores the utilization and attendance of appointments scheduled between From and To.
+// Cancellation rate is cancelled over booked and cancelled appointments, no-show rate is no-shows over
+// appointments already past. New patients are patients without any appointment before From.
+type Report struct {
+	From             string           `json:"from"`
+	To               string           `json:"to"`
+	Appointments     int              `json:"appointments"`
+	Cancellations    int              `json:"cancellations"`
+	CancellationRate float64          `json:"cancellationRate"`
+	PastAppointments int              `json:"pastAppointments"`
+	NoShows          int              `json:"noShows"`
+	NoShowRate       float64          `json:"noShowRate"`
+	NewPatients      int              `json:"newPatients"`
+	Dentists         []*DentistReport `json:"dentists"`
+	BusiestSlots     []SlotReport     `json:"busiestSlots"`
+}
+
+// DentistReport struct stores the utilization of a dentist in total, by session and by weekday.
+type DentistReport struct {
+	Dentist      string        `json:"dentist"`
+	Name         string        `json:"name"`
+	Appointments int           `json:"appointments"`
+	Total        UsageReport   `json:"total"`
+	BySession    []UsageReport `json:"bySession"`
+	ByWeekday    []UsageReport `json:"byWeekday"`
+}
+
+// UsageReport struct stores the number of sessions booked out of sessions available,
+// sessions are available when clinic is opened and dentist is working according to the rota.
+type UsageReport struct {
+	Label     string  `json:"label"`
+	Booked    int     `json:"booked"`
+	Available int     `json:"available"`
+	Rate      float64 `json:"rate"`
+}
+
+// SlotReport struct stores the number of appointments taking a session on a weekday.
+type SlotReport struct {
+	Weekday      string `json:"weekday"`
+	Session      int    `json:"session"`
+	Appointments int    `json:"appointments"`
+}
+
+// GetAppointmentsInRange returns all appointments from date to date (YYYY-MM-DD) inclusive in date order,
+// subtrees outside of the range are not visited.
+func (appBst *BinarySearchTree) GetAppointmentsInRange(from, to string) []*Appointment {
+	var list []*Appointment
+	searchRange(appBst.GetRootNode(), from, to, &list)
+	return list
+}
+
+// searchRange traverse the binary search tree in order and appends appointments within the range.
+// Equal keys may be found on both sides of a node after removal, so both sides are visited on equal keys.
+func searchRange(t *bst.BinaryNode, from, to string, list *[]*Appointment) {
+	if t == nil {
+		return
+	}
+	if from <= t.Key {
+		searchRange(t.Left, from, to, list)
+	}
+	if t.Key >= from && t.Key <= to {
+		*list = append(*list, t.Data.(*Appointment))
+	}
+	if t.Key <= to {
+		searchRange(t.Right, from, to, list)
+	}
+}
+
+// GetReport computes the report of appointments scheduled from date to date inclusive for all dentists,
+// appointments before today (now) are past appointments.
+func GetReport(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *BinarySearchTree, rota *Rota, calendar *Calendar, cancellationLog *CancellationLog, dentists []*user.User, from, to, now time.Time) *Report {
+	sessionCount := (**appointmentSessionList).GetSize()
+	report := &Report{
+		From: from.Format("2006-01-02"),
+		To:   to.Format("2006-01-02"),
+	}
+	today := now.Format("2006-01-02")
+
+	// Sessions available for each dentist
+	dentistReports := make(map[string]*DentistReport)
+	for _, dentist := range dentists {
+		dentistReport := &DentistReport{
+			Dentist:   dentist.Username,
+			Name:      dentist.FirstName + " " + dentist.LastName,
+			BySession: make([]UsageReport, sessionCount),
+			ByWeekday: make([]UsageReport, len(Weekdays)),
+		}
+		for i := range dentistReport.BySession {
+			dentistReport.BySession[i].Label = (**appointmentSessionList).Get(i + 1).(AppSession).StartTime
+		}
+		for i, day := range Weekdays {
+			dentistReport.ByWeekday[i].Label = day.String()
+		}
+		for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+			if calendar.IsClosed(date.Format("2006-01-02")) {
+				continue
+			}
+			for session := 1; session <= sessionCount; session++ {
+				if rota.IsWorking(dentist.Username, date, session) {
+					dentistReport.BySession[session-1].Available++
+					dentistReport.ByWeekday[getWeekdayIndex(date.Weekday())].Available++
+					dentistReport.Total.Available++
+				}
+			}
+		}
+		dentistReports[dentist.Username] = dentistReport
+		report.Dentists = append(report.Dentists, dentistReport)
+	}
+
+	// Sessions booked, attendance and busiest slots
+	type slotKey struct {
+		weekday time.Weekday
+		session int
+	}
+	slots := make(map[slotKey]int)
+	patients := make(map[string]bool)
+	for _, v := range appointmentTree.GetAppointmentsInRange(report.From, report.To) {
+		report.Appointments++
+		if v.Date < today {
+			report.PastAppointments++
+			if v.NoShow {
+				report.NoShows++
+			}
+		}
+		if patient, ok := v.Patient.(*user.User); ok {
+			patients[patient.Username] = true
+		}
+		date, err := time.Parse("2006-01-02", v.Date)
+		if err != nil {
+			continue
+		}
+		weekday := getWeekdayIndex(date.Weekday())
+		var dentistReport *DentistReport
+		if dentist, ok := v.Dentist.(*user.User); ok {
+			dentistReport = dentistReports[dentist.Username]
+		}
+		if dentistReport != nil {
+			dentistReport.Appointments++
+		}
+		for session := v.Session; session <= v.GetLastSession() && session <= sessionCount; session++ {
+			slots[slotKey{date.Weekday(), session}]++
+			if dentistReport != nil {
+				dentistReport.BySession[session-1].Booked++
+				dentistReport.ByWeekday[weekday].Booked++
+				dentistReport.Total.Booked++
+			}
+		}
+	}
+	for _, v := range report.Dentists {
+		v.Total.Rate = getRate(v.Total.Booked, v.Total.Available)
+		for i := range v.BySession {
+			v.BySession[i].Rate = getRate(v.BySession[i].Booked, v.BySession[i].Available)
+		}
+		for i := range v.ByWeekday {
+			v.ByWeekday[i].Rate = getRate(v.ByWeekday[i].Booked, v.ByWeekday[i].Available)
+		}
+	}
+
+	report.Cancellations = len(cancellationLog.GetCancellations(report.From, report.To))
+	report.CancellationRate = getRate(report.Cancellations, report.Appointments+report.Cancellations)
+	report.NoShowRate = getRate(report.NoShows, report.PastAppointments)
+
+	// Patients with an appointment before the range are returning patients
+	for _, v := range appointmentTree.GetAppointmentsInRange("", from.AddDate(0, 0, -1).Format("2006-01-02")) {
+		if patient, ok := v.Patient.(*user.User); ok {
+			delete(patients, patient.Username)
+		}
+	}
+	report.NewPatients = len(patients)
+
+	keys := make([]slotKey, 0, len(slots))
+	for k := range slots {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if slots[a] != slots[b] {
+			return slots[a] > slots[b]
+		}
+		if a.weekday != b.weekday {
+			return getWeekdayIndex(a.weekday) < getWeekdayIndex(b.weekday)
+		}
+		return a.session < b.session
+	})
+	report.BusiestSlots = []SlotReport{}
+	for _, k := range keys {
+		report.BusiestSlots = append(report.BusiestSlots, SlotReport{Weekday: k.weekday.String(), Session: k.session, Appointments: slots[k]})
+	}
+	if len(report.BusiestSlots) > busiestSlotCount {
+		report.BusiestSlots = report.BusiestSlots[:busiestSlotCount]
+	}
+	return report
+}
+
+// getRate returns count over total, 0 if total is 0.
+func getRate(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+// getWeekdayIndex returns the position of a weekday in Weekdays, Monday is 0.
+func getWeekdayIndex(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
@@ -0,0 +1,67 @@
+package appointment
+
+import (
+	"testing"
+	"time"
+
+	bst "github.com/shiweii/binarysearchtree"
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/user"
+)
+
+func TestGetAppointmentsInRange(t *testing.T) {
+	appointmentTree := &BinarySearchTree{bst.New()}
+	for k, v := range []string{"2022-06-08", "2022-06-01", "2022-06-15", "2022-06-08", "2022-06-10"} {
+		appointmentTree.Add(v, New(k, "", "", v, 1))
+	}
+	got := appointmentTree.GetAppointmentsInRange("2022-06-08", "2022-06-10")
+	res := []string{"2022-06-08", "2022-06-08", "2022-06-10"}
+	if len(got) != len(res) {
+		t.Fatalf("GetAppointmentsInRange() = %d appointments; want %d", len(got), len(res))
+	}
+	for k, v := range res {
+		if got[k].Date != v {
+			t.Errorf("GetAppointmentsInRange()[%d].Date = %v; want %v", k, got[k].Date, v)
+		}
+	}
+}
+
+func TestGetReport(t *testing.T) {
+	patient1 := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	patient2 := user.New("patient2", "", "patient", "Rory", "Williams", 98765432)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	sessionList := dll.New()
+	sessionList.Add(AppSession{Num: 1, StartTime: "09:00", EndTime: "10:00"})
+	sessionList.Add(AppSession{Num: 2, StartTime: "10:00", EndTime: "11:00"})
+
+	appointmentTree := &BinarySearchTree{bst.New()}
+	appointmentTree.Add("2022-05-30", New(1, patient1, dentist, "2022-05-30", 1))
+	noShow := New(2, patient1, dentist, "2022-06-06", 1)
+	noShow.NoShow = true
+	appointmentTree.Add(noShow.Date, noShow)
+	long := New(3, patient2, dentist, "2022-06-07", 1)
+	long.Duration = 2
+	appointmentTree.Add(long.Date, long)
+
+	cancellationLog := NewCancellationLog("")
+	cancellationLog.Record(Event{Type: EventCancelled, Appointment: New(4, patient2, dentist, "2022-06-08", 1), Time: time.Now()})
+	cancellationLog.Record(Event{Type: EventCancelled, Appointment: New(5, patient2, dentist, "2022-07-08", 1), Time: time.Now()})
+	rota := NewRota()
+	calendar := NewCalendar()
+	calendar.Add("2022-06-09", "Holiday")
+
+	from := time.Date(2022, 6, 6, 0, 0, 0, 0, time.UTC)
+	got := GetReport(&sessionList, appointmentTree, rota, calendar, cancellationLog, []*user.User{dentist}, from, from.AddDate(0, 0, 6), time.Date(2022, 6, 10, 0, 0, 0, 0, time.UTC))
+	if got.Appointments != 2 || got.NoShows != 1 || got.NoShowRate != 0.5 || got.Cancellations != 1 || got.NewPatients != 1 {
+		t.Errorf("GetReport() = %+v; want 2 appointments, 1 no-show, 1 cancellation and 1 new patient", got)
+	}
+	if len(got.Dentists) != 1 || got.Dentists[0].Total.Booked != 3 || got.Dentists[0].Total.Available != 8 {
+		t.Fatalf("GetReport().Dentists = %+v; want 3 of 8 sessions booked", got.Dentists)
+	}
+	if tuesday := got.Dentists[0].ByWeekday[1]; tuesday.Booked != 2 || tuesday.Rate != 1 {
+		t.Errorf("GetReport().Dentists[0].ByWeekday[1] = %+v; want 2 of 2 sessions booked", tuesday)
+	}
+	if len(got.BusiestSlots) != 3 || got.BusiestSlots[0].Weekday != "Monday" || got.BusiestSlots[0].Session != 1 {
+		t.Errorf("GetReport().BusiestSlots = %+v; want Monday session 1 first", got.BusiestSlots)
+	}
+}
@@ -0,0 +1,267 @@
+package appointment
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shiweii/config"
+	"github.com/shiweii/logger"
+	"github.com/shiweii/user"
+)
+
+// BookingRules struct stores the configuration of the rules engine.
+// A zero value disables the rule, except for MinLeadTime where slots which already started are always rejected.
+type BookingRules struct {
+	MinLeadTime         time.Duration
+	MaxBookingHorizon   int // in days
+	MaxActivePerPatient int
+	NoDoubleBooking     bool
+	CancellationCutOff  time.Duration
+}
+
+// BookingRequest struct stores the details of an appointment to be created or changed.
+// Existing is the appointment being changed and is nil when a new appointment is created.
+// Type is nil for an appointment without type which takes a single session.
+type BookingRequest struct {
+	Patient  *user.User
+	Dentist  *user.User
+	Date     string
+	Session  AppSession
+	Type     *AppointmentType
+	Existing *Appointment
+	Now      time.Time
+}
+
+// RuleViolation is returned when a booking request breaks a booking rule.
+type RuleViolation struct {
+	Rule   string
+	Reason string
+}
+
+// BookingRule is implemented by any rule evaluated against a booking request.
+type BookingRule interface {
+	Evaluate(req *BookingRequest, appointmentTree *BinarySearchTree) *RuleViolation
+}
+
+// RulesEngine evaluates booking requests and cancellations against the configured rules.
+type RulesEngine struct {
+	mu     sync.RWMutex
+	config BookingRules
+	rules  []BookingRule
+	added  []BookingRule
+}
+
+// GetBookingRules returns the booking rules of the current configuration.
+func GetBookingRules() BookingRules {
+	booking := config.Get().Booking
+	return BookingRules{
+		MinLeadTime:         booking.MinLeadTime,
+		MaxBookingHorizon:   booking.MaxHorizonDays,
+		MaxActivePerPatient: booking.MaxActivePerPatient,
+		NoDoubleBooking:     booking.NoDoubleBooking,
+		CancellationCutOff:  booking.CancellationCutOff,
+	}
+}
+
+// NewRulesEngine will return a newly created instance of a rules engine using the given configuration.
+func NewRulesEngine(rules BookingRules) *RulesEngine {
+	engine := &RulesEngine{}
+	engine.SetConfig(rules)
+	return engine
+}
+
+// SetConfig replaces the configuration of the rules engine, rules added by AddRule are kept.
+func (engine *RulesEngine) SetConfig(config BookingRules) {
+	rules := []BookingRule{appointmentTypeRule{}, minLeadTimeRule{config.MinLeadTime}}
+	if config.MaxBookingHorizon > 0 {
+		rules = append(rules, maxBookingHorizonRule{config.MaxBookingHorizon})
+	}
+	if config.MaxActivePerPatient > 0 {
+		rules = append(rules, maxActivePerPatientRule{config.MaxActivePerPatient})
+	}
+	if config.NoDoubleBooking {
+		rules = append(rules, noDoubleBookingRule{})
+	}
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	engine.config, engine.rules = config, rules
+}
+
+// AddRule adds a rule to be evaluated against booking requests.
+func (engine *RulesEngine) AddRule(rule BookingRule) {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	engine.added = append(engine.added, rule)
+}
+
+// GetConfig returns the configuration of the rules engine.
+func (engine *RulesEngine) GetConfig() BookingRules {
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+	return engine.config
+}
+
+// Evaluate checks booking request against all rules and returns the rules violated.
+// Appointments are read while holding bookingMutex, CreateNewAppointment and CreateNewSeries
+// evaluate the rules again in the same step as the booking.
+func (engine *RulesEngine) Evaluate(req *BookingRequest, appointmentTree *BinarySearchTree) []*RuleViolation {
+	bookingMutex.Lock()
+	defer bookingMutex.Unlock()
+	return engine.evaluate(req, appointmentTree)
+}
+
+// evaluate is the unlocked version of Evaluate, caller must hold bookingMutex.
+func (engine *RulesEngine) evaluate(req *BookingRequest, appointmentTree *BinarySearchTree) []*RuleViolation {
+	engine.mu.RLock()
+	rules := append(append([]BookingRule{}, engine.rules...), engine.added...)
+	engine.mu.RUnlock()
+	var violations []*RuleViolation
+	for _, rule := range rules {
+		if v := rule.Evaluate(req, appointmentTree); v != nil {
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+// CanCancel checks if an appointment can still be cancelled or changed by a user according to the cancellation cut-off.
+// Admin is exempted from the cut-off to cancel or change appointments on behalf of patients,
+// appointments which already started cannot be cancelled or changed by any user.
+func (engine *RulesEngine) CanCancel(appointment *Appointment, session AppSession, by *user.User, now time.Time) *RuleViolation {
+	startTime, err := GetStartTime(appointment.Date, session)
+	if err != nil {
+		logger.Error.Println(err)
+		return &RuleViolation{"cancellationCutOff", "Appointment date is invalid."}
+	}
+	cutOff := engine.GetConfig().CancellationCutOff
+	if by != nil && by.Role == "admin" {
+		cutOff = 0
+	}
+	if now.Add(cutOff).After(startTime) {
+		if cutOff == 0 {
+			return &RuleViolation{"cancellationCutOff", "Appointment has already started."}
+		}
+		return &RuleViolation{"cancellationCutOff", fmt.Sprintf("Appointment can only be cancelled or changed at least %v before the appointment.", FormatDuration(cutOff))}
+	}
+	return nil
+}
+
+// Error returns the reason of the violation.
+func (v *RuleViolation) Error() string {
+	return v.Reason
+}
+
+// GetStartTime returns the start time of a session on a given date (YYYY-MM-DD) in local time.
+func GetStartTime(date string, session AppSession) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04", date+" "+session.StartTime, time.Local)
+}
+
+// FormatDuration formats a duration into days, hours or minutes.
+func FormatDuration(d time.Duration) string {
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %v", n, unit)
+		}
+		return fmt.Sprintf("%d %vs", n, unit)
+	}
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return plural(int64(d/(24*time.Hour)), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return plural(int64(d/time.Hour), "hour")
+	default:
+		return plural(int64(d/time.Minute), "minute")
+	}
+}
+
+// appointmentTypeRule rejects appointment types not performed by the dentist.
+type appointmentTypeRule struct{}
+
+// Evaluate checks that the dentist performs the appointment type.
+func (r appointmentTypeRule) Evaluate(req *BookingRequest, _ *BinarySearchTree) *RuleViolation {
+	if req.Type != nil && !req.Type.AllowsDentist(req.Dentist.Username) {
+		return &RuleViolation{"appointmentType", fmt.Sprintf("Dr. %v %v does not perform %v.", req.Dentist.FirstName, req.Dentist.LastName, req.Type.Name)}
+	}
+	return nil
+}
+
+// minLeadTimeRule rejects slots which starts within the minimum lead time.
+type minLeadTimeRule struct {
+	leadTime time.Duration
+}
+
+// Evaluate checks that the appointment starts after the minimum lead time.
+func (r minLeadTimeRule) Evaluate(req *BookingRequest, _ *BinarySearchTree) *RuleViolation {
+	startTime, err := GetStartTime(req.Date, req.Session)
+	if err != nil {
+		return &RuleViolation{"minLeadTime", "Appointment date is invalid."}
+	}
+	if !startTime.After(req.Now.Add(r.leadTime)) {
+		if r.leadTime == 0 {
+			return &RuleViolation{"minLeadTime", "Appointment slot has already started."}
+		}
+		return &RuleViolation{"minLeadTime", fmt.Sprintf("Appointment must be booked at least %v in advance.", FormatDuration(r.leadTime))}
+	}
+	return nil
+}
+
+// maxBookingHorizonRule rejects slots which are too far ahead.
+type maxBookingHorizonRule struct {
+	days int
+}
+
+// Evaluate checks that the appointment date is within the booking horizon.
+func (r maxBookingHorizonRule) Evaluate(req *BookingRequest, _ *BinarySearchTree) *RuleViolation {
+	lastDate := req.Now.AddDate(0, 0, r.days).Format("2006-01-02")
+	if req.Date > lastDate {
+		return &RuleViolation{"maxBookingHorizon", fmt.Sprintf("Appointment can only be booked up to %d days in advance.", r.days)}
+	}
+	return nil
+}
+
+// maxActivePerPatientRule limits the number of upcoming appointments a patient can hold.
+type maxActivePerPatientRule struct {
+	max int
+}
+
+// Evaluate checks the number of upcoming appointments of the patient,
+// the appointment being changed is not counted and a recurring series is counted once.
+func (r maxActivePerPatientRule) Evaluate(req *BookingRequest, appointmentTree *BinarySearchTree) *RuleViolation {
+	var upcoming []*Appointment
+	count := 0
+	series := make(map[int]bool)
+	appointmentTree.searchAppointments(appointmentTree.GetRootNode(), req.Now.Format("2006-01-02"), req.Patient, "patient", &upcoming)
+	for _, v := range upcoming {
+		if req.Existing != nil && (v.ID == req.Existing.ID || (v.SeriesID != 0 && v.SeriesID == req.Existing.SeriesID)) {
+			continue
+		}
+		if v.SeriesID != 0 {
+			if series[v.SeriesID] {
+				continue
+			}
+			series[v.SeriesID] = true
+		}
+		count++
+	}
+	if count >= r.max {
+		return &RuleViolation{"maxActivePerPatient", fmt.Sprintf("You have reached the maximum of %d upcoming appointments.", r.max)}
+	}
+	return nil
+}
+
+// noDoubleBookingRule rejects patient from booking more than one appointment in the same session.
+type noDoubleBookingRule struct{}
+
+// Evaluate checks that patient does not have another appointment on the same date in any of the sessions taken.
+func (r noDoubleBookingRule) Evaluate(req *BookingRequest, appointmentTree *BinarySearchTree) *RuleViolation {
+	for _, v := range appointmentTree.GetAppointmentByDate(req.Date, "patient", req.Patient) {
+		if req.Existing != nil && v.ID == req.Existing.ID {
+			continue
+		}
+		if v.Overlaps(req.Session.Num, req.Type.GetDuration()) {
+			return &RuleViolation{"noDoubleBooking", "You already have another appointment in the same session."}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package appointment
+
+import (
+	"testing"
+	"time"
+
+	bst "github.com/shiweii/binarysearchtree"
+	"github.com/shiweii/user"
+)
+
+func TestRulesEngineEvaluate(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	appointmentTree := &BinarySearchTree{bst.New()}
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.Local)
+	existing := New(1, patient, dentist, "2022-06-10", 1)
+	appointmentTree.Add(existing.Date, existing)
+	appointmentTree.Add("2022-06-11", New(2, patient, dentist, "2022-06-11", 1))
+
+	engine := NewRulesEngine(BookingRules{
+		MinLeadTime:         2 * time.Hour,
+		MaxBookingHorizon:   30,
+		MaxActivePerPatient: 2,
+		NoDoubleBooking:     true,
+		CancellationCutOff:  24 * time.Hour,
+	})
+
+	tests := []struct {
+		name     string
+		date     string
+		session  AppSession
+		existing *Appointment
+		res      []string
+	}{
+		{"slot already started", "2022-06-01", AppSession{Num: 1, StartTime: "09:00"}, existing, []string{"minLeadTime"}},
+		{"within lead time", "2022-06-01", AppSession{Num: 3, StartTime: "11:00"}, existing, []string{"minLeadTime"}},
+		{"beyond horizon", "2022-07-15", AppSession{Num: 1, StartTime: "09:00"}, existing, []string{"maxBookingHorizon"}},
+		{"too many active", "2022-06-12", AppSession{Num: 1, StartTime: "09:00"}, nil, []string{"maxActivePerPatient"}},
+		{"double booking", "2022-06-11", AppSession{Num: 1, StartTime: "09:00"}, existing, []string{"noDoubleBooking"}},
+		{"valid change", "2022-06-12", AppSession{Num: 1, StartTime: "09:00"}, existing, nil},
+	}
+	for _, tt := range tests {
+		got := engine.Evaluate(&BookingRequest{
+			Patient:  patient,
+			Dentist:  dentist,
+			Date:     tt.date,
+			Session:  tt.session,
+			Existing: tt.existing,
+			Now:      now,
+		}, appointmentTree)
+		if len(got) != len(tt.res) {
+			t.Errorf("Evaluate(%v) = %d violations; want %d", tt.name, len(got), len(tt.res))
+			continue
+		}
+		for k, v := range got {
+			if v.Rule != tt.res[k] {
+				t.Errorf("Evaluate(%v) = %v; want %v", tt.name, v.Rule, tt.res[k])
+			}
+		}
+	}
+}
+
+func TestRulesEngineCanCancel(t *testing.T) {
+	engine := NewRulesEngine(BookingRules{CancellationCutOff: 24 * time.Hour})
+	appointment := New(1, nil, nil, "2022-06-10", 1)
+	session := AppSession{Num: 1, StartTime: "09:00"}
+
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	admin := user.New("admin", "", "admin", "Admin", "Admin", 0)
+
+	got := engine.CanCancel(appointment, session, patient, time.Date(2022, 6, 8, 9, 0, 0, 0, time.Local))
+	if got != nil {
+		t.Errorf("CanCancel(2 days before) = %v; want nil", got)
+	}
+
+	got = engine.CanCancel(appointment, session, patient, time.Date(2022, 6, 9, 12, 0, 0, 0, time.Local))
+	if got == nil {
+		t.Errorf("CanCancel(21 hours before) = nil; want violation")
+	}
+
+	// Admin is exempted from the cut-off but not after the appointment started
+	got = engine.CanCancel(appointment, session, admin, time.Date(2022, 6, 9, 12, 0, 0, 0, time.Local))
+	if got != nil {
+		t.Errorf("CanCancel(admin, 21 hours before) = %v; want nil", got)
+	}
+	got = engine.CanCancel(appointment, session, admin, time.Date(2022, 6, 10, 9, 30, 0, 0, time.Local))
+	if got == nil || got.Reason != "Appointment has already started." {
+		t.Errorf("CanCancel(admin, after start) = %v; want already started", got)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	got := FormatDuration(24 * time.Hour)
+	res := "1 day"
+	if got != res {
+		t.Errorf("FormatDuration(24h) = %v; want %v", got, res)
+	}
+
+	got = FormatDuration(2 * time.Hour)
+	res = "2 hours"
+	if got != res {
+		t.Errorf("FormatDuration(2h) = %v; want %v", got, res)
+	}
+
+	got = FormatDuration(90 * time.Minute)
+	res = "90 minutes"
+	if got != res {
+		t.Errorf("FormatDuration(90m) = %v; want %v", got, res)
+	}
+}
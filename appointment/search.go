@@ -0,0 +1,111 @@
+package appointment
+
+import (
+	"sort"
+	"time"
+
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/user"
+)
+
+// Time of day filters, sessions are matched using their start time.
+const (
+	TimeOfDayAny       = ""
+	TimeOfDayMorning   = "morning"
+	TimeOfDayAfternoon = "afternoon"
+	TimeOfDayEvening   = "evening"
+)
+
+// SlotQuery struct stores the filters used to search for open slots.
+// Empty Dentists, Weekdays or TimeOfDay matches all values.
+type SlotQuery struct {
+	Dentists  []*user.User
+	FromDate  time.Time
+	ToDate    time.Time
+	Weekdays  []time.Weekday
+	TimeOfDay string
+	Limit     int
+	// Branch is the code of the branch a chair must be available at, empty for any branch
+	Branch string
+	// Duration is the number of consecutive sessions required, 0 for a single session
+	Duration int
+	// Now and MinLeadTime excludes slots which starts too soon to be booked
+	Now         time.Time
+	MinLeadTime time.Duration
+	// Exclude is called for every open slot found, slot is skipped when it returns true
+	Exclude func(dentist *user.User, date string, session AppSession) bool
+}
+
+// OpenSlot struct stores an available session of a dentist on a date.
+type OpenSlot struct {
+	Dentist   *user.User
+	Date      string
+	Session   AppSession
+	StartTime time.Time
+	// Booked is the number of appointments the dentist has on the date, used for ranking
+	Booked int
+}
+
+// MatchTimeOfDay checks if a session starts within a time of day.
+func MatchTimeOfDay(session AppSession, timeOfDay string) bool {
+	switch timeOfDay {
+	case TimeOfDayMorning:
+		return session.StartTime < "12:00"
+	case TimeOfDayAfternoon:
+		return session.StartTime >= "12:00" && session.StartTime < "17:00"
+	case TimeOfDayEvening:
+		return session.StartTime >= "17:00"
+	default:
+		return true
+	}
+}
+
+// FindOpenSlots searches all dentists in the query for open slots between FromDate and ToDate (inclusive).
+// Slots are ranked by start time, dentists with fewer appointments on the same day are ranked first
+// so that bookings are spread across dentists.
+func FindOpenSlots(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *BinarySearchTree, rota *Rota, calendar *Calendar, clinics Clinics, query SlotQuery) []OpenSlot {
+	var slots []OpenSlot
+	weekdays := make(map[time.Weekday]bool)
+	for _, v := range query.Weekdays {
+		weekdays[v] = true
+	}
+	earliest := query.Now.Add(query.MinLeadTime)
+	for date := query.FromDate; !date.After(query.ToDate); date = date.AddDate(0, 0, 1) {
+		if len(weekdays) > 0 && !weekdays[date.Weekday()] {
+			continue
+		}
+		formattedDate := date.Format("2006-01-02")
+		if calendar.IsClosed(formattedDate) {
+			continue
+		}
+		for _, dentist := range query.Dentists {
+			booked := len((*appointmentTree).GetAppointmentByDate(formattedDate, dentist.Role, dentist))
+			for _, session := range GetDentistAvailability(appointmentSessionList, appointmentTree, rota, calendar, clinics, date, dentist, query.Branch, query.Duration) {
+				if !session.Available || !MatchTimeOfDay(session, query.TimeOfDay) {
+					continue
+				}
+				startTime, err := GetStartTime(formattedDate, session)
+				if err != nil || !startTime.After(earliest) {
+					continue
+				}
+				if query.Exclude != nil && query.Exclude(dentist, formattedDate, session) {
+					continue
+				}
+				slots = append(slots, OpenSlot{dentist, formattedDate, session, startTime, booked})
+			}
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		if !slots[i].StartTime.Equal(slots[j].StartTime) {
+			return slots[i].StartTime.Before(slots[j].StartTime)
+		}
+		if slots[i].Booked != slots[j].Booked {
+			return slots[i].Booked < slots[j].Booked
+		}
+		return slots[i].Dentist.Username < slots[j].Dentist.Username
+	})
+	if query.Limit > 0 && len(slots) > query.Limit {
+		slots = slots[:query.Limit]
+	}
+	return slots
+}
@@ -0,0 +1,63 @@
+package appointment
+
+import (
+	"testing"
+	"time"
+
+	bst "github.com/shiweii/binarysearchtree"
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/user"
+)
+
+func TestFindOpenSlots(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist1 := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	dentist2 := user.New("dentist2", "", "dentist", "Naomi", "Nagata", 0)
+	sessionList := dll.New()
+	sessionList.Add(AppSession{1, "09:00", "10:00", true})
+	sessionList.Add(AppSession{2, "14:00", "15:00", true})
+	appointmentTree := &BinarySearchTree{bst.New()}
+	// 2022-06-06 is a Monday
+	appointmentTree.Add("2022-06-06", New(1, patient, dentist1, "2022-06-06", 1))
+	appointmentTree.Add("2022-06-07", New(2, patient, dentist2, "2022-06-07", 2))
+	rota := NewRota()
+	calendar := NewCalendar()
+	calendar.Add("2022-06-08", "Holiday")
+
+	query := SlotQuery{
+		Dentists: []*user.User{dentist1, dentist2},
+		FromDate: time.Date(2022, 6, 6, 0, 0, 0, 0, time.UTC),
+		ToDate:   time.Date(2022, 6, 12, 0, 0, 0, 0, time.UTC),
+		Now:      time.Date(2022, 6, 6, 8, 0, 0, 0, time.Local),
+		Limit:    3,
+	}
+	// dentist2 has fewer appointments on the day and is ranked first
+	got := FindOpenSlots(&sessionList, appointmentTree, rota, calendar, nil, query)
+	res := []struct {
+		dentist string
+		date    string
+		session int
+	}{
+		{"dentist2", "2022-06-06", 1},
+		{"dentist2", "2022-06-06", 2},
+		{"dentist1", "2022-06-06", 2},
+	}
+	if len(got) != len(res) {
+		t.Fatalf("FindOpenSlots() = %d slots; want %d", len(got), len(res))
+	}
+	for k, v := range res {
+		if got[k].Dentist.Username != v.dentist || got[k].Date != v.date || got[k].Session.Num != v.session {
+			t.Errorf("FindOpenSlots()[%d] = %v %v %v; want %v %v %v", k, got[k].Dentist.Username, got[k].Date, got[k].Session.Num, v.dentist, v.date, v.session)
+		}
+	}
+
+	// Wednesday afternoon falls on a holiday, next match is the following Wednesday
+	query.ToDate = time.Date(2022, 6, 20, 0, 0, 0, 0, time.UTC)
+	query.Weekdays = []time.Weekday{time.Wednesday}
+	query.TimeOfDay = TimeOfDayAfternoon
+	query.Dentists = []*user.User{dentist1}
+	got = FindOpenSlots(&sessionList, appointmentTree, rota, calendar, nil, query)
+	if len(got) != 1 || got[0].Date != "2022-06-15" || got[0].Session.Num != 2 {
+		t.Errorf("FindOpenSlots(Wednesday afternoon) = %v; want 2022-06-15 session 2", got)
+	}
+}
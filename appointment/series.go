@@ -0,0 +1,197 @@
+package appointment
+
+import (
+	"time"
+
+	"github.com/shiweii/user"
+	util "github.com/shiweii/utility"
+)
+
+// Limits of a recurring appointment series.
+const (
+	MaxSeriesInterval    = 12 // in weeks
+	MaxSeriesOccurrences = 12
+)
+
+// SeriesConflict struct stores an occurrence of a series which cannot be booked.
+type SeriesConflict struct {
+	Date   string
+	Reason string
+}
+
+// GetSeriesDates returns the dates (YYYY-MM-DD) of a series starting from a given date
+// and repeating every interval weeks.
+func GetSeriesDates(start time.Time, interval, occurrences int) []string {
+	var dates []string
+	for i := 0; i < occurrences; i++ {
+		dates = append(dates, start.AddDate(0, 0, 7*interval*i).Format("2006-01-02"))
+	}
+	return dates
+}
+
+// GetSeriesConflicts checks every date of a series against the same collision check used to create appointments.
+func (appBst *BinarySearchTree) GetSeriesConflicts(dates []string, session int, appointmentType *AppointmentType, dentist *user.User, branch string, rota *Rota, calendar *Calendar, clinics Clinics) []SeriesConflict {
+	bookingMutex.Lock()
+	defer bookingMutex.Unlock()
+	return appBst.getSeriesConflicts(dates, session, appointmentType, dentist, branch, rota, calendar, clinics)
+}
+
+// getSeriesConflicts is the unlocked version of GetSeriesConflicts, caller must hold bookingMutex.
+func (appBst *BinarySearchTree) getSeriesConflicts(dates []string, session int, appointmentType *AppointmentType, dentist *user.User, branch string, rota *Rota, calendar *Calendar, clinics Clinics) []SeriesConflict {
+	var conflicts []SeriesConflict
+	for _, date := range dates {
+		if reason := appBst.getSlotConflict(date, session, appointmentType.GetDuration(), dentist, branch, rota, calendar, clinics, nil); reason != "" {
+			conflicts = append(conflicts, SeriesConflict{date, reason})
+		}
+	}
+	return conflicts
+}
+
+// CreateNewSeries reserves all dates of a series with the same type, dentist and session of the booking request in a single step.
+// Booking rules of the request applies to the whole series, every other date breaking a rule of the engine is a conflict.
+// When any date conflicts no appointment is created and the conflicts are returned,
+// unless skipConflicts is set where only the open dates are booked.
+// An EventBooked event is published for every appointment created.
+func CreateNewSeries(seriesID int, dates []string, req *BookingRequest, branch string, engine *RulesEngine, appointmentTree *BinarySearchTree, rota *Rota, calendar *Calendar, clinics Clinics, skipConflicts bool) ([]*Appointment, []SeriesConflict) {
+	bookingMutex.Lock()
+	created, conflicts := appointmentTree.createNewSeries(seriesID, dates, req, branch, engine, rota, calendar, clinics, skipConflicts)
+	bookingMutex.Unlock()
+	for _, v := range created {
+		Publish(Event{Type: EventBooked, Appointment: v})
+	}
+	return created, conflicts
+}
+
+// createNewSeries is the unlocked version of CreateNewSeries, caller must hold bookingMutex.
+func (appBst *BinarySearchTree) createNewSeries(seriesID int, dates []string, req *BookingRequest, branch string, engine *RulesEngine, rota *Rota, calendar *Calendar, clinics Clinics, skipConflicts bool) ([]*Appointment, []SeriesConflict) {
+	var created []*Appointment
+	if violations := engine.evaluate(req, appBst); len(violations) > 0 {
+		return nil, []SeriesConflict{{req.Date, violations[0].Reason}}
+	}
+	conflicts := appBst.getSeriesConflicts(dates, req.Session.Num, req.Type, req.Dentist, branch, rota, calendar, clinics)
+	// Rules of every date are evaluated before any appointment of the series is created
+	for _, date := range dates {
+		if date == req.Date || isConflict(conflicts, date) {
+			continue
+		}
+		occurrence := *req
+		occurrence.Date = date
+		if violations := engine.evaluate(&occurrence, appBst); len(violations) > 0 {
+			conflicts = append(conflicts, SeriesConflict{date, violations[0].Reason})
+		}
+	}
+	if len(conflicts) > 0 && !skipConflicts {
+		return nil, conflicts
+	}
+	for _, date := range dates {
+		if isConflict(conflicts, date) {
+			continue
+		}
+		appointment := New(util.GenerateID(), req.Patient, req.Dentist, date, req.Session.Num)
+		appointment.SeriesID = seriesID
+		appointment.SetType(req.Type)
+		appointment.Clinic = branch
+		if !appBst.createAppointment(appointment, rota, calendar, clinics) {
+			continue
+		}
+		created = append(created, appointment)
+	}
+	return created, conflicts
+}
+
+// GetSeries returns all appointments of a series sorted by date.
+func (appBst *BinarySearchTree) GetSeries(seriesID int) []*Appointment {
+	var list []*Appointment
+	if seriesID == 0 {
+		return list
+	}
+	for _, v := range appBst.GetAllAppointments(nil, "") {
+		if v.SeriesID == seriesID {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// GetFollowing returns the appointment and all appointments after it in the same series.
+func (appBst *BinarySearchTree) GetFollowing(appointment *Appointment) []*Appointment {
+	var list = []*Appointment{appointment}
+	for _, v := range appBst.GetSeries(appointment.SeriesID) {
+		if v != appointment && v.Date >= appointment.Date {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// RescheduleSeries moves appointments of a series by a number of days to another dentist or session in a single step.
+// When any moved appointment conflicts no appointment is changed and the conflicts are returned.
+// Appointments keep their branch, chairs are reserved again as the sessions may have changed.
+// An EventRescheduled event is published for every appointment moved.
+func (appBst *BinarySearchTree) RescheduleSeries(appointments []*Appointment, days int, session int, dentist *user.User, rota *Rota, calendar *Calendar, clinics Clinics) ([]*Appointment, []SeriesConflict, error) {
+	bookingMutex.Lock()
+	rescheduled, conflicts, err := appBst.rescheduleSeries(appointments, days, session, dentist, rota, calendar, clinics)
+	bookingMutex.Unlock()
+	for k, v := range rescheduled {
+		Publish(Event{Type: EventRescheduled, Appointment: v, Previous: appointments[k]})
+	}
+	return rescheduled, conflicts, err
+}
+
+// rescheduleSeries is the unlocked version of RescheduleSeries, caller must hold bookingMutex.
+func (appBst *BinarySearchTree) rescheduleSeries(appointments []*Appointment, days int, session int, dentist *user.User, rota *Rota, calendar *Calendar, clinics Clinics) ([]*Appointment, []SeriesConflict, error) {
+	var (
+		conflicts   []SeriesConflict
+		rescheduled []*Appointment
+		chairs      = make(map[*Appointment]*Chair)
+	)
+	for _, v := range appointments {
+		date, err := time.Parse("2006-01-02", v.Date)
+		if err != nil {
+			return nil, nil, err
+		}
+		newDate := date.AddDate(0, 0, days).Format("2006-01-02")
+		// Slots taken by the appointments being moved are treated as open
+		if reason := appBst.getSlotConflict(newDate, session, v.GetDuration(), dentist, "", rota, calendar, nil, appointments); reason != "" {
+			conflicts = append(conflicts, SeriesConflict{newDate, reason})
+			continue
+		}
+		if clinics != nil {
+			clinic := clinics.GetForAppointment(v)
+			_, chair, reason := appBst.getResource(newDate, session, v.GetDuration(), dentist, clinic.Code, clinics, appointments)
+			if reason != "" {
+				conflicts = append(conflicts, SeriesConflict{newDate, reason})
+			}
+			chairs[v] = chair
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, conflicts, nil
+	}
+	for _, v := range appointments {
+		if err := appBst.deleteAppointment(v); err != nil {
+			return rescheduled, nil, err
+		}
+		date, _ := time.Parse("2006-01-02", v.Date)
+		appointment := New(util.GenerateID(), v.Patient, dentist, date.AddDate(0, 0, days).Format("2006-01-02"), session)
+		appointment.SeriesID = v.SeriesID
+		appointment.Type = v.Type
+		appointment.Duration = v.Duration
+		if chair := chairs[v]; chair != nil {
+			appointment.Clinic, appointment.Chair = clinics.GetForAppointment(v).Code, chair.Code
+		}
+		appBst.addAppointment(appointment)
+		rescheduled = append(rescheduled, appointment)
+	}
+	return rescheduled, nil, nil
+}
+
+// isConflict checks if a date is in the list of conflicts.
+func isConflict(conflicts []SeriesConflict, date string) bool {
+	for _, v := range conflicts {
+		if v.Date == date {
+			return true
+		}
+	}
+	return false
+}
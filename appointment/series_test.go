@@ -0,0 +1,184 @@
+package appointment
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	bst "github.com/shiweii/binarysearchtree"
+	"github.com/shiweii/user"
+)
+
+func TestGetSeriesDates(t *testing.T) {
+	got := GetSeriesDates(time.Date(2022, 6, 6, 0, 0, 0, 0, time.UTC), 2, 3)
+	res := []string{"2022-06-06", "2022-06-20", "2022-07-04"}
+	if len(got) != len(res) {
+		t.Fatalf("GetSeriesDates() = %v; want %v", got, res)
+	}
+	for k, v := range res {
+		if got[k] != v {
+			t.Errorf("GetSeriesDates()[%d] = %v; want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestGetSeriesConflicts(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	appointmentTree := &BinarySearchTree{bst.New()}
+	appointmentTree.Add("2022-06-13", New(1, patient, dentist, "2022-06-13", 1))
+	rota := NewRota()
+	calendar := NewCalendar()
+	calendar.Add("2022-06-20", "Holiday")
+
+	dates := []string{"2022-06-06", "2022-06-13", "2022-06-20", "2022-06-27"}
+	got := appointmentTree.GetSeriesConflicts(dates, 1, nil, dentist, "", rota, calendar, nil)
+	if len(got) != 2 || got[0].Date != "2022-06-13" || got[1].Date != "2022-06-20" {
+		t.Errorf("GetSeriesConflicts() = %v; want 2022-06-13 and 2022-06-20", got)
+	}
+	got = appointmentTree.GetSeriesConflicts(dates, 2, nil, dentist, "", rota, calendar, nil)
+	if len(got) != 1 || got[0].Date != "2022-06-20" {
+		t.Errorf("GetSeriesConflicts(session 2) = %v; want 2022-06-20", got)
+	}
+}
+
+func TestCreateNewSeries(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	rota := NewRota()
+	calendar := NewCalendar()
+	dates := []string{"2022-06-06", "2022-06-13", "2022-06-20"}
+
+	tests := []struct {
+		name          string
+		session       int
+		rules         BookingRules
+		skipConflicts bool
+		created       int
+		conflicts     int
+	}{
+		{"open dates", 2, BookingRules{}, false, 3, 0},
+		{"conflicts", 1, BookingRules{}, false, 0, 1},
+		{"skip conflicts", 1, BookingRules{}, true, 2, 1},
+		{"rule broken", 2, BookingRules{MaxBookingHorizon: 14}, false, 0, 1},
+		{"skip rule broken", 2, BookingRules{MaxBookingHorizon: 14}, true, 2, 1},
+		// Rules of the first date applies to the whole series
+		{"rule broken by first date", 2, BookingRules{MaxActivePerPatient: 1}, true, 0, 1},
+	}
+	for _, tt := range tests {
+		appointmentTree := &BinarySearchTree{bst.New()}
+		appointmentTree.Add("2022-06-13", New(1, patient, dentist, "2022-06-13", 1))
+		req := getTestBookingRequest(patient, dentist, dates[0], tt.session)
+		created, conflicts := CreateNewSeries(100, dates, req, "", NewRulesEngine(tt.rules), appointmentTree, rota, calendar, nil, tt.skipConflicts)
+		if len(created) != tt.created || len(conflicts) != tt.conflicts {
+			t.Errorf("CreateNewSeries(%v) = %d created, %d conflicts; want %d, %d", tt.name, len(created), len(conflicts), tt.created, tt.conflicts)
+		}
+		if n := len(appointmentTree.GetSeries(100)); n != tt.created {
+			t.Errorf("GetSeries() after CreateNewSeries(%v) = %d appointments; want %d", tt.name, n, tt.created)
+		}
+	}
+}
+
+func TestRescheduleSeries(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	rota := NewRota()
+	calendar := NewCalendar()
+	calendar.Add("2022-06-21", "Holiday")
+	dates := []string{"2022-06-06", "2022-06-13"}
+
+	tests := []struct {
+		name       string
+		days       int
+		session    int
+		conflicts  int
+		res        []string
+		resSession int
+	}{
+		{"next day", 1, 2, 0, []string{"2022-06-07", "2022-06-14"}, 2},
+		// Slots taken by the series itself are treated as open
+		{"next week", 7, 1, 0, []string{"2022-06-13", "2022-06-20"}, 1},
+		// Appointments are not changed when any date conflicts
+		{"booked", 0, 3, 1, dates, 1},
+		{"holiday", 8, 1, 1, dates, 1},
+	}
+	for _, tt := range tests {
+		appointmentTree := &BinarySearchTree{bst.New()}
+		appointmentTree.Add("2022-06-13", New(1, patient, dentist, "2022-06-13", 3))
+		series, _ := CreateNewSeries(100, dates, getTestBookingRequest(patient, dentist, dates[0], 1), "", NewRulesEngine(BookingRules{}), appointmentTree, rota, calendar, nil, false)
+		rescheduled, conflicts, err := appointmentTree.RescheduleSeries(series, tt.days, tt.session, dentist, rota, calendar, nil)
+		if err != nil || len(conflicts) != tt.conflicts {
+			t.Errorf("RescheduleSeries(%v) = %d conflicts, %v; want %d conflicts", tt.name, len(conflicts), err, tt.conflicts)
+			continue
+		}
+		if tt.conflicts > 0 && rescheduled != nil {
+			t.Errorf("RescheduleSeries(%v) = %d rescheduled; want none", tt.name, len(rescheduled))
+		}
+		got := appointmentTree.GetSeries(100)
+		if len(got) != len(tt.res) {
+			t.Errorf("GetSeries() after RescheduleSeries(%v) = %d appointments; want %d", tt.name, len(got), len(tt.res))
+			continue
+		}
+		for k, v := range got {
+			if v.Date != tt.res[k] || v.Session != tt.resSession {
+				t.Errorf("GetSeries()[%d] after RescheduleSeries(%v) = %v session %d; want %v session %d", k, tt.name, v.Date, v.Session, tt.res[k], tt.resSession)
+			}
+		}
+	}
+}
+
+func TestGetFollowing(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	rota := NewRota()
+	calendar := NewCalendar()
+	dates := []string{"2022-06-06", "2022-06-13", "2022-06-20", "2022-06-27"}
+
+	tests := []struct {
+		index int
+		res   []string
+	}{
+		{0, dates},
+		{2, []string{"2022-06-20", "2022-06-27"}},
+		{3, []string{"2022-06-27"}},
+	}
+	for _, tt := range tests {
+		appointmentTree := &BinarySearchTree{bst.New()}
+		appointmentTree.Add("2022-06-13", New(1, patient, dentist, "2022-06-13", 2))
+		series, _ := CreateNewSeries(100, dates, getTestBookingRequest(patient, dentist, dates[0], 1), "", NewRulesEngine(BookingRules{}), appointmentTree, rota, calendar, nil, false)
+		got := appointmentTree.GetFollowing(series[tt.index])
+		if len(got) != len(tt.res) {
+			t.Errorf("GetFollowing(%v) = %d appointments; want %d", dates[tt.index], len(got), len(tt.res))
+			continue
+		}
+		for k, v := range got {
+			if v.Date != tt.res[k] || v.SeriesID != 100 {
+				t.Errorf("GetFollowing(%v)[%d] = %v; want %v", dates[tt.index], k, v.Date, tt.res[k])
+			}
+		}
+
+		// Cancelling the following appointments keeps earlier appointments of the series
+		for _, v := range got {
+			if err := appointmentTree.DeleteAppointment(v, ""); err != nil {
+				t.Errorf("DeleteAppointment(%v) = %v; want nil", v.Date, err)
+			}
+		}
+		if n := len(appointmentTree.GetSeries(100)); n != tt.index {
+			t.Errorf("GetSeries() after cancelling following %v = %d appointments; want %d", dates[tt.index], n, tt.index)
+		}
+		if n := len(appointmentTree.GetAllAppointments(nil, "")); n != tt.index+1 {
+			t.Errorf("GetAllAppointments() after cancelling following %v = %d appointments; want %d", dates[tt.index], n, tt.index+1)
+		}
+	}
+}
+
+// getTestBookingRequest returns a booking request without appointment type made on 2022-06-01.
+func getTestBookingRequest(patient, dentist *user.User, date string, session int) *BookingRequest {
+	return &BookingRequest{
+		Patient: patient,
+		Dentist: dentist,
+		Date:    date,
+		Session: AppSession{Num: session, StartTime: fmt.Sprintf("%02d:00", 8+session)},
+		Now:     time.Date(2022, 6, 1, 10, 0, 0, 0, time.Local),
+	}
+}
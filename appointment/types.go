@@ -0,0 +1,103 @@
+package appointment
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/shiweii/config"
+	"github.com/shiweii/logger"
+)
+
+// AppointmentType struct stores a type of treatment and the number of consecutive sessions it takes.
+// Dentists holds the usernames of dentists performing the treatment, empty if all dentists.
+type AppointmentType struct {
+	Code     string   `json:"code"`
+	Name     string   `json:"name"`
+	Sessions int      `json:"sessions"`
+	Dentists []string `json:"dentists,omitempty"`
+}
+
+// AppointmentTypes stores all appointment types in the order displayed to users.
+type AppointmentTypes []*AppointmentType
+
+// defaultAppointmentTypes are used when appointment type data is not available.
+var defaultAppointmentTypes = AppointmentTypes{
+	{Code: "checkup", Name: "Check-up", Sessions: 1},
+	{Code: "cleaning", Name: "Cleaning", Sessions: 1},
+	{Code: "filling", Name: "Filling", Sessions: 2},
+	{Code: "rootcanal", Name: "Root Canal", Sessions: 3},
+}
+
+// GetAppointmentTypeData will open, read and unmarshal appointment type data from JSON file.
+func GetAppointmentTypeData() AppointmentTypes {
+	var types AppointmentTypes
+	JSONData, err := ioutil.ReadFile(config.Get().Data.AppointmentTypes)
+	if err != nil {
+		logger.Warning.Println(err)
+		return defaultAppointmentTypes
+	}
+	if err = json.Unmarshal(JSONData, &types); err != nil {
+		logger.Error.Println(err)
+		return defaultAppointmentTypes
+	}
+	for _, v := range types {
+		if v.Sessions < 1 {
+			v.Sessions = 1
+		}
+	}
+	return types
+}
+
+// Get returns the appointment type by code, nil if not found.
+func (types AppointmentTypes) Get(code string) *AppointmentType {
+	for _, v := range types {
+		if v.Code == code {
+			return v
+		}
+	}
+	return nil
+}
+
+// GetForDentist returns the appointment types performed by a dentist.
+func (types AppointmentTypes) GetForDentist(dentist string) AppointmentTypes {
+	var list AppointmentTypes
+	for _, v := range types {
+		if v.AllowsDentist(dentist) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// AllowsDentist checks if dentist performs the treatment.
+func (t *AppointmentType) AllowsDentist(dentist string) bool {
+	if len(t.Dentists) == 0 {
+		return true
+	}
+	for _, v := range t.Dentists {
+		if v == dentist {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDuration returns the number of sessions taken by the appointment type, 1 if type is nil.
+func (t *AppointmentType) GetDuration() int {
+	if t == nil || t.Sessions < 1 {
+		return 1
+	}
+	return t.Sessions
+}
+
+// GetForAppointment returns the type of an appointment, nil for appointments without type.
+// A type is created from the appointment's duration when its type is no longer offered or has changed duration.
+func (types AppointmentTypes) GetForAppointment(appointment *Appointment) *AppointmentType {
+	if appointment.Type == "" && appointment.GetDuration() == 1 {
+		return nil
+	}
+	if t := types.Get(appointment.Type); t != nil && t.GetDuration() == appointment.GetDuration() {
+		return t
+	}
+	return &AppointmentType{Code: appointment.Type, Name: appointment.Type, Sessions: appointment.GetDuration()}
+}
@@ -0,0 +1,58 @@
+package appointment
+
+import (
+	"testing"
+	"time"
+
+	bst "github.com/shiweii/binarysearchtree"
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/user"
+)
+
+func TestGetDentistAvailabilityDuration(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	sessionList := dll.New()
+	sessionList.Add(AppSession{1, "09:00", "10:00", true})
+	sessionList.Add(AppSession{2, "10:00", "11:00", true})
+	sessionList.Add(AppSession{3, "11:00", "12:00", true})
+	sessionList.Add(AppSession{4, "13:00", "14:00", true})
+	appointmentTree := &BinarySearchTree{bst.New()}
+	// Filling taking session 2 and 3
+	filling := New(1, patient, dentist, "2022-06-06", 2)
+	filling.Duration = 2
+	appointmentTree.Add(filling.Date, filling)
+	rota := NewRota()
+	calendar := NewCalendar()
+	date := time.Date(2022, 6, 6, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		duration int
+		res      []bool
+	}{
+		{1, []bool{true, false, false, true}},
+		{2, []bool{false, false, false, false}},
+	}
+	for _, tt := range tests {
+		got := GetDentistAvailability(&sessionList, appointmentTree, rota, calendar, nil, date, dentist, "", tt.duration)
+		for k, v := range tt.res {
+			if got[k].Available != v {
+				t.Errorf("GetDentistAvailability(duration %d)[session %d] = %v; want %v", tt.duration, k+1, got[k].Available, v)
+			}
+		}
+	}
+
+	got := GetDentistAvailability(&sessionList, appointmentTree, rota, calendar, nil, date.AddDate(0, 0, 1), dentist, "", 3)
+	if !got[0].Available || got[0].EndTime != "12:00" || got[2].Available {
+		t.Errorf("GetDentistAvailability(duration 3) = %v; want session 1 available until 12:00", got)
+	}
+
+	conflict := appointmentTree.GetSlotConflict("2022-06-06", 1, 2, dentist, "", rota, calendar, nil, nil)
+	if conflict == "" {
+		t.Errorf("GetSlotConflict(session 1, duration 2) = empty; want conflict")
+	}
+	conflict = appointmentTree.GetSlotConflict("2022-06-06", 1, 2, dentist, "", rota, calendar, nil, []*Appointment{filling})
+	if conflict != "" {
+		t.Errorf("GetSlotConflict(ignore filling) = %v; want empty", conflict)
+	}
+}
@@ -0,0 +1,235 @@
+package appointment
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/shiweii/config"
+	"github.com/shiweii/integrity"
+	"github.com/shiweii/logger"
+	util "github.com/shiweii/utility"
+)
+
+// WaitlistEntry struct stores a patient's interest in a dentist within a date range.
+// Offer is the slot currently held for the patient, slots which were offered
+// but not booked before the hold expired are kept in Expired and will not be offered again.
+type WaitlistEntry struct {
+	ID        int         `json:"id"`
+	Patient   string      `json:"patient"`
+	Dentist   string      `json:"dentist"`
+	FromDate  string      `json:"fromDate"`
+	ToDate    string      `json:"toDate"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Offer     *SlotOffer  `json:"offer,omitempty"`
+	Expired   []SlotOffer `json:"expired,omitempty"`
+}
+
+// SlotOffer struct stores a freed slot held for a waitlisted patient until ExpiresAt.
+type SlotOffer struct {
+	Dentist   string    `json:"dentist"`
+	Date      string    `json:"date"`
+	Session   int       `json:"session"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Waitlist holds all waitlist entries in the order patients joined.
+// Waitlist is accessed by both request handlers and the offer expiry routine.
+type Waitlist struct {
+	mu      sync.Mutex
+	entries []*WaitlistEntry
+}
+
+// GetWaitlistData will open, read and unmarshal waitlist data from JSON file.
+func GetWaitlistData() *Waitlist {
+	waitlist := &Waitlist{}
+	JSONData, err := ioutil.ReadFile(config.Get().Data.Waitlist)
+	if err != nil {
+		logger.Warning.Println(err)
+		return waitlist
+	}
+	if err = json.Unmarshal(JSONData, &waitlist.entries); err != nil {
+		logger.Error.Println(err)
+	}
+	return waitlist
+}
+
+// GetWaitlistHoldDuration returns the duration a freed slot is held for a waitlisted patient.
+func GetWaitlistHoldDuration() time.Duration {
+	return config.Get().WaitlistHoldDuration
+}
+
+// saveWaitlistData will marshal and write all waitlist entries into JSON file.
+func (w *Waitlist) saveWaitlistData() {
+	JSONData, _ := json.MarshalIndent(w.entries, "", " ")
+	err := integrity.WriteFile(config.Get().Data.Waitlist, JSONData, 0644)
+	if err != nil {
+		logger.Error.Println(err)
+	}
+}
+
+// Join adds a patient into the waitlist of a dentist between two dates (YYYY-MM-DD).
+func (w *Waitlist) Join(patient, dentist, fromDate, toDate string, now time.Time) *WaitlistEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry := &WaitlistEntry{
+		ID:        util.GenerateID(),
+		Patient:   patient,
+		Dentist:   dentist,
+		FromDate:  fromDate,
+		ToDate:    toDate,
+		CreatedAt: now,
+	}
+	w.entries = append(w.entries, entry)
+	w.saveWaitlistData()
+	return entry
+}
+
+// Remove removes a waitlist entry by id, patient can only remove their own entry.
+// Entries of all patients can be removed when patient is empty.
+func (w *Waitlist) Remove(id int, patient string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for k, v := range w.entries {
+		if v.ID == id && (patient == "" || v.Patient == patient) {
+			w.entries = append(w.entries[:k], w.entries[k+1:]...)
+			w.saveWaitlistData()
+			return true
+		}
+	}
+	return false
+}
+
+// GetEntries returns a copy of waitlist entries of a patient,
+// entries of all patients are returned when patient is empty.
+func (w *Waitlist) GetEntries(patient string) []WaitlistEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var list []WaitlistEntry
+	for _, v := range w.entries {
+		if patient == "" || v.Patient == patient {
+			list = append(list, *v)
+		}
+	}
+	return list
+}
+
+// OfferSlot holds a freed slot for the earliest eligible waitlisted patient and returns the entry offered.
+// Nil is returned when there's no eligible patient or the slot is already held.
+func (w *Waitlist) OfferSlot(dentist, date string, session AppSession, now time.Time, hold time.Duration) *WaitlistEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if startTime, err := GetStartTime(date, session); err != nil || !startTime.After(now) {
+		return nil
+	}
+	if w.getHolder(dentist, date, session.Num, now) != nil {
+		return nil
+	}
+	for _, v := range w.entries {
+		if v.Dentist != dentist || v.Offer != nil || date < v.FromDate || date > v.ToDate || v.wasOffered(date, session.Num) {
+			continue
+		}
+		v.Offer = &SlotOffer{Dentist: dentist, Date: date, Session: session.Num, ExpiresAt: now.Add(hold)}
+		w.saveWaitlistData()
+		entry := *v
+		return &entry
+	}
+	return nil
+}
+
+// GetHolder returns the username of the patient holding a slot, empty if slot is not held.
+func (w *Waitlist) GetHolder(dentist, date string, session int, now time.Time) (string, time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if entry := w.getHolder(dentist, date, session, now); entry != nil {
+		return entry.Patient, entry.Offer.ExpiresAt
+	}
+	return "", time.Time{}
+}
+
+// getHolder returns the entry holding a slot.
+func (w *Waitlist) getHolder(dentist, date string, session int, now time.Time) *WaitlistEntry {
+	for _, v := range w.entries {
+		if v.Offer != nil && v.Offer.Dentist == dentist && v.Offer.Date == date && v.Offer.Session == session && v.Offer.ExpiresAt.After(now) {
+			return v
+		}
+	}
+	return nil
+}
+
+// Fulfil removes the patient's entry when the slot offered was booked.
+func (w *Waitlist) Fulfil(patient, dentist, date string, session int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for k, v := range w.entries {
+		if v.Patient == patient && v.Offer != nil && v.Offer.Dentist == dentist && v.Offer.Date == date && v.Offer.Session == session {
+			w.entries = append(w.entries[:k], w.entries[k+1:]...)
+			w.saveWaitlistData()
+			return
+		}
+	}
+}
+
+// ExpireOffers releases all offers which hold has expired and returns the released slots,
+// entries which date range has passed are removed from the waitlist.
+func (w *Waitlist) ExpireOffers(now time.Time) []SlotOffer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var (
+		released []SlotOffer
+		entries  []*WaitlistEntry
+		changed  bool
+	)
+	today := now.Format("2006-01-02")
+	for _, v := range w.entries {
+		if v.Offer != nil && !v.Offer.ExpiresAt.After(now) {
+			released = append(released, *v.Offer)
+			v.Expired = append(v.Expired, *v.Offer)
+			v.Offer = nil
+			changed = true
+		}
+		if v.ToDate < today && v.Offer == nil {
+			changed = true
+			continue
+		}
+		entries = append(entries, v)
+	}
+	if changed {
+		w.entries = entries
+		w.saveWaitlistData()
+	}
+	return released
+}
+
+// wasOffered checks if a slot was previously offered to the entry.
+func (e *WaitlistEntry) wasOffered(date string, session int) bool {
+	for _, v := range e.Expired {
+		if v.Date == date && v.Session == session {
+			return true
+		}
+	}
+	return false
+}
+
+// waitlistHoldRule rejects booking of slots which are held for another waitlisted patient.
+type waitlistHoldRule struct {
+	waitlist *Waitlist
+}
+
+// NewWaitlistHoldRule will return a booking rule which enforce holds of the waitlist.
+func NewWaitlistHoldRule(waitlist *Waitlist) BookingRule {
+	return waitlistHoldRule{waitlist}
+}
+
+// Evaluate checks that none of the sessions taken are held for another patient.
+func (r waitlistHoldRule) Evaluate(req *BookingRequest, _ *BinarySearchTree) *RuleViolation {
+	for session := req.Session.Num; session < req.Session.Num+req.Type.GetDuration(); session++ {
+		holder, expiresAt := r.waitlist.GetHolder(req.Dentist.Username, req.Date, session, req.Now)
+		if holder != "" && holder != req.Patient.Username {
+			return &RuleViolation{"waitlistHold", fmt.Sprintf("Appointment slot is on hold for a patient on the waiting list until %v.", expiresAt.Format("02-Jan-2006 15:04"))}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package appointment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shiweii/user"
+)
+
+func TestWaitlistOfferSlot(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.Local)
+	session := AppSession{Num: 1, StartTime: "09:00"}
+	waitlist := &Waitlist{}
+	waitlist.Join("patient1", "dentist1", "2022-06-01", "2022-06-30", now)
+	waitlist.Join("patient2", "dentist2", "2022-06-01", "2022-06-30", now)
+	waitlist.Join("patient3", "dentist1", "2022-06-15", "2022-06-30", now)
+	waitlist.Join("patient4", "dentist1", "2022-06-01", "2022-06-30", now)
+
+	tests := []struct {
+		name    string
+		dentist string
+		date    string
+		session AppSession
+		res     string
+	}{
+		{"first entry of dentist", "dentist1", "2022-06-10", session, "patient1"},
+		{"slot already held", "dentist1", "2022-06-10", session, ""},
+		{"next entry in date range", "dentist1", "2022-06-10", AppSession{Num: 2, StartTime: "10:00"}, "patient4"},
+		{"entries holding offers skipped", "dentist1", "2022-06-20", session, "patient3"},
+		{"no eligible entry", "dentist1", "2022-06-20", AppSession{Num: 2, StartTime: "10:00"}, ""},
+		{"slot already started", "dentist2", "2022-06-01", session, ""},
+		{"other dentist", "dentist2", "2022-06-10", session, "patient2"},
+	}
+	for _, tt := range tests {
+		got := waitlist.OfferSlot(tt.dentist, tt.date, tt.session, now, time.Hour)
+		switch {
+		case got == nil && tt.res != "":
+			t.Errorf("OfferSlot(%v) = nil; want %v", tt.name, tt.res)
+		case got != nil && got.Patient != tt.res:
+			t.Errorf("OfferSlot(%v) = %v; want %q", tt.name, got.Patient, tt.res)
+		case got != nil && !got.Offer.ExpiresAt.Equal(now.Add(time.Hour)):
+			t.Errorf("OfferSlot(%v).Offer.ExpiresAt = %v; want %v", tt.name, got.Offer.ExpiresAt, now.Add(time.Hour))
+		}
+	}
+}
+
+func TestWaitlistExpireOffers(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.Local)
+	session := AppSession{Num: 1, StartTime: "09:00"}
+	waitlist := &Waitlist{}
+	waitlist.Join("patient1", "dentist1", "2022-06-01", "2022-06-30", now)
+	waitlist.Join("patient2", "dentist1", "2022-06-01", "2022-06-30", now)
+	waitlist.Join("patient3", "dentist1", "2022-06-01", "2022-06-01", now)
+
+	if got := waitlist.OfferSlot("dentist1", "2022-06-10", session, now, time.Hour); got == nil || got.Patient != "patient1" {
+		t.Fatalf("OfferSlot() = %v; want patient1", got)
+	}
+	if got := waitlist.ExpireOffers(now.Add(30 * time.Minute)); len(got) != 0 {
+		t.Errorf("ExpireOffers() before hold expires = %v; want none", got)
+	}
+
+	tests := []struct {
+		now      time.Time
+		released int
+		res      string
+		entries  int
+	}{
+		// Hold expires and the slot is offered to the next entry
+		{now.Add(time.Hour), 1, "patient2", 3},
+		// Entries which date range has passed are removed, all entries were offered the slot
+		{now.Add(26 * time.Hour), 1, "", 2},
+	}
+	for _, tt := range tests {
+		released := waitlist.ExpireOffers(tt.now)
+		if len(released) != tt.released {
+			t.Fatalf("ExpireOffers(%v) = %v; want %d released", tt.now, released, tt.released)
+		}
+		if holder, _ := waitlist.GetHolder("dentist1", "2022-06-10", 1, tt.now); holder != "" {
+			t.Errorf("GetHolder(%v) = %v; want released", tt.now, holder)
+		}
+		got := waitlist.OfferSlot(released[0].Dentist, released[0].Date, session, tt.now, time.Hour)
+		switch {
+		case got == nil && tt.res != "":
+			t.Errorf("OfferSlot(%v) = nil; want %v", tt.now, tt.res)
+		case got != nil && got.Patient != tt.res:
+			t.Errorf("OfferSlot(%v) = %v; want %q", tt.now, got.Patient, tt.res)
+		}
+		if n := len(waitlist.GetEntries("")); n != tt.entries {
+			t.Errorf("GetEntries() after ExpireOffers(%v) = %d entries; want %d", tt.now, n, tt.entries)
+		}
+	}
+}
+
+func TestWaitlistFulfil(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.Local)
+	session := AppSession{Num: 1, StartTime: "09:00"}
+	waitlist := &Waitlist{}
+	waitlist.Join("patient1", "dentist1", "2022-06-01", "2022-06-30", now)
+	waitlist.Join("patient2", "dentist1", "2022-06-01", "2022-06-30", now)
+	waitlist.OfferSlot("dentist1", "2022-06-10", session, now, time.Hour)
+
+	tests := []struct {
+		patient string
+		date    string
+		session int
+		res     []string
+	}{
+		// Slot booked was not offered to the patient
+		{"patient2", "2022-06-10", 1, []string{"patient1", "patient2"}},
+		{"patient1", "2022-06-10", 2, []string{"patient1", "patient2"}},
+		{"patient1", "2022-06-10", 1, []string{"patient2"}},
+	}
+	for _, tt := range tests {
+		waitlist.Fulfil(tt.patient, "dentist1", tt.date, tt.session)
+		got := waitlist.GetEntries("")
+		if len(got) != len(tt.res) {
+			t.Errorf("GetEntries() after Fulfil(%v, %v, %d) = %d entries; want %d", tt.patient, tt.date, tt.session, len(got), len(tt.res))
+			continue
+		}
+		for k, v := range got {
+			if v.Patient != tt.res[k] {
+				t.Errorf("GetEntries()[%d] after Fulfil(%v, %v, %d) = %v; want %v", k, tt.patient, tt.date, tt.session, v.Patient, tt.res[k])
+			}
+		}
+	}
+}
+
+func TestWaitlistHoldRule(t *testing.T) {
+	patient := user.New("patient1", "", "patient", "Amy", "Pond", 91234567)
+	other := user.New("patient2", "", "patient", "Rory", "Williams", 98765432)
+	dentist := user.New("dentist1", "", "dentist", "James", "Holden", 0)
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.Local)
+	waitlist := &Waitlist{}
+	waitlist.Join("patient1", "dentist1", "2022-06-01", "2022-06-30", now)
+	waitlist.OfferSlot("dentist1", "2022-06-10", AppSession{Num: 2, StartTime: "10:00"}, now, time.Hour)
+	rule := NewWaitlistHoldRule(waitlist)
+
+	tests := []struct {
+		name     string
+		patient  *user.User
+		session  AppSession
+		apptType *AppointmentType
+		now      time.Time
+		res      bool
+	}{
+		{"holder", patient, AppSession{Num: 2, StartTime: "10:00"}, nil, now, false},
+		{"other patient", other, AppSession{Num: 2, StartTime: "10:00"}, nil, now, true},
+		{"other session", other, AppSession{Num: 1, StartTime: "09:00"}, nil, now, false},
+		{"covers held session", other, AppSession{Num: 1, StartTime: "09:00"}, &AppointmentType{Sessions: 2}, now, true},
+		{"hold expired", other, AppSession{Num: 2, StartTime: "10:00"}, nil, now.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		got := rule.Evaluate(&BookingRequest{
+			Patient: tt.patient,
+			Dentist: dentist,
+			Date:    "2022-06-10",
+			Session: tt.session,
+			Type:    tt.apptType,
+			Now:     tt.now,
+		}, nil)
+		if (got != nil) != tt.res {
+			t.Errorf("Evaluate(%v) = %v; want violation %t", tt.name, got, tt.res)
+		}
+	}
+}
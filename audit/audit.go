@@ -0,0 +1,398 @@
+// Package audit is an append-only log of administrative and data-changing actions.
+// Entries are written as JSON lines and chained by HMAC, every entry stores the hash of
+// the previous entry so that changing or removing an entry breaks the chain from that entry onwards.
+// The sequence and hash of the last entry are kept in a head file signed with the same key,
+// so that removing entries from the end of the log is also detected.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBrokenChain is returned when an entry was changed, removed or inserted.
+var ErrBrokenChain = errors.New("audit: hash chain is broken")
+
+// redacted replaces the values of redacted fields in changes.
+const redacted = "[redacted]"
+
+// headExt is appended to the path of the audit log to name its head file.
+const headExt = ".head"
+
+// Change struct stores the value of a field before and after an action, empty when the field was not set.
+type Change struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Entry struct stores an action performed by Actor on Target.
+// Hash is the HMAC-SHA256 of the entry without Hash, which includes the hash of the previous entry.
+type Entry struct {
+	Seq      int       `json:"seq"`
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor"`
+	Action   string    `json:"action"`
+	Target   string    `json:"target"`
+	Changes  []Change  `json:"changes,omitempty"`
+	IP       string    `json:"ip,omitempty"`
+	PrevHash string    `json:"prevHash"`
+	Hash     string    `json:"hash"`
+}
+
+// Filter struct stores the conditions to search entries, empty conditions match all entries.
+// Query matches the actor, action, target or any change of an entry.
+type Filter struct {
+	Actor  string
+	Action string
+	Target string
+	Query  string
+	From   time.Time
+	To     time.Time
+}
+
+// head struct stores the sequence and hash of the last entry, Signature is the HMAC of Seq and Hash.
+type head struct {
+	Seq       int    `json:"seq"`
+	Hash      string `json:"hash"`
+	Signature string `json:"signature"`
+}
+
+// Log holds all entries of the audit log, new entries are appended to the file at path.
+// Entries are kept in memory only when path is empty.
+type Log struct {
+	mu      sync.Mutex
+	path    string
+	key     []byte
+	entries []*Entry
+}
+
+// NewLog will return a newly created audit log appended to path, entries are chained by HMAC with key.
+func NewLog(path string, key []byte) *Log {
+	return &Log{path: path, key: key}
+}
+
+// LoadLog will open and read all entries of the audit log at path.
+func LoadLog(path string, key []byte) (*Log, error) {
+	log := NewLog(path, key)
+	entries, err := readEntries(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return log, nil
+	}
+	log.entries = entries
+	return log, err
+}
+
+// readEntries reads all entries of the audit log file at path.
+func readEntries(path string) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("audit: line %d: %w", line, err)
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Append adds an entry to the end of the audit log, the sequence, time and hashes of the entry are set.
+// The entry is only kept when it is written into the file.
+func (l *Log) Append(entry Entry) (*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.Seq, entry.PrevHash = 1, ""
+	if n := len(l.entries); n > 0 {
+		entry.Seq = l.entries[n-1].Seq + 1
+		entry.PrevHash = l.entries[n-1].Hash
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	entry.Hash = getHash(l.key, entry)
+
+	if l.path != "" {
+		JSONData, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		_, err = file.Write(append(JSONData, '\n'))
+		if err == nil {
+			err = file.Sync()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The entry is kept when only the head is not written, entries after the head are still chained
+		if err = l.writeHead(entry); err != nil {
+			l.entries = append(l.entries, &entry)
+			return &entry, err
+		}
+	}
+	l.entries = append(l.entries, &entry)
+	return &entry, nil
+}
+
+// getHash returns the hex encoded HMAC-SHA256 of an entry without its hash.
+func getHash(key []byte, entry Entry) string {
+	entry.Hash = ""
+	JSONData, _ := json.Marshal(entry)
+	h := hmac.New(sha256.New, key)
+	h.Write(JSONData)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// getSignature returns the hex encoded HMAC-SHA256 of the sequence and hash of a head.
+func (h head) getSignature(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d:%v", h.Seq, h.Hash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// writeHead replaces the head file with the sequence and hash of entry, the file is replaced
+// by renaming a temporary file so that the head is never partly written.
+func (l *Log) writeHead(entry Entry) error {
+	h := head{Seq: entry.Seq, Hash: entry.Hash}
+	h.Signature = h.getSignature(l.key)
+	JSONData, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+headExt+".*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(JSONData)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), l.path+headExt)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// readHead reads the head file of the audit log, returns nil when there is no head file.
+func (l *Log) readHead() (*head, error) {
+	JSONData, err := os.ReadFile(l.path + headExt)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var h head
+	if err = json.Unmarshal(JSONData, &h); err != nil {
+		return nil, fmt.Errorf("%w: head is not valid: %v", ErrBrokenChain, err)
+	}
+	return &h, nil
+}
+
+// Verify reads the audit log file again and checks the hash chain of all entries against the signed head,
+// returns the number of entries verified and ErrBrokenChain with the first entry which does not match
+// or when entries were removed from the end of the log.
+func (l *Log) Verify() (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.path == "" {
+		return VerifyEntries(l.entries, l.key)
+	}
+	entries, err := readEntries(l.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	n, err := VerifyEntries(entries, l.key)
+	if err != nil {
+		return n, err
+	}
+	h, err := l.readHead()
+	if err != nil {
+		return n, err
+	}
+	if h == nil {
+		if n > 0 {
+			return n, fmt.Errorf("%w: head was removed", ErrBrokenChain)
+		}
+		return n, nil
+	}
+	if !hmac.Equal([]byte(h.Signature), []byte(h.getSignature(l.key))) {
+		return n, fmt.Errorf("%w: head signature does not match", ErrBrokenChain)
+	}
+	// Entries after the head were appended when the head could not be written and are still chained
+	if h.Seq > n {
+		return n, fmt.Errorf("%w: entries after entry %d were removed", ErrBrokenChain, n)
+	}
+	if h.Seq > 0 && entries[h.Seq-1].Hash != h.Hash {
+		return h.Seq - 1, fmt.Errorf("%w at entry %d", ErrBrokenChain, h.Seq)
+	}
+	return n, nil
+}
+
+// VerifyEntries checks the hash chain of entries with key, returns the number of entries verified
+// and ErrBrokenChain with the first entry which does not match.
+func VerifyEntries(entries []*Entry, key []byte) (int, error) {
+	prevHash := ""
+	for k, v := range entries {
+		if v.Seq != k+1 || v.PrevHash != prevHash || !hmac.Equal([]byte(v.Hash), []byte(getHash(key, *v))) {
+			return k, fmt.Errorf("%w at entry %d", ErrBrokenChain, k+1)
+		}
+		prevHash = v.Hash
+	}
+	return len(entries), nil
+}
+
+// Search returns the entries matching filter, newest first.
+func (l *Log) Search(filter Filter) []*Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	query := strings.ToLower(filter.Query)
+	var list []*Entry
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		v := l.entries[i]
+		if (filter.Actor != "" && v.Actor != filter.Actor) || (filter.Action != "" && !strings.HasPrefix(v.Action, filter.Action)) ||
+			(filter.Target != "" && v.Target != filter.Target) || (!filter.From.IsZero() && v.Time.Before(filter.From)) ||
+			(!filter.To.IsZero() && !v.Time.Before(filter.To)) || (query != "" && !v.contains(query)) {
+			continue
+		}
+		list = append(list, v)
+	}
+	return list
+}
+
+// contains checks if the actor, action, target or any change of an entry contains query in lower case.
+func (e *Entry) contains(query string) bool {
+	values := []string{e.Actor, e.Action, e.Target, e.IP}
+	for _, v := range e.Changes {
+		values = append(values, v.Field, v.Before, v.After)
+	}
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetActions returns all actions in the audit log in alphabetical order.
+func (l *Log) GetActions() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	found := make(map[string]bool)
+	var list []string
+	for _, v := range l.entries {
+		if !found[v.Action] {
+			found[v.Action] = true
+			list = append(list, v.Action)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Diff returns the fields which are different between before and after, either may be nil for
+// created and deleted records. Values are compared by their JSON encoding, nested values are
+// compared as a whole. Values of fields in redact are never stored.
+func Diff(before, after interface{}, redact ...string) []Change {
+	beforeFields, afterFields := getFields(before), getFields(after)
+	var fields []string
+	for k := range beforeFields {
+		fields = append(fields, k)
+	}
+	for k := range afterFields {
+		if _, ok := beforeFields[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []Change
+	for _, field := range fields {
+		change := Change{Field: field, Before: beforeFields[field], After: afterFields[field]}
+		if change.Before == change.After {
+			continue
+		}
+		for _, v := range redact {
+			if v == field {
+				change.Before, change.After = redactValue(change.Before), redactValue(change.After)
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// getFields returns the fields of a JSON object as strings, values which are not JSON objects are stored as "value".
+func getFields(v interface{}) map[string]string {
+	fields := make(map[string]string)
+	if v == nil {
+		return fields
+	}
+	JSONData, err := json.Marshal(v)
+	if err != nil || string(JSONData) == "null" {
+		return fields
+	}
+	var object map[string]json.RawMessage
+	if err = json.Unmarshal(JSONData, &object); err != nil {
+		fields["value"] = getValue(JSONData)
+		return fields
+	}
+	for k, raw := range object {
+		fields[k] = getValue(raw)
+	}
+	return fields
+}
+
+// getValue returns a JSON value as string, strings are unquoted.
+func getValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// redactValue hides a value, empty values stay empty to show that the field was set or removed.
+func redactValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redacted
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKey is the HMAC key of audit logs in tests.
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log := NewLog(path, testKey)
+	for _, v := range []Entry{
+		{Actor: "admin", Action: "user.update", Target: "patient1", Changes: []Change{{Field: "firstName", Before: "Amy", After: "Amelia"}}},
+		{Actor: "admin", Action: "user.delete", Target: "patient2"},
+		{Actor: "patient1", Action: "appointment.create", Target: "42"},
+	} {
+		if _, err := log.Append(v); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	if n, err := log.Verify(); n != 3 || err != nil {
+		t.Fatalf("Verify() = %d, %v; want 3, nil", n, err)
+	}
+
+	// Entries appended after loading continue the chain
+	loaded, err := LoadLog(path, testKey)
+	if err != nil {
+		t.Fatalf("LoadLog() error = %v", err)
+	}
+	if entry, _ := loaded.Append(Entry{Actor: "admin", Action: "holiday.add", Target: "2022-08-09"}); entry.Seq != 4 {
+		t.Errorf("Append() after LoadLog() Seq = %d; want 4", entry.Seq)
+	}
+	if n, err := loaded.Verify(); n != 4 || err != nil {
+		t.Errorf("Verify() after LoadLog() = %d, %v; want 4, nil", n, err)
+	}
+
+	if got := loaded.Search(Filter{Action: "user."}); len(got) != 2 || got[0].Target != "patient2" {
+		t.Errorf("Search(user.) = %v; want 2 entries newest first", got)
+	}
+	if got := loaded.Search(Filter{Query: "amelia"}); len(got) != 1 {
+		t.Errorf("Search(amelia) = %d entries; want 1", len(got))
+	}
+
+	// Changing an entry in the file breaks the chain from that entry
+	JSONData, _ := os.ReadFile(path)
+	if err = os.WriteFile(path, []byte(strings.Replace(string(JSONData), "patient2", "patient3", 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := loaded.Verify(); n != 1 || !errors.Is(err, ErrBrokenChain) {
+		t.Errorf("Verify() after tampering = %d, %v; want 1, ErrBrokenChain", n, err)
+	}
+}
+
+func TestLogVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    []byte
+		change func(path string) error
+		res    int
+	}{
+		{"not changed", testKey, func(path string) error { return nil }, 3},
+		{"other key", []byte("fedcba9876543210fedcba9876543210"), func(path string) error { return nil }, 0},
+		// Entries removed from the end are still a valid chain and detected by the head
+		{"last entry removed", testKey, func(path string) error {
+			JSONData, _ := os.ReadFile(path)
+			lines := strings.SplitAfter(string(JSONData), "\n")
+			return os.WriteFile(path, []byte(strings.Join(lines[:2], "")), 0600)
+		}, 2},
+		{"all entries removed", testKey, func(path string) error { return os.WriteFile(path, nil, 0600) }, 0},
+		{"head removed", testKey, func(path string) error { return os.Remove(path + headExt) }, 3},
+		{"head changed", testKey, func(path string) error {
+			return os.WriteFile(path+headExt, []byte(`{"seq":2,"hash":"","signature":""}`), 0600)
+		}, 3},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		log := NewLog(path, testKey)
+		for _, target := range []string{"patient1", "patient2", "patient3"} {
+			if _, err := log.Append(Entry{Actor: "admin", Action: "user.delete", Target: target}); err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+		}
+		if err := tt.change(path); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadLog(path, tt.key)
+		if err != nil {
+			t.Fatalf("LoadLog(%v) error = %v", tt.name, err)
+		}
+		n, err := loaded.Verify()
+		if n != tt.res || (tt.name == "not changed") != (err == nil) || (err != nil && !errors.Is(err, ErrBrokenChain)) {
+			t.Errorf("Verify(%v) = %d, %v; want %d", tt.name, n, err, tt.res)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	type record struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Mobile   int    `json:"mobile,omitempty"`
+	}
+	got := Diff(record{"amy", "hash1", 0}, record{"amy", "hash2", 91234567}, "password")
+	want := []Change{{Field: "mobile", After: "91234567"}, {Field: "password", Before: redacted, After: redacted}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Diff() = %v; want %v", got, want)
+	}
+	if got = Diff(nil, record{Username: "amy"}, "password"); len(got) != 1 || got[0].After != "amy" {
+		t.Errorf("Diff(nil) = %v; want username only", got)
+	}
+}
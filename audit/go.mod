@@ -0,0 +1,3 @@
+module github.com/shiweii/audit
+
+go 1.18
@@ -56,7 +56,11 @@ func (bst *BinarySearchTree) insertNode(t **BinaryNode, key string, data interfa
 }
 
 // Remove wrapper function to remove application from the binary search tree.
+// An error is returned when the node is not in the binary search tree.
 func (bst *BinarySearchTree) Remove(removeNode *BinaryNode) error {
+	if removeNode == nil {
+		return errors.New("error: node not found")
+	}
 	var err error
 	bst.root, err = bst.removeNode(&bst.root, removeNode)
 	return err
@@ -66,31 +70,35 @@ func (bst *BinarySearchTree) Remove(removeNode *BinaryNode) error {
 // Case 1, node to be deleted has 0 child (is a leaf)
 // Case 2, node to be deleted has 1 child
 // Case 3, node to be deleted has 2 children
+// Nodes with the same key are inserted to the right, so the right subtree is searched until the node itself is found
+// and a node with 2 children is replaced by the smallest node of its right subtree to keep same keys on the right.
 func (bst *BinarySearchTree) removeNode(t **BinaryNode, removeNode *BinaryNode) (*BinaryNode, error) {
+	var err error
 	if *t == nil {
-		return nil, errors.New("error: tree is empty")
+		return nil, errors.New("error: node not found")
 	} else if removeNode.Key < (*t).Key {
-		(*t).Left, _ = bst.removeNode(&(*t).Left, removeNode)
-	} else if removeNode.Key > (*t).Key {
-		(*t).Right, _ = bst.removeNode(&(*t).Right, removeNode)
+		(*t).Left, err = bst.removeNode(&(*t).Left, removeNode)
+	} else if removeNode.Key > (*t).Key || *t != removeNode {
+		(*t).Right, err = bst.removeNode(&(*t).Right, removeNode)
 	} else {
 		if (*t).Left == nil {
 			return (*t).Right, nil
 		} else if (*t).Right == nil {
 			return (*t).Left, nil
 		} else { // 3rd case of 2 children
-			*t = bst.findSuccessor((*t).Left)
-			removeNode = *t
-			(*t).Left, _ = bst.removeNode(&(*t).Left, removeNode)
+			successor := bst.findSuccessor((*t).Right)
+			successor.Right, err = bst.removeNode(&(*t).Right, successor)
+			successor.Left = (*t).Left
+			*t = successor
 		}
 	}
-	return *t, nil
+	return *t, err
 }
 
-// findSuccessor find and return binary nodes next smaller value
+// findSuccessor find and return binary nodes next larger or equal value
 func (bst *BinarySearchTree) findSuccessor(t *BinaryNode) *BinaryNode {
-	for t.Right != nil { // Find node on extreme right
-		t = t.Right
+	for t.Left != nil { // Find node on extreme left
+		t = t.Left
 	}
 	return t
 }
@@ -0,0 +1,387 @@
+// Package config loads the configuration of the server once at startup into a typed struct.
+// Settings are read from the environment, the .env file and an optional YAML or TOML file declared by CONFIG_FILE,
+// in order of precedence, default values are used for settings not declared. Settings marked as reloadable are
+// applied by Reload without restarting the server, changes of other settings take effect after restart.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/shiweii/logger"
+)
+
+// Config struct stores the configuration of the server, fields are decoded from the setting named by the env tag.
+// Fields tagged as required must be declared, fields tagged with reload are applied by Reload.
+type Config struct {
+	ConfigFile string `env:"CONFIG_FILE"`
+	Port       string `env:"PORT" required:"true"`
+	SSLCert    string `env:"SSL_CERT" required:"true"`
+	SSLKey     string `env:"SSL_KEY" required:"true"`
+	Key        string `env:"KEY" required:"true"`
+	CookieName string `env:"COOKIE_NAME" required:"true"`
+
+	// ShutdownTimeout is how long requests in progress are waited for on shutdown
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	// MetricsToken is the bearer token required to scrape /metrics, metrics are public when empty
+	MetricsToken string `env:"METRICS_TOKEN" reload:"true"`
+	// AssetSource is where pages load Bootstrap from, cdn or local to serve the vendored copy embedded in the binary
+	AssetSource string `env:"ASSET_SOURCE" default:"cdn" reload:"true"`
+	// OverrideDir is the directory of templates and static files read on every request instead of the files
+	// embedded in the binary, so that changes are displayed without rebuilding during development
+	OverrideDir string `env:"OVERRIDE_DIR"`
+
+	Data         DataConfig
+	Log          LogConfig
+	Integrity    IntegrityConfig
+	Booking      BookingConfig
+	Notification NotificationConfig
+
+	WaitlistHoldDuration time.Duration `env:"WAITLIST_HOLD_DURATION" default:"2h" reload:"true"`
+	ReminderLeadTime     time.Duration `env:"REMINDER_LEAD_TIME" default:"24h" reload:"true"`
+}
+
+// DataConfig struct stores the paths of data files.
+type DataConfig struct {
+	Users                   string `env:"USER_DATA" required:"true"`
+	EncryptedUsers          string `env:"USER_DATA_ENCRYPT" required:"true"`
+	Appointments            string `env:"APPOINTMENT_DATA"`
+	Holidays                string `env:"HOLIDAY_DATA"`
+	Availability            string `env:"AVAILABILITY_DATA"`
+	Waitlist                string `env:"WAITLIST_DATA"`
+	AppointmentTypes        string `env:"APPOINTMENT_TYPE_DATA"`
+	Clinics                 string `env:"CLINIC_DATA"`
+	Reminders               string `env:"REMINDER_DATA"`
+	CalendarFeeds           string `env:"CALENDAR_FEED_DATA"`
+	Cancellations           string `env:"CANCELLATION_DATA"`
+	NotificationPreferences string `env:"NOTIFICATION_PREFERENCES"`
+	MessageTemplates        string `env:"MESSAGE_TEMPLATES"`
+	AuditLog                string `env:"AUDIT_LOG"`
+}
+
+// LogConfig struct stores the level, format and files of logs, MaxSize is in MB and MaxAge in days.
+type LogConfig struct {
+	Level          string        `env:"LOG_LEVEL" default:"trace" reload:"true"`
+	Format         string        `env:"LOG_FORMAT" default:"logfmt" reload:"true"`
+	Dir            string        `env:"LOG_DIR" default:"log"`
+	MaxSize        int64         `env:"LOG_MAX_SIZE"`
+	MaxAge         int           `env:"LOG_MAX_AGE"`
+	HMACKey        string        `env:"LOG_HMAC_KEY"`
+	VerifyInterval time.Duration `env:"LOG_VERIFY_INTERVAL" default:"10m"`
+}
+
+// IntegrityConfig struct stores the manifest, key and response to tampering of monitored files.
+type IntegrityConfig struct {
+	Manifest     string        `env:"INTEGRITY_MANIFEST"`
+	Key          string        `env:"INTEGRITY_KEY"`
+	Mode         string        `env:"INTEGRITY_MODE" default:"alert" reload:"true"`
+	ScanInterval time.Duration `env:"INTEGRITY_SCAN_INTERVAL" default:"5m"`
+}
+
+// BookingConfig struct stores the configuration of booking rules, MaxHorizonDays is in days.
+type BookingConfig struct {
+	MinLeadTime         time.Duration `env:"BOOKING_MIN_LEAD_TIME" default:"2h" reload:"true"`
+	MaxHorizonDays      int           `env:"BOOKING_MAX_HORIZON_DAYS" default:"180" reload:"true"`
+	MaxActivePerPatient int           `env:"BOOKING_MAX_ACTIVE_PER_PATIENT" default:"3" reload:"true"`
+	NoDoubleBooking     bool          `env:"BOOKING_NO_DOUBLE_BOOKING" default:"true" reload:"true"`
+	CancellationCutOff  time.Duration `env:"BOOKING_CANCELLATION_CUT_OFF" default:"24h" reload:"true"`
+}
+
+// NotificationConfig struct stores the SMS gateway, SMTP server and outbox files messages are sent through.
+type NotificationConfig struct {
+	SMSGatewayURL string `env:"SMS_GATEWAY_URL"`
+	SMSAPIKey     string `env:"SMS_API_KEY"`
+	SMSSender     string `env:"SMS_SENDER"`
+	SMSOutbox     string `env:"SMS_OUTBOX"`
+	SMTPHost      string `env:"SMTP_HOST"`
+	SMTPPort      string `env:"SMTP_PORT"`
+	SMTPUsername  string `env:"SMTP_USERNAME"`
+	SMTPPassword  string `env:"SMTP_PASSWORD"`
+	SMTPFrom      string `env:"SMTP_FROM"`
+	EmailOutbox   string `env:"EMAIL_OUTBOX"`
+}
+
+var (
+	mu       sync.RWMutex
+	current  = getDefault()
+	envFile  string
+	handlers []func(old, new *Config)
+)
+
+// Get returns the current configuration, the default configuration is returned before Load.
+// The configuration must not be modified, it is replaced as a whole by Reload.
+func Get() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Load reads and validates the configuration from the environment, the .env file at path and the
+// configuration file declared by CONFIG_FILE. A .env file which does not exist is skipped.
+func Load(path string) error {
+	config, err := Read(path)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current, envFile = config, path
+	return nil
+}
+
+// Reload reads the configuration again and applies the changed settings which are reloadable,
+// changes of other settings are logged and take effect after restart. The current configuration
+// is kept when the configuration is not valid. Functions added with OnReload are called on change.
+func Reload() error {
+	mu.RLock()
+	path := envFile
+	mu.RUnlock()
+	config, err := Read(path)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	old := current
+	next := *old
+	reloaded, restart := merge(reflect.ValueOf(&next).Elem(), reflect.ValueOf(config).Elem())
+	current = &next
+	list := handlers
+	mu.Unlock()
+
+	if len(restart) > 0 {
+		logger.Warning.Printf("Configuration reloaded, restart is required to apply %v.", strings.Join(restart, ", "))
+	}
+	if len(reloaded) > 0 {
+		logger.Info.Printf("Configuration reloaded, %v applied.", strings.Join(reloaded, ", "))
+		for _, handler := range list {
+			handler(old, &next)
+		}
+	}
+	return nil
+}
+
+// OnReload adds a function called with the previous and new configuration when settings are reloaded.
+func OnReload(handler func(old, new *Config)) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers = append(handlers, handler)
+}
+
+// Read returns the configuration read from the environment, the .env file at path and the configuration file
+// declared by CONFIG_FILE without changing the current configuration. Returns an error listing all invalid settings.
+func Read(path string) (*Config, error) {
+	dotenv := make(map[string]string)
+	if path != "" {
+		var err error
+		if dotenv, err = godotenv.Read(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("config: error reading %v: %v", path, err)
+		}
+	}
+	lookup := func(key string) (string, bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			return v, true
+		}
+		v, ok := dotenv[key]
+		return v, ok
+	}
+
+	var file map[string]string
+	if name, _ := lookup("CONFIG_FILE"); name != "" {
+		var err error
+		if file, err = readFile(name); err != nil {
+			return nil, err
+		}
+	}
+	config := &Config{}
+	errs := decode(reflect.ValueOf(config).Elem(), func(key string) (string, bool) {
+		if v, ok := lookup(key); ok {
+			return v, true
+		}
+		v, ok := file[key]
+		return v, ok
+	})
+	errs = append(errs, config.validate()...)
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("config: %v", strings.Join(errs, "; "))
+	}
+	return config, nil
+}
+
+// getDefault returns the configuration with default values only.
+func getDefault() *Config {
+	config := &Config{}
+	decode(reflect.ValueOf(config).Elem(), func(string) (string, bool) { return "", false })
+	return config
+}
+
+// decode sets the fields of a struct from the settings returned by lookup or the default values,
+// nested structs are decoded recursively. Returns the settings which could not be parsed.
+func decode(v reflect.Value, lookup func(key string) (string, bool)) []string {
+	var errs []string
+	for i := 0; i < v.NumField(); i++ {
+		field, tag := v.Field(i), v.Type().Field(i).Tag
+		key := tag.Get("env")
+		if key == "" {
+			if field.Kind() == reflect.Struct {
+				errs = append(errs, decode(field, lookup)...)
+			}
+			continue
+		}
+		value, ok := lookup(key)
+		if !ok || value == "" {
+			value = tag.Get("default")
+		}
+		if value == "" {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			errs = append(errs, fmt.Sprintf("%v is not valid: %v", key, err))
+		}
+	}
+	return errs
+}
+
+// setField parses value into a string, bool, integer or duration field.
+func setField(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Int || field.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported type %v", field.Type())
+	}
+	return nil
+}
+
+// merge copies the reloadable settings of next into current, returns the settings applied
+// and the settings which changed but require restart.
+func merge(current, next reflect.Value) ([]string, []string) {
+	var reloaded, restart []string
+	for i := 0; i < current.NumField(); i++ {
+		field, tag := current.Field(i), current.Type().Field(i).Tag
+		key := tag.Get("env")
+		if key == "" {
+			if field.Kind() == reflect.Struct {
+				r, s := merge(field, next.Field(i))
+				reloaded, restart = append(reloaded, r...), append(restart, s...)
+			}
+			continue
+		}
+		if reflect.DeepEqual(field.Interface(), next.Field(i).Interface()) {
+			continue
+		}
+		if tag.Get("reload") == "true" {
+			field.Set(next.Field(i))
+			reloaded = append(reloaded, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	return reloaded, restart
+}
+
+// validate checks required settings are declared and settings are within range, returns the invalid settings.
+func (c *Config) validate() []string {
+	var errs []string
+	checkRequired(reflect.ValueOf(c).Elem(), &errs)
+
+	if c.Port != "" {
+		if _, port, err := net.SplitHostPort(c.Port); err != nil {
+			errs = append(errs, fmt.Sprintf("PORT is not valid: %v", err))
+		} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			errs = append(errs, fmt.Sprintf("PORT is not valid: port %q is not between 1 and 65535", port))
+		}
+	}
+	for key, path := range map[string]string{"SSL_CERT": c.SSLCert, "SSL_KEY": c.SSLKey} {
+		if _, err := os.Stat(path); path != "" && err != nil {
+			errs = append(errs, fmt.Sprintf("%v is not valid: %v", key, err))
+		}
+	}
+	if c.OverrideDir != "" {
+		if info, err := os.Stat(filepath.Join(c.OverrideDir, "templates")); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Sprintf("OVERRIDE_DIR is not valid: %v has no templates directory", c.OverrideDir))
+		}
+	}
+	// Files are encrypted with AES-256
+	if c.Key != "" && len(c.Key) != 32 {
+		errs = append(errs, fmt.Sprintf("KEY must be 32 bytes, got %d bytes", len(c.Key)))
+	}
+	for key, value := range map[string]string{"LOG_HMAC_KEY": c.Log.HMACKey, "INTEGRITY_KEY": c.Integrity.Key} {
+		if value != "" && len(value) < 32 {
+			errs = append(errs, fmt.Sprintf("%v must be at least 32 bytes, got %d bytes", key, len(value)))
+		}
+	}
+
+	if _, err := logger.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Sprintf("LOG_LEVEL is not valid: %v", err))
+	}
+	if c.Log.Format != logger.FormatLogfmt && c.Log.Format != logger.FormatJSON {
+		errs = append(errs, fmt.Sprintf("LOG_FORMAT must be %v or %v", logger.FormatLogfmt, logger.FormatJSON))
+	}
+	if c.Integrity.Mode != "alert" && c.Integrity.Mode != "readonly" && c.Integrity.Mode != "refuse" {
+		errs = append(errs, "INTEGRITY_MODE must be alert, readonly or refuse")
+	}
+	if c.AssetSource != "cdn" && c.AssetSource != "local" {
+		errs = append(errs, "ASSET_SOURCE must be cdn or local")
+	}
+	for key, value := range map[string]int64{
+		"LOG_MAX_SIZE": c.Log.MaxSize, "LOG_MAX_AGE": int64(c.Log.MaxAge), "BOOKING_MAX_HORIZON_DAYS": int64(c.Booking.MaxHorizonDays),
+		"BOOKING_MAX_ACTIVE_PER_PATIENT": int64(c.Booking.MaxActivePerPatient), "BOOKING_MIN_LEAD_TIME": int64(c.Booking.MinLeadTime),
+		"BOOKING_CANCELLATION_CUT_OFF": int64(c.Booking.CancellationCutOff),
+	} {
+		if value < 0 {
+			errs = append(errs, fmt.Sprintf("%v must not be negative", key))
+		}
+	}
+	for key, value := range map[string]time.Duration{
+		"LOG_VERIFY_INTERVAL": c.Log.VerifyInterval, "INTEGRITY_SCAN_INTERVAL": c.Integrity.ScanInterval,
+		"WAITLIST_HOLD_DURATION": c.WaitlistHoldDuration, "REMINDER_LEAD_TIME": c.ReminderLeadTime,
+		"SHUTDOWN_TIMEOUT": c.ShutdownTimeout,
+	} {
+		if value <= 0 {
+			errs = append(errs, fmt.Sprintf("%v must be positive", key))
+		}
+	}
+	return errs
+}
+
+// checkRequired adds the required settings which are not declared into errs.
+func checkRequired(v reflect.Value, errs *[]string) {
+	for i := 0; i < v.NumField(); i++ {
+		field, tag := v.Field(i), v.Type().Field(i).Tag
+		if tag.Get("env") == "" && field.Kind() == reflect.Struct {
+			checkRequired(field, errs)
+		} else if tag.Get("required") == "true" && field.IsZero() {
+			*errs = append(*errs, fmt.Sprintf("%v is required", tag.Get("env")))
+		}
+	}
+}
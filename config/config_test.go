@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFiles writes a .env file with required settings and the given lines into dir, the certificate
+// and key files are created. Returns the path of the .env file.
+func writeFiles(t *testing.T, dir string, lines ...string) string {
+	cert, key := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	_ = os.WriteFile(cert, nil, 0644)
+	_ = os.WriteFile(key, nil, 0644)
+	env := append([]string{
+		"PORT=:5221",
+		"SSL_CERT=" + cert,
+		"SSL_KEY=" + key,
+		"KEY=0123456789abcdef0123456789abcdef",
+		"COOKIE_NAME=sessionID",
+		"USER_DATA=data/users.json",
+		"USER_DATA_ENCRYPT=data/users.bin",
+	}, lines...)
+	path := filepath.Join(dir, ".env")
+	if err := os.WriteFile(path, []byte(strings.Join(env, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("log:\n  level: info\n  format: json\nbooking:\n  max_horizon_days: 90\n"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "config.toml"), []byte("[booking]\nno_double_booking = false\nmin_lead_time = \"30m\"\n"), 0644)
+	t.Setenv("LOG_FORMAT", "logfmt")
+
+	// Environment takes precedence over .env which takes precedence over the configuration file
+	config, err := Read(writeFiles(t, dir, "CONFIG_FILE="+filepath.Join(dir, "config.yaml"), "LOG_LEVEL=warning"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Log.Level != "warning" || config.Log.Format != "logfmt" || config.Booking.MaxHorizonDays != 90 {
+		t.Errorf("got %+v, want level warning, format logfmt and horizon 90", config.Log)
+	}
+	if config.Booking.MaxActivePerPatient != 3 || config.ReminderLeadTime != 24*time.Hour || config.Log.Dir != "log" {
+		t.Errorf("got %+v, want default values", config)
+	}
+
+	config, err = Read(writeFiles(t, dir, "CONFIG_FILE="+filepath.Join(dir, "config.toml")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Booking.NoDoubleBooking || config.Booking.MinLeadTime != 30*time.Minute {
+		t.Errorf("got %+v, want no double booking disabled and lead time 30m", config.Booking)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Read(writeFiles(t, dir, "PORT=5221", "KEY=short", "SSL_KEY=missing.pem", "COOKIE_NAME=", "BOOKING_MIN_LEAD_TIME=2 hours", "INTEGRITY_MODE=off", "ASSET_SOURCE=s3", "OVERRIDE_DIR="+dir))
+	if err == nil {
+		t.Fatal("got no error, want invalid settings")
+	}
+	for _, v := range []string{"PORT", "KEY must be 32 bytes", "SSL_KEY", "COOKIE_NAME is required", "BOOKING_MIN_LEAD_TIME", "INTEGRITY_MODE", "ASSET_SOURCE", "OVERRIDE_DIR"} {
+		if !strings.Contains(err.Error(), v) {
+			t.Errorf("got %v, want %v", err, v)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFiles(t, dir, "LOG_LEVEL=info")
+	if err := Load(path); err != nil {
+		t.Fatal(err)
+	}
+	var reloaded *Config
+	OnReload(func(old, new *Config) {
+		reloaded = new
+	})
+
+	// Only reloadable settings are applied
+	writeFiles(t, dir, "LOG_LEVEL=error", "PORT=:8080")
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if config := Get(); config != reloaded || config.Log.Level != "error" || config.Port != ":5221" {
+		t.Errorf("got level %v and port %v, want level error and port :5221", config.Log.Level, config.Port)
+	}
+
+	// Invalid configuration is not applied
+	writeFiles(t, dir, "LOG_LEVEL=verbose")
+	if err := Reload(); err == nil || Get().Log.Level != "error" {
+		t.Errorf("got %v, want level error kept", err)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile reads the settings of a YAML (.yaml, .yml) or TOML (.toml) file. Nested keys are joined by
+// underscore in upper case to name settings, so that log.level and LOG_LEVEL declare the same setting.
+func readFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: error reading %v: %v", path, err)
+	}
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("config: %v is not a YAML or TOML file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: error parsing %v: %v", path, err)
+	}
+	settings := make(map[string]string)
+	flatten("", values, settings)
+	return settings, nil
+}
+
+// flatten adds the values of nested maps into settings named by the keys of all levels.
+func flatten(prefix string, values map[string]interface{}, settings map[string]string) {
+	for k, v := range values {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			flatten(key, nested, settings)
+		} else if v != nil {
+			settings[key] = fmt.Sprint(v)
+		}
+	}
+}
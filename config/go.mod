@@ -0,0 +1,12 @@
+module github.com/shiweii/config
+
+go 1.18
+
+require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/joho/godotenv v1.4.0
+	github.com/shiweii/logger v0.0.0-00010101000000-000000000000
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+replace github.com/shiweii/logger => ../logger
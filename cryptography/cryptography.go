@@ -8,6 +8,7 @@ import (
 	"crypto/rand"
 	"crypto/sha512"
 	"encoding/hex"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
@@ -110,3 +111,27 @@ func ComputeSHA512(file string) (string, error) {
 	}
 	return hex.EncodeToString(harsher.Sum(nil)), nil
 }
+
+// CheckKey checks that a file encrypted by EncryptFile is decrypted by the key without writing the plaintext.
+func CheckKey(envKey, path string) error {
+	ciphertext, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	block, err := aes.NewCipher([]byte(envKey))
+	if err != nil {
+		return err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return errors.New("cryptography: file is too short to be encrypted")
+	}
+	_, err = gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+	return err
+}
@@ -0,0 +1,49 @@
+// Package document renders printable documents as PDF.
+// Documents use the standard PDF fonts, text is converted from UTF-8 to the
+// Windows-1252 code page and characters outside of it are not printed.
+package document
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Page layout in millimetres.
+const (
+	pageMargin = 15.0
+	lineHeight = 6.0
+	fontFamily = "Helvetica"
+)
+
+// newPDF returns an A4 document with a footer showing the page number and time generated.
+func newPDF(title string, generated time.Time) *gofpdf.Fpdf {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin+5)
+	pdf.SetTitle(title, true)
+	pdf.SetCreator("Central City Dentist Clinic", true)
+	pdf.SetCreationDate(generated)
+	pdf.SetModificationDate(generated)
+	pdf.AliasNbPages("")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pageMargin)
+		pdf.SetFont(fontFamily, "I", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 5, tr(fmt.Sprintf("Generated %v", generated.Format("02 Jan 2006 15:04"))), "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+	return pdf
+}
+
+// output writes the document into w, returns the first error found while rendering.
+func output(pdf *gofpdf.Fpdf, w io.Writer) error {
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
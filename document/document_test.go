@@ -0,0 +1,66 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteRunSheets(t *testing.T) {
+	date := time.Date(2022, 6, 6, 0, 0, 0, 0, time.UTC)
+	var rows []RunSheetRow
+	for i := 0; i < 60; i++ {
+		rows = append(rows, RunSheetRow{Time: "09:00 - 10:00", Patient: fmt.Sprintf("Patient %d", i), MobileNumber: "91234567", Type: "Root canal treatment", Location: "Main Branch (Chair 1)"})
+	}
+	sheets := []RunSheet{
+		{Clinic: "Central City Dentist Clinic", Dentist: "James Holden", Date: date, Rows: rows},
+		{Clinic: "Central City Dentist Clinic", Dentist: "Naomi Nagata", Date: date},
+	}
+	var b bytes.Buffer
+	if err := WriteRunSheets(&b, sheets, date); err != nil {
+		t.Fatalf("WriteRunSheets() error = %v", err)
+	}
+	if !strings.HasPrefix(b.String(), "%PDF-") {
+		t.Fatalf("WriteRunSheets() does not write a PDF document")
+	}
+	// 60 rows need more than one page, the second run-sheet starts on a new page
+	if got := getPageCount(b.String()); got < 3 {
+		t.Errorf("WriteRunSheets() wrote %d pages; want at least 3", got)
+	}
+}
+
+func TestWriteLetters(t *testing.T) {
+	letters := []Letter{{
+		Clinic:    "Central City Dentist Clinic",
+		Address:   "1 Café Street",
+		Date:      time.Date(2022, 6, 6, 0, 0, 0, 0, time.UTC),
+		Recipient: "Amy Pond",
+		Reference: "12345",
+		Body:      "This letter confirms your appointment.",
+		Details:   []Detail{{"Date", "Monday, 13 June 2022"}, {"Dentist", "Dr. 李 Holden"}},
+	}}
+	var b bytes.Buffer
+	if err := WriteLetters(&b, letters, letters[0].Date); err != nil {
+		t.Fatalf("WriteLetters() error = %v", err)
+	}
+	if !strings.HasPrefix(b.String(), "%PDF-") {
+		t.Fatalf("WriteLetters() does not write a PDF document")
+	}
+	if got := getPageCount(b.String()); got != 1 {
+		t.Errorf("WriteLetters() wrote %d pages; want 1", got)
+	}
+}
+
+// getPageCount returns the number of pages in the page tree of a PDF document.
+func getPageCount(document string) int {
+	match := regexp.MustCompile(`/Count (\d+)`).FindStringSubmatch(document)
+	if match == nil {
+		return 0
+	}
+	count, _ := strconv.Atoi(match[1])
+	return count
+}
@@ -0,0 +1,5 @@
+module github.com/shiweii/document
+
+go 1.18
+
+require github.com/jung-kurt/gofpdf v1.16.2
@@ -0,0 +1,75 @@
+package document
+
+import (
+	"io"
+	"time"
+)
+
+// Letter struct stores an appointment confirmation letter addressed to a patient.
+// Details are printed as a table of labels and values below the body.
+type Letter struct {
+	Clinic    string
+	Address   string
+	Date      time.Time
+	Recipient string
+	Reference string
+	Body      string
+	Details   []Detail
+	Closing   string
+}
+
+// Detail struct stores a labelled value printed in a letter.
+type Detail struct {
+	Label string
+	Value string
+}
+
+// WriteLetters writes letters as a PDF document into w, every letter starts on a new page.
+// Generated is printed in the footer.
+func WriteLetters(w io.Writer, letters []Letter, generated time.Time) error {
+	pdf := newPDF("Appointment Letter", generated)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	for _, letter := range letters {
+		pdf.AddPage()
+		pdf.SetFont(fontFamily, "B", 18)
+		pdf.CellFormat(0, 9, tr(letter.Clinic), "", 1, "L", false, 0, "")
+		if letter.Address != "" {
+			pdf.SetFont(fontFamily, "", 10)
+			pdf.MultiCell(0, 5, tr(letter.Address), "", "L", false)
+		}
+		pdf.Ln(2)
+		pageWidth, _ := pdf.GetPageSize()
+		pdf.Line(pageMargin, pdf.GetY(), pageWidth-pageMargin, pdf.GetY())
+		pdf.Ln(8)
+
+		pdf.SetFont(fontFamily, "", 11)
+		pdf.CellFormat(0, lineHeight, letter.Date.Format("02 January 2006"), "", 1, "L", false, 0, "")
+		if letter.Reference != "" {
+			pdf.CellFormat(0, lineHeight, tr("Reference: "+letter.Reference), "", 1, "L", false, 0, "")
+		}
+		pdf.Ln(lineHeight)
+		pdf.CellFormat(0, lineHeight, tr("Dear "+letter.Recipient+","), "", 1, "L", false, 0, "")
+		pdf.Ln(2)
+		pdf.MultiCell(0, lineHeight, tr(letter.Body), "", "L", false)
+		pdf.Ln(4)
+
+		for _, v := range letter.Details {
+			pdf.SetFont(fontFamily, "B", 11)
+			pdf.CellFormat(45, lineHeight+1, tr(v.Label), "", 0, "L", false, 0, "")
+			pdf.SetFont(fontFamily, "", 11)
+			pdf.MultiCell(0, lineHeight+1, tr(v.Value), "", "L", false)
+		}
+		pdf.Ln(4)
+		if letter.Closing != "" {
+			pdf.MultiCell(0, lineHeight, tr(letter.Closing), "", "L", false)
+			pdf.Ln(lineHeight)
+		}
+		pdf.CellFormat(0, lineHeight, "Yours sincerely,", "", 1, "L", false, 0, "")
+		pdf.Ln(2)
+		pdf.CellFormat(0, lineHeight, tr(letter.Clinic), "", 1, "L", false, 0, "")
+	}
+	if len(letters) == 0 {
+		pdf.AddPage()
+	}
+	return output(pdf, w)
+}
@@ -0,0 +1,94 @@
+package document
+
+import (
+	"io"
+	"time"
+)
+
+// RunSheet struct stores the appointments of a dentist on a day in session order.
+type RunSheet struct {
+	Clinic  string
+	Dentist string
+	Date    time.Time
+	Rows    []RunSheetRow
+}
+
+// RunSheetRow struct stores an appointment printed on a run-sheet.
+type RunSheetRow struct {
+	Time         string
+	Patient      string
+	MobileNumber string
+	Type         string
+	Location     string
+	Notes        string
+}
+
+// runSheetColumns are the column titles and widths in millimetres, widths add up to the printable width.
+var runSheetColumns = []struct {
+	title string
+	width float64
+}{
+	{"Time", 25},
+	{"Patient", 40},
+	{"Mobile", 22},
+	{"Type", 30},
+	{"Location", 38},
+	{"Notes", 25},
+}
+
+// WriteRunSheets writes run-sheets as a PDF document into w, every run-sheet starts on a new page
+// and column titles are repeated on every page. Generated is printed in the footer.
+func WriteRunSheets(w io.Writer, sheets []RunSheet, generated time.Time) error {
+	pdf := newPDF("Daily Run-Sheet", generated)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	for _, sheet := range sheets {
+		writeHeader := func() {
+			pdf.SetFont(fontFamily, "B", 16)
+			pdf.CellFormat(0, 8, tr(sheet.Clinic), "", 1, "L", false, 0, "")
+			pdf.SetFont(fontFamily, "", 12)
+			pdf.CellFormat(0, 7, tr("Run-sheet for Dr. "+sheet.Dentist+", "+sheet.Date.Format("Monday, 02 January 2006")), "", 1, "L", false, 0, "")
+			pdf.Ln(3)
+			pdf.SetFont(fontFamily, "B", 9)
+			pdf.SetFillColor(230, 230, 230)
+			for _, v := range runSheetColumns {
+				pdf.CellFormat(v.width, lineHeight, v.title, "1", 0, "L", true, 0, "")
+			}
+			pdf.Ln(-1)
+			pdf.SetFont(fontFamily, "", 9)
+		}
+		pdf.AddPage()
+		writeHeader()
+		if len(sheet.Rows) == 0 {
+			pdf.CellFormat(0, lineHeight, "No appointments.", "1", 1, "C", false, 0, "")
+		}
+		_, pageHeight := pdf.GetPageSize()
+		_, _, _, bottomMargin := pdf.GetMargins()
+		for _, row := range sheet.Rows {
+			values := []string{row.Time, row.Patient, row.MobileNumber, row.Type, row.Location, row.Notes}
+			// Rows grow to fit wrapped text, a row is moved to a new page when it does not fit
+			lines := 1
+			for k, v := range values {
+				if n := len(pdf.SplitText(tr(v), runSheetColumns[k].width-2)); n > lines {
+					lines = n
+				}
+			}
+			height := float64(lines) * lineHeight
+			if pdf.GetY()+height > pageHeight-bottomMargin-5 {
+				pdf.AddPage()
+				writeHeader()
+			}
+			x, y := pdf.GetXY()
+			for k, v := range values {
+				pdf.Rect(x, y, runSheetColumns[k].width, height, "D")
+				pdf.MultiCell(runSheetColumns[k].width, lineHeight, tr(v), "", "L", false)
+				x += runSheetColumns[k].width
+				pdf.SetXY(x, y)
+			}
+			pdf.SetXY(pageMargin, y+height)
+		}
+	}
+	if len(sheets) == 0 {
+		pdf.AddPage()
+	}
+	return output(pdf, w)
+}
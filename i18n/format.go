@@ -0,0 +1,87 @@
+package i18n
+
+import (
+	"fmt"
+	"time"
+)
+
+// locale stores the name of a locale and the formats of dates, months and weekdays are displayed in dates,
+// date is formatted with the day, month and year as arguments.
+type locale struct {
+	name      string
+	date      string
+	months    [12]string
+	weekdays  [7]string
+	separator string
+}
+
+// locales stores the formats of all supported locales.
+var locales = map[string]*locale{
+	Default: {
+		name:      "English",
+		date:      "%02[1]d-%[2]v-%[3]d",
+		months:    [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		weekdays:  [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		separator: " ",
+	},
+	"zh-Hans": {
+		name:     "简体中文",
+		date:     "%[3]d年%[2]v月%[1]d日",
+		months:   [12]string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"},
+		weekdays: [7]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+	},
+	"ms": {
+		name:      "Bahasa Melayu",
+		date:      "%02[1]d-%[2]v-%[3]d",
+		months:    [12]string{"Jan", "Feb", "Mac", "Apr", "Mei", "Jun", "Jul", "Ogo", "Sep", "Okt", "Nov", "Dis"},
+		weekdays:  [7]string{"Ahad", "Isnin", "Selasa", "Rabu", "Khamis", "Jumaat", "Sabtu"},
+		separator: " ",
+	},
+}
+
+// getLocale returns the formats of locale, formats of Default are returned for unsupported locales.
+func getLocale(code string) *locale {
+	if l, ok := locales[code]; ok {
+		return l
+	}
+	return locales[Default]
+}
+
+// FormatDate returns a date (YYYY-MM-DD) in the format of locale, such as 02-Jan-2006 in English.
+// Invalid dates are returned as is.
+func FormatDate(locale, date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return formatDate(getLocale(locale), t)
+}
+
+// GetDay returns the weekday of a date (YYYY-MM-DD) in the language of locale, invalid dates are returned as is.
+func GetDay(locale, date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return Weekday(locale, t.Weekday())
+}
+
+// Weekday returns the name of a weekday in the language of locale.
+func Weekday(locale string, day time.Weekday) string {
+	return getLocale(locale).weekdays[day]
+}
+
+// FormatDateTime returns the date and time of t to the minute in the format of locale.
+func FormatDateTime(locale string, t time.Time) string {
+	return formatDate(getLocale(locale), t) + " " + t.Format("15:04")
+}
+
+// FormatTimestamp returns the date and time of t to the second in the format of locale.
+func FormatTimestamp(locale string, t time.Time) string {
+	return formatDate(getLocale(locale), t) + " " + t.Format("15:04:05")
+}
+
+// formatDate returns the date of t in the format of l.
+func formatDate(l *locale, t time.Time) string {
+	return fmt.Sprintf(l.date, t.Day(), l.months[t.Month()-1], t.Year())
+}
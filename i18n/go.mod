@@ -0,0 +1,5 @@
+module github.com/shiweii/i18n
+
+go 1.18
+
+require golang.org/x/text v0.3.7
@@ -0,0 +1,218 @@
+// Package i18n translates the messages of the web UI and formats dates for the locale of a user.
+// Messages are written in English and used as the keys of the message catalogs of other locales,
+// catalogs are JSON files in locales embedded into the package. Messages formatted in English by other
+// packages are translated by matching the formats of the catalog, so that only the message is translated
+// where it is displayed.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Default is the locale of messages in the source code, used when no supported locale is accepted.
+const Default = "en"
+
+// Locales are the codes of all supported locales, messages of Default are not translated.
+var Locales = []string{Default, "zh-Hans", "ms"}
+
+//go:embed locales/*.json
+var files embed.FS
+
+// catalog stores the translations of messages of a locale, formats are the messages with arguments
+// used to translate messages formatted in English, longest first.
+type catalog struct {
+	messages map[string]string
+	formats  []format
+}
+
+// format stores a message with arguments and the pattern matching the message formatted in English.
+type format struct {
+	message string
+	pattern *regexp.Regexp
+}
+
+// verb matches the verbs of arguments in messages, only %v is used so that arguments are able to be
+// formatted in any order with %[n]v in translations.
+var verb = regexp.MustCompile(`%(\[\d+\])?v`)
+
+var (
+	matcher  = newMatcher()
+	catalogs = loadCatalogs()
+)
+
+// newMatcher returns the matcher of the supported locales.
+func newMatcher() language.Matcher {
+	tags := make([]language.Tag, len(Locales))
+	for i, v := range Locales {
+		tags[i] = language.MustParse(v)
+	}
+	return language.NewMatcher(tags)
+}
+
+// loadCatalogs loads the catalogs of all locales except Default. Catalogs are embedded, invalid catalogs panic.
+// Weekdays in English are translated with the weekdays of the locale.
+func loadCatalogs() map[string]*catalog {
+	catalogs := map[string]*catalog{}
+	for _, locale := range Locales[1:] {
+		b, err := files.ReadFile(path.Join("locales", locale+".json"))
+		if err != nil {
+			panic(err)
+		}
+		c := &catalog{}
+		if err := json.Unmarshal(b, &c.messages); err != nil {
+			panic(fmt.Errorf("i18n: %v: %v", locale, err))
+		}
+		for i, day := range locales[Default].weekdays {
+			if _, ok := c.messages[day]; !ok {
+				c.messages[day] = getLocale(locale).weekdays[i]
+			}
+		}
+		for message := range c.messages {
+			if verb.MatchString(message) {
+				c.formats = append(c.formats, format{message, compileFormat(message)})
+			}
+		}
+		sort.Slice(c.formats, func(i, j int) bool {
+			if len(c.formats[i].message) != len(c.formats[j].message) {
+				return len(c.formats[i].message) > len(c.formats[j].message)
+			}
+			return c.formats[i].message < c.formats[j].message
+		})
+		catalogs[locale] = c
+	}
+	return catalogs
+}
+
+// compileFormat returns the pattern matching a message formatted in English, arguments are captured.
+func compileFormat(message string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, v := range verb.FindAllStringIndex(message, -1) {
+		b.WriteString(regexp.QuoteMeta(message[last:v[0]]))
+		b.WriteString("(.+?)")
+		last = v[1]
+	}
+	b.WriteString(regexp.QuoteMeta(message[last:]))
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// IsSupported checks if locale is one of the supported locales.
+func IsSupported(locale string) bool {
+	_, ok := locales[locale]
+	return ok
+}
+
+// Name returns the name of locale in its own language.
+func Name(locale string) string {
+	return getLocale(locale).name
+}
+
+// Negotiate returns the supported locale which best matches preferences in order, preferences are locales
+// or Accept-Language headers. Default is returned when no supported locale is accepted.
+func Negotiate(preferences ...string) string {
+	var tags []language.Tag
+	for _, v := range preferences {
+		if accepted, _, err := language.ParseAcceptLanguage(v); err == nil {
+			tags = append(tags, accepted...)
+		}
+	}
+	if len(tags) == 0 {
+		return Default
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return Locales[index]
+}
+
+// T translates message into locale and formats args into the translation as fmt.Sprintf.
+// A message without translation is formatted in English, a message formatted in English without args
+// is translated with the format of the catalog it matches, arguments matched are translated as messages.
+func T(locale, message string, args ...interface{}) string {
+	c := catalogs[locale]
+	if c == nil {
+		return sprintf(message, args...)
+	}
+	if translation, ok := c.messages[message]; ok {
+		return sprintf(translation, args...)
+	}
+	if len(args) > 0 {
+		return sprintf(message, args...)
+	}
+	if translation, ok := c.translate(locale, message); ok {
+		return translation
+	}
+	return message
+}
+
+// translate translates a message formatted in English with the first format it matches. Messages of several
+// sentences are translated sentence by sentence when the message does not match any format.
+func (c *catalog) translate(locale, message string) (string, bool) {
+	for _, v := range c.formats {
+		matches := v.pattern.FindStringSubmatch(message)
+		if matches == nil || spansSentences(matches[1:]) {
+			continue
+		}
+		args := make([]interface{}, len(matches)-1)
+		for i, arg := range matches[1:] {
+			args[i] = T(locale, arg)
+		}
+		return fmt.Sprintf(c.messages[v.message], args...), true
+	}
+
+	sentences := splitSentences(message)
+	if len(sentences) < 2 {
+		return "", false
+	}
+	translated := false
+	for i, v := range sentences {
+		if translation := T(locale, v); translation != v {
+			sentences[i], translated = translation, true
+		}
+	}
+	return strings.Join(sentences, getLocale(locale).separator), translated
+}
+
+// spansSentences checks if any argument matched spans several sentences, such arguments match a format
+// only because messages of several sentences are joined.
+func spansSentences(args []string) bool {
+	for _, v := range args {
+		if strings.Contains(v, ". ") {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSentences splits a message into sentences ending with a full stop followed by a space.
+func splitSentences(message string) []string {
+	var sentences []string
+	for {
+		i := strings.Index(message, ". ")
+		if i < 0 {
+			break
+		}
+		sentences = append(sentences, message[:i+1])
+		message = message[i+2:]
+	}
+	return append(sentences, message)
+}
+
+// sprintf formats args into message, message is returned as is without args so that % is not treated as a verb.
+func sprintf(message string, args ...interface{}) string {
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
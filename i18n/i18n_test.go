@@ -0,0 +1,113 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		preferences []string
+		want        string
+	}{
+		{nil, "en"},
+		{[]string{""}, "en"},
+		{[]string{"", "zh-CN,zh;q=0.9,en;q=0.8"}, "zh-Hans"},
+		{[]string{"", "en-GB,en;q=0.9,ms;q=0.8"}, "en"},
+		{[]string{"", "fr-FR,ms;q=0.5"}, "ms"},
+		{[]string{"", "fr-FR,de;q=0.5"}, "en"},
+		{[]string{"ms", "zh-CN,zh;q=0.9"}, "ms"},
+		{[]string{"zh-Hans", "en-US"}, "zh-Hans"},
+		{[]string{"invalid;;", "zh-SG"}, "zh-Hans"},
+	}
+	for _, v := range tests {
+		if got := Negotiate(v.preferences...); got != v.want {
+			t.Errorf("Negotiate(%q) = %v; want %v", v.preferences, got, v.want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		locale  string
+		message string
+		args    []interface{}
+		want    string
+	}{
+		{"en", "Session %v", []interface{}{2}, "Session 2"},
+		{"en", "100% sure", nil, "100% sure"},
+		{"fr", "Session %v", []interface{}{2}, "Session 2"},
+		{"zh-Hans", "Appointments", nil, "预约"},
+		{"ms", "Appointments", nil, "Temujanji"},
+		{"zh-Hans", "Session %v", []interface{}{2}, "第 2 时段"},
+		{"zh-Hans", "%v of %v dates are not available.", []interface{}{1, 4}, "4 个日期中有 1 个不可用。"},
+		{"zh-Hans", "Untranslated %v", []interface{}{1}, "Untranslated 1"},
+		{"zh-Hans", "Untranslated", nil, "Untranslated"},
+		// Messages formatted in English are translated with the formats they match, arguments are translated.
+		{"zh-Hans", "Appointment must be booked at least 2 hours in advance.", nil, "预约必须至少提前 2 小时 预订。"},
+		{"ms", "No chair is available at East Branch.", nil, "Tiada kerusi tersedia di Cawangan Timur."},
+		{"ms", "Dr. Ann Lee does not perform Root Canal.", nil, "Dr. Ann Lee tidak melakukan Rawatan Saluran Akar."},
+		{"ms", "Wednesday", nil, "Rabu"},
+		// Messages of several sentences are translated sentence by sentence.
+		{"ms", "Username is not valid. Session must be between 1 and 4.", nil, "Nama pengguna tidak sah. Sesi mestilah antara 1 dan 4."},
+		{"zh-Hans", "Username is not valid. Email is not valid.", nil, "用户名无效。电子邮件无效。"},
+		{"ms", "Unknown. Another unknown.", nil, "Unknown. Another unknown."},
+	}
+	for _, v := range tests {
+		if got := T(v.locale, v.message, v.args...); got != v.want {
+			t.Errorf("T(%v, %q) = %q; want %q", v.locale, v.message, got, v.want)
+		}
+	}
+}
+
+func TestCatalogs(t *testing.T) {
+	for _, locale := range Locales[1:] {
+		c := catalogs[locale]
+		if c == nil {
+			t.Fatalf("catalog of %v is not loaded", locale)
+		}
+		for _, other := range Locales[1:] {
+			for message := range catalogs[other].messages {
+				if _, ok := c.messages[message]; !ok {
+					t.Errorf("%v: %q is not translated", locale, message)
+				}
+			}
+		}
+		for message, translation := range c.messages {
+			if got, want := len(verb.FindAllString(translation, -1)), len(verb.FindAllString(message, -1)); got != want {
+				t.Errorf("%v: %q has %d arguments; want %d", locale, translation, got, want)
+			}
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	tests := []struct {
+		locale string
+		date   string
+		want   string
+		day    string
+	}{
+		{"en", "2022-03-07", "07-Mar-2022", "Monday"},
+		{"zh-Hans", "2022-03-07", "2022年3月7日", "星期一"},
+		{"ms", "2022-08-07", "07-Ogo-2022", "Ahad"},
+		{"fr", "2022-03-07", "07-Mar-2022", "Monday"},
+		{"en", "invalid", "invalid", "invalid"},
+	}
+	for _, v := range tests {
+		if got := FormatDate(v.locale, v.date); got != v.want {
+			t.Errorf("FormatDate(%v, %v) = %v; want %v", v.locale, v.date, got, v.want)
+		}
+		if got := GetDay(v.locale, v.date); got != v.day {
+			t.Errorf("GetDay(%v, %v) = %v; want %v", v.locale, v.date, got, v.day)
+		}
+	}
+
+	date := time.Date(2022, time.December, 25, 9, 5, 30, 0, time.UTC)
+	if got, want := FormatDateTime("ms", date), "25-Dis-2022 09:05"; got != want {
+		t.Errorf("FormatDateTime(ms) = %v; want %v", got, want)
+	}
+	if got, want := FormatTimestamp("zh-Hans", date), "2022年12月25日 09:05:30"; got != want {
+		t.Errorf("FormatTimestamp(zh-Hans) = %v; want %v", got, want)
+	}
+}
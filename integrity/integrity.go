@@ -0,0 +1,364 @@
+// Package integrity monitors data, template and configuration files for modifications made outside of the application.
+// The expected SHA-256 hash of every monitored file is recorded after each write by the application, a file whose
+// hash no longer matches is reported as tampered. Expected hashes are kept in a manifest signed with HMAC-SHA256
+// so that modifications made while the application is not running are detected on start.
+package integrity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shiweii/metrics"
+)
+
+// Modes of responding to tampering.
+const (
+	ModeAlert    = "alert"    // Tampering is reported only
+	ModeReadOnly = "readonly" // Requests which change data are refused
+	ModeRefuse   = "refuse"   // All requests are refused
+)
+
+// FileStatus struct stores the result of checking a monitored file, Actual is empty when the file is missing.
+type FileStatus struct {
+	Path       string
+	Expected   string
+	Actual     string
+	Tampered   bool
+	Reason     string
+	DetectedAt time.Time
+}
+
+// manifest struct stores the expected hashes of files signed with HMAC-SHA256.
+type manifest struct {
+	Files     map[string]string `json:"files"`
+	Signature string            `json:"signature"`
+}
+
+// file struct stores the state of a monitored file.
+type file struct {
+	expected string
+	writing  int
+	status   *FileStatus
+}
+
+// Monitor holds the monitored files and their expected hashes, the manifest is saved at path
+// when a key is given. Handlers added with OnTamper are called when a file is found tampered.
+type Monitor struct {
+	mu       sync.Mutex
+	path     string
+	key      []byte
+	files    map[string]*file
+	trusted  map[string]string
+	handlers []func(status FileStatus)
+	pending  []FileStatus
+	now      func() time.Time
+}
+
+// std is the monitor used by the package level functions, writes are not monitored when nil.
+// pending counts the writes in progress through the package level functions.
+var (
+	stdMu   sync.Mutex
+	std     *Monitor
+	pending sync.WaitGroup
+)
+
+// New will return a newly created monitor with the expected hashes read from the manifest at path,
+// the manifest is reported as tampered by Check when its signature does not match.
+func New(path string, key []byte) *Monitor {
+	m := &Monitor{path: path, key: key, files: make(map[string]*file), trusted: make(map[string]string), now: time.Now}
+	if path == "" || len(key) == 0 {
+		return m
+	}
+	JSONData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return m
+	}
+	var data manifest
+	if err = json.Unmarshal(JSONData, &data); err == nil && hmac.Equal([]byte(data.Signature), []byte(m.getSignature(data.Files))) {
+		m.trusted = data.Files
+		return m
+	}
+	// The manifest itself was changed, it is reported by the first check
+	status := FileStatus{Path: getPath(path), Tampered: true, Reason: "manifest signature does not match", DetectedAt: m.now()}
+	m.files[status.Path] = &file{status: &status}
+	m.pending = append(m.pending, status)
+	return m
+}
+
+// SetDefault sets the monitor of writes made through the package level functions.
+func SetDefault(m *Monitor) {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	std = m
+}
+
+// getDefault returns the monitor of the package level functions.
+func getDefault() *Monitor {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	return std
+}
+
+// WriteFile writes data into a file like ioutil.WriteFile and records the hash of a monitored file.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	done := Expect(path)
+	defer done()
+	return ioutil.WriteFile(path, data, perm)
+}
+
+// Expect marks a file as being written by the application until the returned function is called,
+// the hash of a monitored file is recorded then. The time taken to write is recorded as write latency.
+func Expect(path string) func() {
+	pending.Add(1)
+	start := time.Now()
+	done := func() {}
+	if m := getDefault(); m != nil {
+		done = m.Expect(path)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			metrics.ObserveWrite(path, time.Since(start))
+			done()
+			pending.Done()
+		})
+	}
+}
+
+// Wait waits until the writes in progress through WriteFile and Expect are done,
+// returns false when they are not done within timeout.
+func Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Add starts monitoring files, the expected hash is read from the manifest or the current content
+// of a file not in the manifest. Files which do not exist are expected to be created by the application.
+func (m *Monitor) Add(paths ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range paths {
+		path := getPath(v)
+		if _, ok := m.files[path]; ok || path == getPath(m.path) {
+			continue
+		}
+		expected, ok := m.trusted[path]
+		if !ok {
+			expected, _ = getHash(path)
+		}
+		m.files[path] = &file{expected: expected}
+	}
+	m.saveManifest()
+}
+
+// AddDir starts monitoring all files in a directory matching pattern.
+func (m *Monitor) AddDir(dir, pattern string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return err
+	}
+	m.Add(paths...)
+	return nil
+}
+
+// OnTamper adds a function called with the status of every file found tampered.
+func (m *Monitor) OnTamper(handler func(status FileStatus)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = append(m.handlers, handler)
+}
+
+// Expect marks a file as being written by the application until the returned function is called,
+// the hash of the file is recorded then. Changes of a file being written are not checked.
+func (m *Monitor) Expect(path string) func() {
+	path = getPath(path)
+	m.mu.Lock()
+	f, ok := m.files[path]
+	if ok {
+		f.writing++
+	}
+	m.mu.Unlock()
+	if !ok {
+		return func() {}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			hash, _ := getHash(path)
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			f.writing--
+			// A file already tampered stays tampered until accepted
+			if f.status == nil {
+				f.expected = hash
+				m.saveManifest()
+			}
+		})
+	}
+}
+
+// Check compares the hash of monitored files with the expected hash, handlers are called
+// for files found tampered which were not tampered before. Returns the files found tampered.
+func (m *Monitor) Check(paths ...string) []FileStatus {
+	m.mu.Lock()
+	if len(paths) == 0 {
+		for k := range m.files {
+			paths = append(paths, k)
+		}
+	}
+	sort.Strings(paths)
+	found := m.pending
+	m.pending = nil
+	for _, v := range paths {
+		f, ok := m.files[getPath(v)]
+		// The manifest stays tampered until accepted
+		path := getPath(v)
+		if !ok || f.writing > 0 || path == getPath(m.path) {
+			continue
+		}
+		actual, err := getHash(path)
+		switch {
+		case actual == f.expected:
+			f.status = nil
+		case f.status != nil:
+			f.status.Actual = actual
+		default:
+			f.status = &FileStatus{Path: path, Expected: f.expected, Actual: actual, Tampered: true, Reason: "file was modified", DetectedAt: m.now()}
+			if errors.Is(err, os.ErrNotExist) {
+				f.status.Reason = "file was removed"
+			} else if f.expected == "" {
+				f.status.Reason = "file was created"
+			}
+			found = append(found, *f.status)
+		}
+	}
+	handlers := m.handlers
+	m.mu.Unlock()
+
+	for _, status := range found {
+		for _, handler := range handlers {
+			handler(status)
+		}
+	}
+	return found
+}
+
+// Accept records the current content of a tampered file as expected.
+func (m *Monitor) Accept(path string) error {
+	path = getPath(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[path]
+	if !ok {
+		return os.ErrNotExist
+	}
+	if path == getPath(m.path) {
+		delete(m.files, path)
+	} else {
+		f.expected, _ = getHash(path)
+		f.status = nil
+	}
+	return m.saveManifest()
+}
+
+// GetStatus returns the status of all monitored files in order of path.
+func (m *Monitor) GetStatus() []FileStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []FileStatus
+	for k, v := range m.files {
+		if v.status != nil {
+			list = append(list, *v.status)
+		} else {
+			list = append(list, FileStatus{Path: k, Expected: v.expected, Actual: v.expected})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Path < list[j].Path
+	})
+	return list
+}
+
+// IsTampered checks if any monitored file is tampered.
+func (m *Monitor) IsTampered() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range m.files {
+		if v.status != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// saveManifest writes the expected hashes into the manifest, caller must hold mu.
+func (m *Monitor) saveManifest() error {
+	if m.path == "" || len(m.key) == 0 {
+		return nil
+	}
+	data := manifest{Files: make(map[string]string)}
+	for k, v := range m.files {
+		if k != getPath(m.path) {
+			data.Files[k] = v.expected
+		}
+	}
+	data.Signature = m.getSignature(data.Files)
+	JSONData, err := json.MarshalIndent(data, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(m.path, JSONData, 0600)
+}
+
+// getSignature returns the hex encoded HMAC of expected hashes.
+func (m *Monitor) getSignature(files map[string]string) string {
+	JSONData, _ := json.Marshal(files)
+	h := hmac.New(sha256.New, m.key)
+	h.Write(JSONData)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// getHash returns the hex encoded SHA-256 hash of a file, empty when the file does not exist.
+func getHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// getPath returns the absolute path of a file to compare paths given in different forms.
+func getPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,87 @@
+package integrity
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	_ = os.WriteFile(path, []byte(`{"a":1}`), 0644)
+
+	m := New(filepath.Join(dir, "manifest.json"), testKey)
+	m.Add(path)
+	var alerted []FileStatus
+	m.OnTamper(func(status FileStatus) {
+		alerted = append(alerted, status)
+	})
+	SetDefault(m)
+	defer SetDefault(nil)
+
+	// Writes by the application are expected
+	if err := WriteFile(path, []byte(`{"a":2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if found := m.Check(); len(found) != 0 || m.IsTampered() {
+		t.Fatalf("got %+v, want no tampering", found)
+	}
+
+	_ = os.WriteFile(path, []byte(`{"a":3}`), 0644)
+	if found := m.Check(); len(found) != 1 || found[0].Reason != "file was modified" {
+		t.Fatalf("got %+v, want file was modified", found)
+	}
+	// Tampering is reported once and kept after the next write
+	_ = WriteFile(path, []byte(`{"a":4}`), 0644)
+	if found := m.Check(); len(found) != 0 || !m.IsTampered() || len(alerted) != 1 {
+		t.Fatalf("got %+v, %v alerts, want tampering reported once", found, len(alerted))
+	}
+	if err := m.Accept(path); err != nil || m.IsTampered() {
+		t.Fatalf("got %v, want tampering accepted", err)
+	}
+
+	_ = os.Remove(path)
+	if found := m.Check(); len(found) != 1 || found[0].Reason != "file was removed" {
+		t.Fatalf("got %+v, want file was removed", found)
+	}
+}
+
+func TestWait(t *testing.T) {
+	done := Expect(filepath.Join(t.TempDir(), "data.json"))
+	if Wait(10 * time.Millisecond) {
+		t.Fatal("got writes done, want write in progress")
+	}
+	done()
+	done()
+	if !Wait(time.Second) {
+		t.Fatal("got write in progress, want writes done")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	path, manifestPath := filepath.Join(dir, "data.json"), filepath.Join(dir, "manifest.json")
+	_ = os.WriteFile(path, []byte(`{"a":1}`), 0644)
+	New(manifestPath, testKey).Add(path)
+
+	// Changes made while not running are detected on start
+	_ = os.WriteFile(path, []byte(`{"a":2}`), 0644)
+	m := New(manifestPath, testKey)
+	m.Add(path)
+	if found := m.Check(); len(found) != 1 || found[0].Path != path {
+		t.Fatalf("got %+v, want %v tampered", found, path)
+	}
+
+	data, _ := os.ReadFile(manifestPath)
+	_ = os.WriteFile(manifestPath, bytes.Replace(data, []byte(`"signature": "`), []byte(`"signature": "0`), 1), 0644)
+	m = New(manifestPath, testKey)
+	m.Add(path)
+	if found := m.Check(); len(found) != 1 || found[0].Reason != "manifest signature does not match" {
+		t.Fatalf("got %+v, want manifest signature does not match", found)
+	}
+}
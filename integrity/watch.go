@@ -0,0 +1,77 @@
+package integrity
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/shiweii/logger"
+)
+
+// Watch checks monitored files when they are changed and every interval until stop is closed.
+// Directories of monitored files are watched as files written by replacing them are not watched
+// after the first write. Files are only checked every interval when the directories can not be watched.
+func (m *Monitor) Watch(interval time.Duration, stop <-chan struct{}) {
+	m.Check()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error.Printf("Error watching monitored files, files are checked every %v: %v", interval, err)
+		m.scan(ticker.C, stop)
+		return
+	}
+	defer watcher.Close()
+	for dir := range m.getDirs() {
+		if err = watcher.Add(dir); err != nil {
+			logger.Error.Printf("Error watching directory [%v], files are checked every %v: %v", dir, interval, err)
+		}
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.Check()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				m.scan(ticker.C, stop)
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				m.Check(event.Name)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				m.scan(ticker.C, stop)
+				return
+			}
+			logger.Error.Printf("Error watching monitored files: %v", err)
+		}
+	}
+}
+
+// scan checks monitored files on every tick until stop is closed.
+func (m *Monitor) scan(tick <-chan time.Time, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick:
+			m.Check()
+		}
+	}
+}
+
+// getDirs returns the directories of monitored files.
+func (m *Monitor) getDirs() map[string]bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dirs := make(map[string]bool)
+	for k := range m.files {
+		dirs[filepath.Dir(k)] = true
+	}
+	return dirs
+}
@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"errors"
+	"time"
+)
+
+// Alert struct stores a tampered log file detected by Monitor, Line is 0 when the whole file does not match.
+type Alert struct {
+	Time   time.Time
+	File   string
+	Line   int
+	Reason string
+}
+
+// AlertSink is implemented by any destination tampering alerts are raised through.
+type AlertSink interface {
+	Alert(alert Alert) error
+}
+
+// AlertFunc is an adapter to use a function as AlertSink.
+type AlertFunc func(alert Alert) error
+
+// Alert calls f(alert).
+func (f AlertFunc) Alert(alert Alert) error {
+	return f(alert)
+}
+
+// Monitor verifies the log files in dir every interval until stop is closed and raises an alert
+// through sink for every tampered file. The same tampering is only alerted once.
+func Monitor(dir string, key []byte, interval time.Duration, sink AlertSink, stop <-chan struct{}) {
+	alerted := make(map[string]bool)
+	for {
+		for _, v := range checkDir(dir, key, alerted) {
+			if err := sink.Alert(v); err != nil {
+				Error.Printf("Error raising alert of tampered log file [%v]: %v", v.File, err)
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// checkDir verifies the log files in dir and returns alerts of tampering not in alerted,
+// alerted stores the tampering already alerted and is updated.
+func checkDir(dir string, key []byte, alerted map[string]bool) []Alert {
+	results, err := VerifyDir(dir, key)
+	if err != nil {
+		Error.Printf("Error verifying log files: %v", err)
+		return nil
+	}
+	var alerts []Alert
+	found := make(map[string]bool)
+	for _, v := range results {
+		var tamperErr *TamperError
+		if !errors.As(v.Err, &tamperErr) {
+			if v.Err != nil {
+				Error.Printf("Error verifying log file [%v]: %v", v.File, v.Err)
+			}
+			continue
+		}
+		found[tamperErr.Error()] = true
+		if !alerted[tamperErr.Error()] {
+			alerts = append(alerts, Alert{Time: time.Now(), File: tamperErr.File, Line: tamperErr.Line, Reason: tamperErr.Reason})
+		}
+	}
+	for k := range alerted {
+		delete(alerted, k)
+	}
+	for k := range found {
+		alerted[k] = true
+	}
+	return alerts
+}
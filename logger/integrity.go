@@ -0,0 +1,319 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Log files written with a key are protected by a chain of HMAC-SHA256: every line ends with the MAC of
+// the line and the MAC of the previous line, which is a "mac" field of JSON records and a mac key-value
+// pair of logfmt records. Rotated files are sealed with a manifest signed with the same key, manifests
+// store the signature of the previous manifest so that removing a sealed file is detected.
+
+// manifestExt is appended to the path of a sealed log file to name its manifest.
+const manifestExt = ".manifest"
+
+const (
+	macLength  = sha256.Size * 2
+	jsonMAC    = `,"mac":"`
+	logfmtMAC  = " mac="
+	jsonSuffix = len(jsonMAC) + macLength + len(`"}`)
+)
+
+// Manifest struct stores the digest of a sealed log file.
+// Signature is the HMAC of the manifest without Signature, Previous is the signature of the previous manifest.
+type Manifest struct {
+	File      string    `json:"file"`
+	Lines     int       `json:"lines"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	LastMAC   string    `json:"lastMac"`
+	Previous  string    `json:"previous"`
+	SealedAt  time.Time `json:"sealedAt"`
+	Signature string    `json:"signature"`
+}
+
+// TamperError reports the first line of a log file which does not match its MAC or manifest,
+// Line is 0 when the whole file or manifest does not match.
+type TamperError struct {
+	File   string
+	Line   int
+	Reason string
+}
+
+func (e *TamperError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("logger: %v is tampered: %v", e.File, e.Reason)
+	}
+	return fmt.Sprintf("logger: %v is tampered at line %d: %v", e.File, e.Line, e.Reason)
+}
+
+// FileResult struct stores the result of verifying a log file, Err is a *TamperError when the file was tampered.
+type FileResult struct {
+	File   string
+	Lines  int
+	Sealed bool
+	Err    error
+}
+
+// getMAC returns the hex encoded HMAC of a record chained to the MAC of the previous record.
+func getMAC(key []byte, prevMAC string, record []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(prevMAC))
+	h.Write(record)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// signLine returns a record with its MAC chained to prevMAC and the MAC.
+func signLine(key []byte, prevMAC string, record []byte) ([]byte, string) {
+	mac := getMAC(key, prevMAC, record)
+	signed := make([]byte, 0, len(record)+jsonSuffix)
+	if len(record) >= 2 && record[0] == '{' && record[len(record)-1] == '}' {
+		signed = append(append(append(signed, record[:len(record)-1]...), jsonMAC...), mac...)
+		return append(signed, `"}`...), mac
+	}
+	return append(append(append(signed, record...), logfmtMAC...), mac...), mac
+}
+
+// splitLine returns the record and MAC of a signed line, returns false if the line is not signed.
+func splitLine(line []byte) ([]byte, string, bool) {
+	n := len(line)
+	if n >= jsonSuffix && string(line[n-jsonSuffix:n-jsonSuffix+len(jsonMAC)]) == jsonMAC && string(line[n-2:]) == `"}` {
+		record := append(append([]byte{}, line[:n-jsonSuffix]...), '}')
+		return record, string(line[n-macLength-2 : n-2]), true
+	}
+	if n >= len(logfmtMAC)+macLength && string(line[n-macLength-len(logfmtMAC):n-macLength]) == logfmtMAC {
+		return line[:n-macLength-len(logfmtMAC)], string(line[n-macLength:]), true
+	}
+	return nil, "", false
+}
+
+// verifyLines checks the MAC chain of all lines of a log file, returns the number of lines and the MAC of the last line.
+// An incomplete last line is being written and is ignored unless the file is sealed.
+func verifyLines(path string, key []byte, sealed bool) (int, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	name := filepath.Base(path)
+	reader := bufio.NewReader(file)
+	lines, prevMAC := 0, ""
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && (len(line) == 0 || !sealed) {
+			return lines, prevMAC, nil
+		} else if err != nil && err != io.EOF {
+			return lines, prevMAC, err
+		}
+		lines++
+		record, mac, ok := splitLine(bytes.TrimSuffix(line, []byte("\n")))
+		if !ok {
+			return lines - 1, prevMAC, &TamperError{name, lines, "line is not signed"}
+		}
+		if !hmac.Equal([]byte(mac), []byte(getMAC(key, prevMAC, record))) {
+			return lines - 1, prevMAC, &TamperError{name, lines, "MAC does not match, line was changed, inserted or removed"}
+		}
+		prevMAC = mac
+		if err == io.EOF {
+			return lines, prevMAC, nil
+		}
+	}
+}
+
+// getLastMAC returns the number of lines and MAC of the last line of a log file without verifying the lines.
+func getLastMAC(path string) (int, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	lines, lastMAC := 0, ""
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			lines++
+			if _, mac, ok := splitLine(line[:len(line)-1]); ok {
+				lastMAC = mac
+			}
+		}
+		if err == io.EOF {
+			return lines, lastMAC, nil
+		} else if err != nil {
+			return lines, lastMAC, err
+		}
+	}
+}
+
+// getSignature returns the hex encoded HMAC of a manifest without its signature.
+func getSignature(key []byte, manifest Manifest) string {
+	manifest.Signature = ""
+	JSONData, _ := json.Marshal(manifest)
+	h := hmac.New(sha256.New, key)
+	h.Write(JSONData)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// getDigest returns the size and hex encoded SHA-256 hash of a file.
+func getDigest(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	return size, hex.EncodeToString(h.Sum(nil)), err
+}
+
+// seal writes the signed manifest of a log file with lines ending with lastMAC, returns the signature of the manifest.
+func seal(path string, key []byte, lines int, lastMAC, previous string, now time.Time) (string, error) {
+	size, sum, err := getDigest(path)
+	if err != nil {
+		return "", err
+	}
+	manifest := Manifest{
+		File:     filepath.Base(path),
+		Lines:    lines,
+		Size:     size,
+		SHA256:   sum,
+		LastMAC:  lastMAC,
+		Previous: previous,
+		SealedAt: now.UTC(),
+	}
+	manifest.Signature = getSignature(key, manifest)
+	JSONData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return manifest.Signature, writeFileAtomic(path+manifestExt, JSONData, 0644)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory which is synced and renamed to path,
+// so that a crash while writing never leaves a partial file at path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+// readManifest will open, read and unmarshal the manifest of a log file.
+func readManifest(path string) (*Manifest, error) {
+	JSONData, err := os.ReadFile(path + manifestExt)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err = json.Unmarshal(JSONData, &manifest); err != nil {
+		return nil, &TamperError{filepath.Base(path + manifestExt), 0, "manifest is not valid JSON"}
+	}
+	return &manifest, nil
+}
+
+// readManifests returns the manifests of all sealed log files in dir in order of sealing,
+// manifests which are not valid are skipped.
+func readManifests(dir string) []*Manifest {
+	paths, _ := filepath.Glob(filepath.Join(dir, "log_*.log"+manifestExt))
+	var list []*Manifest
+	for _, v := range paths {
+		if manifest, err := readManifest(strings.TrimSuffix(v, manifestExt)); err == nil {
+			list = append(list, manifest)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].SealedAt.Before(list[j].SealedAt)
+	})
+	return list
+}
+
+// VerifyFile checks the MAC chain of every line of a log file and the manifest of a sealed file,
+// returns the number of lines verified and a *TamperError with the first line which does not match.
+func VerifyFile(path string, key []byte) (int, error) {
+	manifest, err := readManifest(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	lines, lastMAC, err := verifyLines(path, key, manifest != nil)
+	if err != nil || manifest == nil {
+		return lines, err
+	}
+
+	name := filepath.Base(path + manifestExt)
+	if !hmac.Equal([]byte(manifest.Signature), []byte(getSignature(key, *manifest))) {
+		return lines, &TamperError{name, 0, "manifest signature does not match"}
+	}
+	if manifest.File != filepath.Base(path) {
+		return lines, &TamperError{name, 0, fmt.Sprintf("manifest belongs to %v", manifest.File)}
+	}
+	if lines != manifest.Lines || lastMAC != manifest.LastMAC {
+		return lines, &TamperError{filepath.Base(path), lines + 1, fmt.Sprintf("file has %d lines, %d lines were sealed", lines, manifest.Lines)}
+	}
+	if size, sum, err := getDigest(path); err != nil {
+		return lines, err
+	} else if size != manifest.Size || sum != manifest.SHA256 {
+		return lines, &TamperError{filepath.Base(path), 0, "file content does not match the sealed hash"}
+	}
+	return lines, nil
+}
+
+// VerifyDir verifies all log files in dir and the chain of manifests of sealed files, sealed files
+// which were removed are reported with a *TamperError of their manifest. The chain starts from the
+// oldest manifest as older files may be removed by retention.
+func VerifyDir(dir string, key []byte) ([]FileResult, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "log_*.log"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var results []FileResult
+	for _, v := range paths {
+		lines, err := VerifyFile(v, key)
+		_, sealed := os.Stat(v + manifestExt)
+		results = append(results, FileResult{File: filepath.Base(v), Lines: lines, Sealed: sealed == nil, Err: err})
+	}
+
+	manifests := readManifests(dir)
+	for k, v := range manifests {
+		name := v.File + manifestExt
+		if _, err := os.Stat(filepath.Join(dir, v.File)); os.IsNotExist(err) {
+			results = append(results, FileResult{File: v.File, Sealed: true, Err: &TamperError{name, 0, "sealed log file was removed"}})
+		} else if k > 0 && v.Previous != manifests[k-1].Signature {
+			results = append(results, FileResult{File: v.File, Sealed: true, Err: &TamperError{name, 0, "manifest does not follow " + manifests[k-1].File + ", a sealed log file was removed"}})
+		}
+	}
+	return results, nil
+}
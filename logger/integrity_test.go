@@ -0,0 +1,165 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+// writeLogs writes records into a rotating file with key in dir which is rotated every 3 records,
+// a new rotating file is used to continue from a restart.
+func writeLogs(t *testing.T, dir string, records ...string) {
+	file := NewRotatingFile(dir, 0, 0)
+	file.Key = testKey
+	file.now = func() time.Time { return time.Date(2022, 6, 1, 10, 0, 0, 0, time.Local) }
+	defer file.Close()
+	for _, v := range records {
+		if _, err := file.Write([]byte(v + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		if file.lines == 3 {
+			file.MaxSize = 1
+		} else {
+			file.MaxSize = 0
+		}
+	}
+}
+
+// getTamperError returns the tampering found in the results of a directory.
+func getTamperError(t *testing.T, dir string) *TamperError {
+	results, err := VerifyDir(dir, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range results {
+		var tamperErr *TamperError
+		if errors.As(v.Err, &tamperErr) {
+			return tamperErr
+		} else if v.Err != nil {
+			t.Fatal(v.Err)
+		}
+	}
+	return nil
+}
+
+func TestSignLine(t *testing.T) {
+	for _, record := range []string{`time=now level=info msg="saved"`, `{"time":"now","level":"info"}`} {
+		signed, mac := signLine(testKey, "", []byte(record))
+		got, gotMAC, ok := splitLine(signed)
+		if !ok || string(got) != record || gotMAC != mac {
+			t.Errorf("splitLine(%q) = %q, %v, %v", signed, got, gotMAC, ok)
+		}
+	}
+	if _, _, ok := splitLine([]byte("level=info msg=unsigned")); ok {
+		t.Error("unsigned line should not be split")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log_2022_06_01.1.log"+manifestExt)
+	for _, data := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(path); string(got) != data {
+			t.Errorf("got %q, want %q", got, data)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d files, want temporary files removed", len(entries))
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "file"), nil, 0644); err == nil {
+		t.Error("writing into a missing directory should fail")
+	}
+}
+
+func TestVerifyDir(t *testing.T) {
+	dir := t.TempDir()
+	writeLogs(t, dir, "a=1", `{"b":2}`, "c=3", "d=4", "e=5")
+	writeLogs(t, dir, "f=6", "g=7")
+
+	results, err := VerifyDir(dir, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FileResult{{"log_2022_06_01.1.log", 3, true, nil}, {"log_2022_06_01.2.log", 3, true, nil}, {"log_2022_06_01.log", 1, false, nil}}
+	if len(results) != len(want) {
+		t.Fatalf("got %+v, want %+v", results, want)
+	}
+	for k, v := range want {
+		if results[k] != v {
+			t.Errorf("got %+v, want %+v", results[k], v)
+		}
+	}
+
+	// Alerts are raised once for the same tampering
+	alerted := make(map[string]bool)
+	path := filepath.Join(dir, "log_2022_06_01.2.log")
+	data, _ := os.ReadFile(path)
+	_ = os.WriteFile(path, bytes.Replace(data, []byte("e=5"), []byte("e=6"), 1), 0644)
+	if alerts := checkDir(dir, testKey, alerted); len(alerts) != 1 || alerts[0].File != "log_2022_06_01.2.log" || alerts[0].Line != 2 {
+		t.Errorf("got alerts %+v, want line 2 of log_2022_06_01.2.log", alerts)
+	}
+	if alerts := checkDir(dir, testKey, alerted); len(alerts) != 0 {
+		t.Errorf("got alerts %+v, want none", alerts)
+	}
+}
+
+func TestVerifyTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(dir string)
+		file   string
+		line   int
+	}{
+		{"line changed", func(dir string) {
+			replaceLine(dir, "log_2022_06_01.1.log", 1, func(line string) string { return strings.Replace(line, "b", "x", 1) })
+		}, "log_2022_06_01.1.log", 2},
+		{"line removed", func(dir string) {
+			replaceLine(dir, "log_2022_06_01.log", 0, func(string) string { return "" })
+		}, "log_2022_06_01.log", 1},
+		{"unsigned line inserted", func(dir string) {
+			replaceLine(dir, "log_2022_06_01.log", 1, func(line string) string { return "level=info msg=inserted\n" + line })
+		}, "log_2022_06_01.log", 2},
+		{"sealed file truncated", func(dir string) {
+			replaceLine(dir, "log_2022_06_01.1.log", 2, func(string) string { return "" })
+		}, "log_2022_06_01.1.log", 3},
+		{"sealed file removed", func(dir string) {
+			_ = os.Remove(filepath.Join(dir, "log_2022_06_01.1.log"))
+		}, "log_2022_06_01.1.log.manifest", 0},
+		{"sealed file and manifest removed", func(dir string) {
+			_ = os.Remove(filepath.Join(dir, "log_2022_06_01.2.log"))
+			_ = os.Remove(filepath.Join(dir, "log_2022_06_01.2.log.manifest"))
+		}, "log_2022_06_01.3.log.manifest", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeLogs(t, dir, "a=1", "b=2", "c=3", "d=4", "e=5", "f=6", "g=7", "h=8", "i=9", "j=10", "k=11")
+			if err := getTamperError(t, dir); err != nil {
+				t.Fatal(err)
+			}
+			test.tamper(dir)
+			err := getTamperError(t, dir)
+			if err == nil || err.File != test.file || err.Line != test.line {
+				t.Errorf("got %v, want tampering of %v at line %v", err, test.file, test.line)
+			}
+		})
+	}
+}
+
+// replaceLine replaces a line (starting from 0) of a file in dir.
+func replaceLine(dir, name string, line int, replace func(line string) string) {
+	path := filepath.Join(dir, name)
+	data, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(data), "\n")
+	lines[line] = replace(lines[line])
+	_ = os.WriteFile(path, []byte(strings.Join(lines, "")), 0644)
+}
@@ -1,47 +1,315 @@
-// Package logger implements a simple logging package.
+// Package logger implements a leveled, structured logging package.
+// Records are written as logfmt or JSON lines with the time, level, caller, message and key-value fields.
+// Records are written into stderr until Configure sets the minimum level, format and writers, such as a RotatingFile.
 package logger
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Level is the severity of a log record.
+type Level int
+
+// Log levels in increasing severity.
+const (
+	LevelTrace Level = iota
+	LevelInfo
+	LevelWarning
+	LevelError
+	LevelPanic
+	LevelFatal
+)
+
+var levelNames = []string{"trace", "info", "warning", "error", "panic", "fatal"}
+
+// String returns the name of a level in lower case.
+func (l Level) String() string {
+	if l < LevelTrace || l > LevelFatal {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level of a name, names are not case-sensitive.
+func ParseLevel(name string) (Level, error) {
+	for k, v := range levelNames {
+		if strings.EqualFold(name, v) {
+			return Level(k), nil
+		}
+	}
+	return LevelTrace, fmt.Errorf("logger: unknown level %q", name)
+}
+
+// Formats of log records.
+const (
+	FormatLogfmt = "logfmt"
+	FormatJSON   = "json"
+)
+
+// Config struct stores the minimum level and format of records and the writers records are written into.
+// Records are written into stderr when there is no writer.
+type Config struct {
+	Level   Level
+	Format  string
+	Writers []io.Writer
+}
+
+// output is the destination shared by all loggers.
+type output struct {
+	mu      sync.Mutex
+	level   Level
+	format  string
+	writers []io.Writer
+	writer  io.Writer
+}
+
+var std = &output{level: LevelTrace, format: FormatLogfmt, writer: os.Stderr}
+
 // Instantiate different log levels
 var (
-	Trace   *log.Logger // Just about anything
-	Info    *log.Logger // Important information
-	Warning *log.Logger // Be concerned
-	Error   *log.Logger // Critical problem
-	Panic   *log.Logger // When encounter panic
-	Fatal   *log.Logger // Failure
-	file    *os.File
-	err     error
+	Trace   = &Logger{level: LevelTrace}   // Just about anything
+	Info    = &Logger{level: LevelInfo}    // Important information
+	Warning = &Logger{level: LevelWarning} // Be concerned
+	Error   = &Logger{level: LevelError}   // Critical problem
+	Panic   = &Logger{level: LevelPanic}   // When encounter panic
+	Fatal   = &Logger{level: LevelFatal}   // Failure
 )
 
-// init creates and open a log file got logging,
-// base on current date time a new logfile will be created (log_YYYY_MM_DD.log).
-func init() {
-	t := time.Now()
-	fileName := fmt.Sprintf("log_%d_%02d_%02d.log", t.Year(), int(t.Month()), t.Day())
-	file, err = os.OpenFile("log/"+fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatalln("Failed to open error log file:", err)
+// Configure sets the minimum level, format and writers of all loggers.
+func Configure(config Config) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.level = config.Level
+	std.format = config.Format
+	if std.format != FormatJSON {
+		std.format = FormatLogfmt
+	}
+	std.writers = config.Writers
+	switch len(config.Writers) {
+	case 0:
+		std.writer = os.Stderr
+	case 1:
+		std.writer = config.Writers[0]
+	default:
+		std.writer = io.MultiWriter(config.Writers...)
 	}
-	Trace = log.New(io.MultiWriter(file, os.Stderr), "TRACE: ", log.Ldate|log.Ltime|log.Lshortfile)
-	Info = log.New(io.MultiWriter(file, os.Stderr), "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	Warning = log.New(io.MultiWriter(file, os.Stderr), "WARNING: ", log.Ldate|log.Ltime|log.Lshortfile)
-	Error = log.New(io.MultiWriter(file, os.Stderr), "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
-	Panic = log.New(io.MultiWriter(file, os.Stderr), "PANIC: ", log.Ldate|log.Ltime|log.Lshortfile)
-	Fatal = log.New(io.MultiWriter(file, os.Stderr), "FATAL: ", log.Ldate|log.Ltime|log.Lshortfile)
 }
 
-// CloseLogger closes the logfile when interrupted signal is detected.
+// SetLevel sets the minimum level of all loggers without changing the format and writers.
+func SetLevel(level Level) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.level = level
+}
+
+// SetFormat sets the format of all loggers without changing the level and writers, logfmt is used when not json.
+func SetFormat(format string) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.format = format
+	if std.format != FormatJSON {
+		std.format = FormatLogfmt
+	}
+}
+
+// CloseLogger closes the log files when interrupted signal is detected.
 func CloseLogger() {
-	err := file.Close()
-	if err != nil {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	for _, v := range std.writers {
+		if v == os.Stderr || v == os.Stdout {
+			continue
+		}
+		if closer, ok := v.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
+// Logger writes records of a level with fields added to every record.
+type Logger struct {
+	level  Level
+	fields []interface{}
+}
+
+// With returns a logger which adds key-value pairs to every record.
+func (l *Logger) With(keyValues ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyValues))
+	fields = append(fields, l.fields...)
+	return &Logger{level: l.level, fields: append(fields, keyValues...)}
+}
+
+// WithContext returns a logger which adds the request ID of ctx to every record.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	if id := RequestID(ctx); id != "" {
+		return l.With("requestID", id)
+	}
+	return l
+}
+
+// Enabled checks if records of the logger are written.
+func (l *Logger) Enabled() bool {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	return l.level >= std.level
+}
+
+// Log writes a record with a message and key-value pairs.
+func (l *Logger) Log(msg string, keyValues ...interface{}) {
+	l.output(msg, keyValues)
+}
+
+// Print writes a record with the message formatted as fmt.Sprint.
+func (l *Logger) Print(v ...interface{}) {
+	l.output(fmt.Sprint(v...), nil)
+}
+
+// Printf writes a record with the message formatted as fmt.Sprintf.
+func (l *Logger) Printf(format string, v ...interface{}) {
+	l.output(fmt.Sprintf(format, v...), nil)
+}
+
+// Println writes a record with the message formatted as fmt.Sprintln.
+func (l *Logger) Println(v ...interface{}) {
+	l.output(strings.TrimSuffix(fmt.Sprintln(v...), "\n"), nil)
+}
+
+// Fatal writes a record formatted as fmt.Sprint and exits the program.
+func (l *Logger) Fatal(v ...interface{}) {
+	l.output(fmt.Sprint(v...), nil)
+	os.Exit(1)
+}
+
+// Fatalf writes a record formatted as fmt.Sprintf and exits the program.
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.output(fmt.Sprintf(format, v...), nil)
+	os.Exit(1)
+}
+
+// Fatalln writes a record formatted as fmt.Sprintln and exits the program.
+func (l *Logger) Fatalln(v ...interface{}) {
+	l.output(strings.TrimSuffix(fmt.Sprintln(v...), "\n"), nil)
+	os.Exit(1)
+}
+
+// output writes a record into the writers when the level of the logger is enabled,
+// must be called directly by the exported methods for the caller to be reported correctly.
+func (l *Logger) output(msg string, keyValues []interface{}) {
+	now := time.Now()
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	if l.level < std.level {
 		return
 	}
+	caller := "???"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		caller = filepath.Base(file) + ":" + strconv.Itoa(line)
+	}
+	fields := []interface{}{"time", now.Format(time.RFC3339Nano), "level", l.level.String(), "caller", caller, "msg", msg}
+	fields = append(append(fields, l.fields...), keyValues...)
+	if len(fields)%2 != 0 {
+		fields = append(fields, "")
+	}
+	var record []byte
+	if std.format == FormatJSON {
+		record = encodeJSON(fields)
+	} else {
+		record = encodeLogfmt(fields)
+	}
+	_, _ = std.writer.Write(record)
+}
+
+// encodeLogfmt encodes key-value pairs as a logfmt line, values with spaces, quotes or equal signs are quoted.
+func encodeLogfmt(fields []interface{}) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(getKey(fields[i]))
+		buf.WriteByte('=')
+		value := getValue(fields[i+1])
+		if value == "" || strings.ContainsAny(value, " =\"\\") || strings.IndexFunc(value, func(r rune) bool { return r < ' ' }) >= 0 {
+			value = strconv.Quote(value)
+		}
+		buf.WriteString(value)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// encodeJSON encodes key-value pairs as a JSON object in the order given.
+func encodeJSON(fields []interface{}) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(getKey(fields[i]))
+		buf.Write(key)
+		buf.WriteByte(':')
+		var value []byte
+		switch v := fields[i+1].(type) {
+		case error:
+			value, _ = json.Marshal(v.Error())
+		case fmt.Stringer:
+			value, _ = json.Marshal(v.String())
+		default:
+			var err error
+			if value, err = json.Marshal(v); err != nil {
+				value, _ = json.Marshal(fmt.Sprint(v))
+			}
+		}
+		buf.Write(value)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// getKey returns a key of a key-value pair as string.
+func getKey(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
+}
+
+// getValue returns a value of a key-value pair as string.
+func getValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// contextKey is the key of the request ID stored in a context.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx which stores the ID of a request.
+func NewContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+// RequestID returns the ID of the request stored in ctx, empty if there's none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
 }
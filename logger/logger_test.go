@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogfmt(t *testing.T) {
+	var buf bytes.Buffer
+	Configure(Config{Level: LevelInfo, Writers: []io.Writer{&buf}})
+	defer Configure(Config{})
+
+	Trace.Println("hidden")
+	Info.With("user", "admin").WithContext(NewContext(context.Background(), "abc")).Printf("saved %v", 2)
+	Error.Log("failed", "err", errors.New("no such file"), "count", 3)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %v records, want 2: %q", len(lines), buf.String())
+	}
+	for _, want := range []string{"level=info", "caller=logger_test.go:", `msg="saved 2"`, "user=admin", "requestID=abc"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("record %q does not contain %q", lines[0], want)
+		}
+	}
+	if !strings.Contains(lines[1], `err="no such file" count=3`) {
+		t.Errorf("record %q does not contain fields", lines[1])
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	Configure(Config{Level: LevelTrace, Format: FormatJSON, Writers: []io.Writer{&buf}})
+	defer Configure(Config{})
+
+	Warning.Log("slow \"request\"\n", "duration", 1.5, "odd")
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["level"] != "warning" || record["msg"] != "slow \"request\"\n" || record["duration"] != 1.5 || record["odd"] != "" {
+		t.Errorf("unexpected record %v", record)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("WARNING"); err != nil || level != LevelWarning {
+		t.Errorf("ParseLevel(WARNING) = %v, %v", level, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(verbose) should fail")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "log")
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.Local)
+	file := NewRotatingFile(dir, 10, 48*time.Hour)
+	file.now = func() time.Time { return now }
+	defer file.Close()
+
+	// Expired files are removed when a file is opened
+	old := filepath.Join(dir, "log_2022_05_20.log")
+	_ = os.MkdirAll(dir, 0755)
+	_ = os.WriteFile(old, []byte("old\n"), 0644)
+	_ = os.Chtimes(old, now.AddDate(0, 0, -12), now.AddDate(0, 0, -12))
+
+	for _, v := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := file.Write([]byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = now.AddDate(0, 0, 1)
+	if _, err := file.Write([]byte("next day\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"log_2022_06_01.1.log": "first\n",
+		"log_2022_06_01.2.log": "second\n",
+		"log_2022_06_01.log":   "third\n",
+		"log_2022_06_02.log":   "next day\n",
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != len(want) {
+		t.Errorf("got %v files, want %v", len(entries), len(want))
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("%v = %q, %v, want %q", name, data, err, content)
+		}
+	}
+}
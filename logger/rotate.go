@@ -0,0 +1,197 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RotatingFile is a log file in Dir named after the current date (log_YYYY_MM_DD.log).
+// A new file is opened when the date changes, a file which reaches MaxSize bytes is renamed
+// with a sequence number (log_YYYY_MM_DD.1.log) before a new file is opened.
+// Log files last modified more than MaxAge ago are removed on rotation.
+// There's no size limit when MaxSize is 0 and log files are kept when MaxAge is 0.
+// Lines are chained by HMAC and rotated files are sealed with a manifest when Key is set.
+type RotatingFile struct {
+	Dir     string
+	MaxSize int64
+	MaxAge  time.Duration
+	Key     []byte
+
+	mu        sync.Mutex
+	file      *os.File
+	date      string
+	size      int64
+	lines     int
+	lastMAC   string
+	signature string
+	loaded    bool
+	now       func() time.Time
+}
+
+// NewRotatingFile will return a newly created rotating log file in dir, the file is opened on first write.
+func NewRotatingFile(dir string, maxSize int64, maxAge time.Duration) *RotatingFile {
+	return &RotatingFile{Dir: dir, MaxSize: maxSize, MaxAge: maxAge, now: time.Now}
+}
+
+// Write appends p into the log file of the current date, the file is rotated before p is written when
+// the date changed or the file would exceed MaxSize. The directory is created if it does not exist.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	date := r.now().Format("2006_01_02")
+	if r.file == nil || date != r.date || (r.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxSize) {
+		if err := r.rotate(date); err != nil {
+			return 0, err
+		}
+	}
+	if r.Key == nil {
+		n, err := r.file.Write(p)
+		r.size += int64(n)
+		return n, err
+	}
+
+	// Every line is signed, the whole write is still appended at once
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var signed []byte
+		signed, r.lastMAC = signLine(r.Key, r.lastMAC, bytes.TrimSuffix(line, []byte("\n")))
+		buf.Write(append(signed, '\n'))
+		r.lines++
+	}
+	n, err := r.file.Write(buf.Bytes())
+	r.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// rotate closes and seals the current file and opens the file of date, the current file is renamed
+// with the next sequence number when the date has not changed.
+func (r *RotatingFile) rotate(date string) error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+		r.file = nil
+		path := r.getPath(r.date, 0)
+		if date == r.date {
+			var err error
+			if path, err = r.renameFull(); err != nil {
+				return err
+			}
+		}
+		if r.Key != nil {
+			signature, err := seal(path, r.Key, r.lines, r.lastMAC, r.signature, r.now())
+			if err != nil {
+				return err
+			}
+			r.signature = signature
+		}
+	}
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return err
+	}
+	path := r.getPath(date, 0)
+	if r.Key != nil {
+		if !r.loaded {
+			r.sealPending(path)
+			r.loaded = true
+		}
+		// Lines written before restart are continued
+		var err error
+		if r.lines, r.lastMAC, err = getLastMAC(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	r.file, r.date, r.size = file, date, info.Size()
+	r.removeExpired()
+	return nil
+}
+
+// sealPending continues the chain of manifests from the last sealed file and seals log files
+// left by previous runs except current. Files are only sealed when all lines match their MAC.
+func (r *RotatingFile) sealPending(current string) {
+	if manifests := readManifests(r.Dir); len(manifests) > 0 {
+		r.signature = manifests[len(manifests)-1].Signature
+	}
+	paths, _ := filepath.Glob(filepath.Join(r.Dir, "log_*.log"))
+	sort.Strings(paths)
+	for _, v := range paths {
+		if _, err := os.Stat(v + manifestExt); v == current || err == nil {
+			continue
+		}
+		lines, lastMAC, err := verifyLines(v, r.Key, true)
+		if err != nil {
+			continue
+		}
+		if signature, err := seal(v, r.Key, lines, lastMAC, r.signature, r.now()); err == nil {
+			r.signature = signature
+		}
+	}
+}
+
+// renameFull renames the full log file of the current date with the next unused sequence number, returns the new path.
+func (r *RotatingFile) renameFull() (string, error) {
+	for seq := 1; ; seq++ {
+		path := r.getPath(r.date, seq)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path, os.Rename(r.getPath(r.date, 0), path)
+		}
+	}
+}
+
+// removeExpired removes log files and their manifests last modified more than MaxAge ago, the current file is kept.
+func (r *RotatingFile) removeExpired() {
+	if r.MaxAge <= 0 {
+		return
+	}
+	paths, _ := filepath.Glob(filepath.Join(r.Dir, "log_*.log"))
+	cutOff := r.now().Add(-r.MaxAge)
+	for _, v := range paths {
+		info, err := os.Stat(v)
+		if err != nil || v == r.file.Name() || !info.ModTime().Before(cutOff) {
+			continue
+		}
+		_ = os.Remove(v)
+		_ = os.Remove(v + manifestExt)
+	}
+}
+
+// getPath returns the path of the log file of date, full log files have a sequence number.
+func (r *RotatingFile) getPath(date string, seq int) string {
+	name := "log_" + date
+	if seq > 0 {
+		name += fmt.Sprintf(".%d", seq)
+	}
+	return filepath.Join(r.Dir, name+".log")
+}
+
+// Close closes the current log file, a new file is opened on next write.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,7 @@
+module GoSchool_Assignment4/logverify
+
+go 1.18
+
+require github.com/shiweii/logger v0.0.0-00010101000000-000000000000
+
+replace github.com/shiweii/logger => ../logger
@@ -0,0 +1,78 @@
+// Command logverify checks the HMAC chain of log files written with a key and the manifests of sealed files,
+// the first tampered line of every file is reported. Exits with status 1 when tampering is found.
+//
+// Usage:
+//
+//	logverify [-dir log] [-key-file path] [file ...]
+//
+// All log files in dir are verified when no file is given. The key is read from key-file,
+// or the LOG_HMAC_KEY environment variable when no key file is given.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shiweii/logger"
+)
+
+func main() {
+	dir := flag.String("dir", "log", "directory of log files")
+	keyFile := flag.String("key-file", "", "file containing the HMAC key, LOG_HMAC_KEY is used when empty")
+	flag.Parse()
+
+	key := []byte(os.Getenv("LOG_HMAC_KEY"))
+	if *keyFile != "" {
+		data, err := os.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		key = []byte(strings.TrimSpace(string(data)))
+	}
+	if len(key) == 0 {
+		fmt.Fprintln(os.Stderr, "logverify: no key, set LOG_HMAC_KEY or -key-file")
+		os.Exit(2)
+	}
+
+	var results []logger.FileResult
+	if flag.NArg() == 0 {
+		var err error
+		if results, err = logger.VerifyDir(*dir, key); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	for _, v := range flag.Args() {
+		lines, err := logger.VerifyFile(v, key)
+		_, sealed := os.Stat(v + ".manifest")
+		results = append(results, logger.FileResult{File: filepath.Base(v), Lines: lines, Sealed: sealed == nil, Err: err})
+	}
+
+	tampered := false
+	for _, v := range results {
+		var tamperErr *logger.TamperError
+		switch {
+		case errors.As(v.Err, &tamperErr):
+			tampered = true
+			if tamperErr.Line > 0 {
+				fmt.Printf("TAMPERED %v line %d: %v\n", tamperErr.File, tamperErr.Line, tamperErr.Reason)
+			} else {
+				fmt.Printf("TAMPERED %v: %v\n", tamperErr.File, tamperErr.Reason)
+			}
+		case v.Err != nil:
+			fmt.Printf("ERROR    %v: %v\n", v.File, v.Err)
+		case v.Sealed:
+			fmt.Printf("OK       %v: %d lines, sealed\n", v.File, v.Lines)
+		default:
+			fmt.Printf("OK       %v: %d lines\n", v.File, v.Lines)
+		}
+	}
+	if tampered {
+		os.Exit(1)
+	}
+}
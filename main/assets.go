@@ -0,0 +1,125 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/shiweii/config"
+	"github.com/shiweii/i18n"
+	"github.com/shiweii/logger"
+)
+
+// webFiles are the templates of pages and the stylesheets, scripts and vendored Bootstrap files served at /static/,
+// embedded so that the server is able to start from any working directory.
+//
+//go:embed templates static
+var webFiles embed.FS
+
+// cdnOrigin is the CDN vendored files are loaded from, cdnURL is the base URL of vendored files on the CDN.
+const (
+	cdnOrigin = "https://cdn.jsdelivr.net"
+	cdnURL    = cdnOrigin + "/npm/"
+)
+
+// vendoredAssets are the files loaded by pages, downloaded into static/vendor by static/vendor/fetch.sh.
+var vendoredAssets = []string{
+	"bootstrap@5.1.3/dist/css/bootstrap.min.css",
+	"bootstrap@5.1.2/dist/js/bootstrap.bundle.min.js",
+	"bootstrap-icons@1.8.1/font/bootstrap-icons.css",
+	"bootstrap-icons@1.8.1/font/fonts/bootstrap-icons.woff2",
+	"bootstrap-icons@1.8.1/font/fonts/bootstrap-icons.woff",
+}
+
+// hasVendoredAssets is true when all vendored files are embedded.
+var hasVendoredAssets = checkVendoredAssets(webFiles)
+
+// getWebFiles returns the templates and static files, read from OVERRIDE_DIR when declared.
+func getWebFiles() fs.FS {
+	if dir := config.Get().OverrideDir; dir != "" {
+		return os.DirFS(dir)
+	}
+	return webFiles
+}
+
+// templateSet stores the templates of pages parsed for every locale by locale.
+type templateSet map[string]*template.Template
+
+// parseTemplates parses the templates of pages for every locale, with the functions which translate messages
+// and format dates in the locale.
+func parseTemplates(files fs.FS) (templateSet, error) {
+	set := templateSet{}
+	for _, locale := range i18n.Locales {
+		t, err := template.New("").Funcs(fm).Funcs(getLocaleFuncs(locale)).ParseFS(files, "templates/*")
+		if err != nil {
+			return nil, err
+		}
+		set[locale] = t
+	}
+	return set, nil
+}
+
+// getTemplateSet returns the templates of pages, templates of OVERRIDE_DIR are parsed on every call
+// so that changes are displayed without restarting the server.
+func getTemplateSet() templateSet {
+	if config.Get().OverrideDir == "" {
+		return tpl
+	}
+	set, err := parseTemplates(getWebFiles())
+	if err != nil {
+		logger.Error.Println(err)
+		return tpl
+	}
+	return set
+}
+
+// getTemplates returns the templates of pages in the locale of a request.
+func getTemplates(req *http.Request) *template.Template {
+	return getTemplateSet()[getLocale(req)]
+}
+
+// checkVendoredAssets checks if all vendored files are in static/vendor of files.
+func checkVendoredAssets(files fs.FS) bool {
+	for _, v := range vendoredAssets {
+		if _, err := fs.Stat(files, path.Join("static/vendor", v)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// useLocalAssets checks if vendored files are served by the server, files are loaded from the CDN
+// when ASSET_SOURCE is cdn or the vendored files were not downloaded before the server was built.
+func useLocalAssets() bool {
+	if config.Get().AssetSource != "local" {
+		return false
+	}
+	if config.Get().OverrideDir != "" {
+		return checkVendoredAssets(getWebFiles())
+	}
+	return hasVendoredAssets
+}
+
+// getAssetURL returns the URL of a vendored file on the server or the CDN.
+func getAssetURL(name string) string {
+	if useLocalAssets() {
+		return "/static/vendor/" + name
+	}
+	return cdnURL + name
+}
+
+// staticHandler serves the static files, directories are not listed.
+func staticHandler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/") {
+			renderError(res, req, http.StatusNotFound)
+			return
+		}
+		res.Header().Set("Cache-Control", "public, max-age=86400")
+		http.FileServer(http.FS(getWebFiles())).ServeHTTP(res, req)
+	})
+}
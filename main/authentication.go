@@ -1,19 +1,86 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"sync"
 	"time"
 
-	util "github.com/shiweii/utility"
+	"github.com/shiweii/config"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/shiweii/user"
 )
 
+// sessionMap stores the username of every session ID, sessions are accessed by concurrent requests
+// and by the metrics handler counting active sessions.
+type sessionMap struct {
+	mu       sync.RWMutex
+	sessions map[string]string
+}
+
+// newSessionMap will return a newly created instance of an empty session map.
+func newSessionMap() *sessionMap {
+	return &sessionMap{sessions: make(map[string]string)}
+}
+
+// Get returns the username of a session.
+func (s *sessionMap) Get(sessionID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	username, ok := s.sessions[sessionID]
+	return username, ok
+}
+
+// Set stores the username of a session.
+func (s *sessionMap) Set(sessionID, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = username
+}
+
+// Delete deletes a session and returns the username of the session deleted.
+func (s *sessionMap) Delete(sessionID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	username, ok := s.sessions[sessionID]
+	delete(s.sessions, sessionID)
+	return username, ok
+}
+
+// deleteByUsername deletes all sessions of a user except the session excluded.
+func (s *sessionMap) deleteByUsername(username, excluded string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.sessions {
+		if k != excluded && v == username {
+			delete(s.sessions, k)
+		}
+	}
+}
+
+// GetAll returns a copy of all sessions.
+func (s *sessionMap) GetAll() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := make(map[string]string, len(s.sessions))
+	for k, v := range s.sessions {
+		sessions[k] = v
+	}
+	return sessions
+}
+
+// Len returns the number of active sessions.
+func (s *sessionMap) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.sessions)
+}
+
 // createNewSecureCookie creates and return a new secure cookie.
 func createNewSecureCookie() *http.Cookie {
 	cookie := &http.Cookie{
-		Name:     util.GetEnvVar("COOKIE_NAME"),
+		Name:     config.Get().CookieName,
 		Expires:  time.Now().AddDate(0, 0, 1),
 		Value:    uuid.NewV4().String(),
 		HttpOnly: true,
@@ -28,7 +95,7 @@ func createNewSecureCookie() *http.Cookie {
 func expireCookie() *http.Cookie {
 	cookie := &http.Cookie{
 		Path:    "/",
-		Name:    util.GetEnvVar("COOKIE_NAME"),
+		Name:    config.Get().CookieName,
 		Domain:  "localhost",
 		MaxAge:  -1,
 		Expires: time.Now().Add(-100 * time.Hour),
@@ -36,40 +103,44 @@ func expireCookie() *http.Cookie {
 	return cookie
 }
 
-// authenticationCheck checks user authentication and returns the appropriate redirection code.
-func authenticationCheck(res http.ResponseWriter, req *http.Request, userList *user.DoublyLinkedList, checkAdmin bool) (*user.User, bool, int) {
+// authenticate returns the logged-in user of a request, nil when not logged in.
+func authenticate(res http.ResponseWriter, req *http.Request, userList *user.DoublyLinkedList) *user.User {
 	// Check if users is logged in
 	if !alreadyLoggedIn(req, userList) {
 		// Expire cookie if user's session was ended by admin
 		// to prevent attacker from reusing this cookie
-		cookie, err := req.Cookie(util.GetEnvVar("COOKIE_NAME"))
+		cookie, err := req.Cookie(config.Get().CookieName)
 		if err == nil {
 			cookie = expireCookie()
 			http.SetCookie(res, cookie)
 		}
-		return nil, true, http.StatusSeeOther
+		return nil
 	}
 	// Get info of logged-in user
-	myUser := getUser(res, req, userList)
-	if myUser == nil {
-		return nil, true, http.StatusSeeOther
-	}
-	// Allow access for admin only
-	if checkAdmin {
-		if myUser.Role != enumAdmin {
-			return nil, true, http.StatusUnauthorized
-		}
-	}
-	return myUser, false, 0
+	return getUser(res, req, userList)
+}
+
+// sessionUserKey is the key of the logged-in user in the request context.
+type sessionUserKey struct{}
+
+// withSessionUser returns a copy of ctx which stores the logged-in user.
+func withSessionUser(ctx context.Context, myUser *user.User) context.Context {
+	return context.WithValue(ctx, sessionUserKey{}, myUser)
+}
+
+// getSessionUser returns the logged-in user stored in the request context by requireUser, nil when not stored.
+func getSessionUser(req *http.Request) *user.User {
+	myUser, _ := req.Context().Value(sessionUserKey{}).(*user.User)
+	return myUser
 }
 
 // alreadyLoggedIn checks is user's session exist in session map.
 func alreadyLoggedIn(req *http.Request, userList *user.DoublyLinkedList) bool {
-	cookie, err := req.Cookie(util.GetEnvVar("COOKIE_NAME"))
+	cookie, err := req.Cookie(config.Get().CookieName)
 	if err != nil {
 		return false
 	}
-	username := mapSessions[cookie.Value]
+	username, _ := mapSessions.Get(cookie.Value)
 	ret := (*userList).FindByUsername(username)
 	return ret != nil
 }
@@ -77,11 +148,11 @@ func alreadyLoggedIn(req *http.Request, userList *user.DoublyLinkedList) bool {
 // getUser get user struct from linked list.
 func getUser(res http.ResponseWriter, req *http.Request, userList *user.DoublyLinkedList) *user.User {
 	// get current session cookie
-	cookie, err := req.Cookie(util.GetEnvVar("COOKIE_NAME"))
+	cookie, err := req.Cookie(config.Get().CookieName)
 	if err != nil {
 		id := uuid.NewV4()
 		cookie = &http.Cookie{
-			Name:   util.GetEnvVar("COOKIE_NAME"),
+			Name:   config.Get().CookieName,
 			Value:  id.String(),
 			Domain: "localhost",
 		}
@@ -89,7 +160,7 @@ func getUser(res http.ResponseWriter, req *http.Request, userList *user.DoublyLi
 	}
 	// if the user exists already, get user
 	var myUser *user.User
-	if username, ok := mapSessions[cookie.Value]; ok {
+	if username, ok := mapSessions.Get(cookie.Value); ok {
 		userObj := (*userList).FindByUsername(username)
 		myUser = userObj
 	}
@@ -99,23 +170,10 @@ func getUser(res http.ResponseWriter, req *http.Request, userList *user.DoublyLi
 // terminateOtherSession searches and terminates existing session
 // when a new session is created for the same user.
 func terminateOtherSession(sessionID, username string) {
-	// Loop session Map
-	for k, v := range mapSessions {
-		// If same user but different session, remove session from session map
-		if k != sessionID && v == username {
-			delete(mapSessions, k)
-		}
-	}
+	mapSessions.deleteByUsername(username, sessionID)
 }
 
 // deleteSessionByUsername deletes user's session in session map
 func deleteSessionByUsername(username string) {
-	// Loop session Map
-	for k, v := range mapSessions {
-		// If map value equals username
-		if v == username {
-			// Remove session from session map
-			delete(mapSessions, k)
-		}
-	}
+	mapSessions.deleteByUsername(username, "")
 }
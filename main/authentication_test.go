@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestSessionMap checks sessions are able to be changed by concurrent requests while being counted.
+func TestSessionMap(t *testing.T) {
+	sessions := newSessionMap()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			sessions.Set(strconv.Itoa(i), "patient1")
+		}(i)
+		go func() {
+			defer wg.Done()
+			sessions.Len()
+		}()
+	}
+	wg.Wait()
+	if got := sessions.Len(); got != 10 {
+		t.Errorf("got %d sessions, want 10", got)
+	}
+
+	sessions.Set("admin", "admin")
+	sessions.deleteByUsername("patient1", "3")
+	if got := sessions.GetAll(); len(got) != 2 || got["3"] != "patient1" || got["admin"] != "admin" {
+		t.Errorf("got %v, want sessions 3 and admin", got)
+	}
+	if username, ok := sessions.Delete("3"); !ok || username != "patient1" {
+		t.Errorf("got %v, %v, want patient1", username, ok)
+	}
+	if _, ok := sessions.Get("3"); ok {
+		t.Error("session 3 should be deleted")
+	}
+}
@@ -6,9 +6,18 @@ require (
 	github.com/gorilla/mux v1.8.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/shiweii/appointment v0.0.0-00010101000000-000000000000
+	github.com/shiweii/audit v0.0.0-00010101000000-000000000000
 	github.com/shiweii/binarysearchtree v0.0.0-00010101000000-000000000000
+	github.com/shiweii/config v0.0.0-00010101000000-000000000000
+	github.com/shiweii/cryptography v0.0.0-00010101000000-000000000000
+	github.com/shiweii/document v0.0.0-00010101000000-000000000000
 	github.com/shiweii/doublylinkedlist v0.0.0-00010101000000-000000000000
+	github.com/shiweii/i18n v0.0.0-00010101000000-000000000000
+	github.com/shiweii/integrity v0.0.0-00010101000000-000000000000
 	github.com/shiweii/logger v0.0.0-00010101000000-000000000000
+	github.com/shiweii/metrics v0.0.0-00010101000000-000000000000
+	github.com/shiweii/notification v0.0.0-00010101000000-000000000000
+	github.com/shiweii/spreadsheet v0.0.0-00010101000000-000000000000
 	github.com/shiweii/user v0.0.0-00010101000000-000000000000
 	github.com/shiweii/utility v0.0.0-00010101000000-000000000000
 	github.com/shiweii/validator v0.0.0-00010101000000-000000000000
@@ -16,10 +25,23 @@ require (
 )
 
 require (
+	github.com/BurntSushi/toml v1.2.1 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/joho/godotenv v1.4.0 // indirect
-	github.com/shiweii/cryptography v0.0.0-00010101000000-000000000000 // indirect
+	github.com/jung-kurt/gofpdf v1.16.2 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_golang v1.14.0 // indirect
+	github.com/prometheus/client_model v0.3.0 // indirect
+	github.com/prometheus/common v0.37.0 // indirect
+	github.com/prometheus/procfs v0.8.0 // indirect
+	golang.org/x/sys v0.7.0 // indirect
 	golang.org/x/text v0.3.7 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 replace github.com/shiweii/binarysearchtree => ../binarysearchtree
@@ -37,3 +59,19 @@ replace github.com/shiweii/doublylinkedlist => ../doublylinkedlist
 replace github.com/shiweii/user => ../user
 
 replace github.com/shiweii/validator => ../validator
+
+replace github.com/shiweii/notification => ../notification
+
+replace github.com/shiweii/spreadsheet => ../spreadsheet
+
+replace github.com/shiweii/document => ../document
+
+replace github.com/shiweii/audit => ../audit
+
+replace github.com/shiweii/integrity => ../integrity
+
+replace github.com/shiweii/config => ../config
+
+replace github.com/shiweii/metrics => ../metrics
+
+replace github.com/shiweii/i18n => ../i18n
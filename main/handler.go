@@ -1,15 +1,34 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
 	app "github.com/shiweii/appointment"
+	"github.com/shiweii/audit"
+	"github.com/shiweii/config"
+	"github.com/shiweii/cryptography"
+	"github.com/shiweii/document"
 	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/i18n"
+	"github.com/shiweii/integrity"
 	"github.com/shiweii/logger"
+	"github.com/shiweii/metrics"
+	"github.com/shiweii/notification"
+	"github.com/shiweii/spreadsheet"
 	"github.com/shiweii/user"
 	util "github.com/shiweii/utility"
 	"github.com/shiweii/validator"
@@ -24,41 +43,65 @@ const (
 	enumUpcoming = "upcoming"
 )
 
+// clinicName is printed on documents and displayed on the index page.
+const clinicName = "Central City Dentist Clinic"
+
+// auditPageSize is the maximum number of audit log entries displayed, all matching entries are exported.
+const auditPageSize = 200
+
+// importMaxSize is the maximum size of an imported file in bytes.
+const importMaxSize = 5 << 20
+
+// rescheduleSearchDays is the number of days searched for the next available slot when rescheduling.
+const rescheduleSearchDays = 60
+
+// open slot search limits.
+const (
+	slotSearchDefaultDays  = 30
+	slotSearchMaxDays      = 90
+	slotSearchDefaultLimit = 20
+	slotSearchMaxLimit     = 100
+)
+
+// page struct stores the data of the header shared by all pages, the ViewData of every page embeds page.
+// CurrentPage is the code of the active navigation item, Nonce is the nonce of inline scripts.
+type page struct {
+	LoggedInUser *user.User
+	PageTitle    string
+	CurrentPage  string
+	Nonce        string
+}
+
+// newPage returns the page data of the logged-in user and nonce stored in the request context,
+// title is translated into the locale of the request.
+func newPage(req *http.Request, title, current string) page {
+	return page{getSessionUser(req), translate(req, title), current, getNonce(req)}
+}
+
 // indexHandler handles request to display index page.
 func indexHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
 		if alreadyLoggedIn(req, userList) {
 			http.Redirect(res, req, "/appointments", http.StatusSeeOther)
 			return
 		}
 
 		// Expire cookie if user's session was removed by admin
-		cookie, err := req.Cookie(util.GetEnvVar("COOKIE_NAME"))
+		cookie, err := req.Cookie(config.Get().CookieName)
 		if err == nil {
-			username := mapSessions[cookie.Value]
-			if username == "" {
+			if _, ok := mapSessions.Get(cookie.Value); !ok {
 				cookie = expireCookie()
 				http.SetCookie(res, cookie)
 			}
 		}
 
 		ViewData := struct {
-			LoggedInUser *user.User
-			PageTitle    string
+			page
 		}{
-			nil,
-			"Central City Dentist Clinic",
+			newPage(req, clinicName, ""),
 		}
 
-		if err := tpl.ExecuteTemplate(res, "index.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "index.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
@@ -67,43 +110,36 @@ func indexHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 // signupHandler handles request to create a new user.
 func signupHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				util.CheckEncryption()
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
 		if alreadyLoggedIn(req, userList) {
 			http.Redirect(res, req, "/", http.StatusSeeOther)
 			return
 		}
 
 		ViewData := struct {
-			LoggedInUser         *user.User
-			PageTitle            string
+			page
 			ValidateFirstName    bool
 			ValidateLastName     bool
 			ValidateUserName     bool
 			UserNameTaken        bool
 			ValidatePassword     bool
 			ValidateMobileNumber bool
+			ValidateEmail        bool
 			InputUserName        string
 			InputPassword        string
 			InputFirstName       string
 			InputLastName        string
 			InputMobileNumber    string
+			InputEmail           string
 		}{
-			nil,
-			"Sign Up",
+			newPage(req, "Sign Up", ""),
 			true,
 			true,
 			true,
 			false,
 			true,
 			true,
+			true,
+			"",
 			"",
 			"",
 			"",
@@ -119,8 +155,9 @@ func signupHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 			ViewData.InputFirstName = strings.TrimSpace(req.FormValue("firstname"))
 			ViewData.InputLastName = strings.TrimSpace(req.FormValue("lastname"))
 			ViewData.InputMobileNumber = strings.TrimSpace(req.FormValue("mobileNum"))
+			ViewData.InputEmail = strings.TrimSpace(req.FormValue("email"))
 
-			logger.Trace.Printf("%v: Username: %v, FirstName: %v, LastName: %v, MobileNumber: %v", util.CurrFuncName(), ViewData.InputUserName, ViewData.InputFirstName, ViewData.InputLastName, ViewData.InputMobileNumber)
+			logger.Trace.Printf("%v: Username: %v, FirstName: %v, LastName: %v, MobileNumber: %v, Email: %v", util.CurrFuncName(), ViewData.InputUserName, ViewData.InputFirstName, ViewData.InputLastName, ViewData.InputMobileNumber, ViewData.InputEmail)
 
 			//Validate Fields
 			if validator.IsEmpty(ViewData.InputUserName) || !validator.IsValidUsername(ViewData.InputUserName) {
@@ -145,14 +182,18 @@ func signupHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 			if validator.IsEmpty(ViewData.InputMobileNumber) || !validator.IsMobileNumber(ViewData.InputMobileNumber) {
 				ViewData.ValidateMobileNumber = false
 			}
+			// Email is optional, used for appointment reminders
+			if !validator.IsEmpty(ViewData.InputEmail) && !validator.IsEmail(ViewData.InputEmail) {
+				ViewData.ValidateEmail = false
+			}
 
 			// If all validations are true
-			if ViewData.ValidateFirstName && ViewData.ValidateLastName && ViewData.ValidateUserName && ViewData.ValidatePassword && ViewData.ValidateMobileNumber {
+			if ViewData.ValidateFirstName && ViewData.ValidateLastName && ViewData.ValidateUserName && ViewData.ValidatePassword && ViewData.ValidateMobileNumber && ViewData.ValidateEmail {
 				var myUser user.User
 				// create session
 				cookie := createNewSecureCookie()
 				http.SetCookie(res, cookie)
-				mapSessions[cookie.Value] = ViewData.InputUserName
+				mapSessions.Set(cookie.Value, ViewData.InputUserName)
 
 				bPassword, err := bcrypt.GenerateFromPassword([]byte(ViewData.InputPassword), bcrypt.MinCost)
 				if err != nil {
@@ -168,20 +209,22 @@ func signupHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 				myUser.Role = enumPatient
 				mobileNum, _ := strconv.Atoi(ViewData.InputMobileNumber)
 				myUser.MobileNumber = mobileNum
+				myUser.Email = ViewData.InputEmail
 
 				// Add into linklist and JSON
 				if err = (*userList).Add(&myUser); err != nil {
-					logger.Error.Printf("%v: Error:", util.CurrFuncName(), err)
+					logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
 				} else {
 					(*userList).InsertionSort()
 					user.AddUserDate(&myUser)
+					recordAudit(req, myUser.Username, "user.create", myUser.Username, nil, &myUser)
 				}
 				// redirect to patient landing page
 				http.Redirect(res, req, "/appointments", http.StatusSeeOther)
 				return
 			}
 		}
-		if err := tpl.ExecuteTemplate(res, "signup.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "signup.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
@@ -190,26 +233,16 @@ func signupHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 // loginHandler handles request to log in existing user.
 func loginHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
 		if alreadyLoggedIn(req, userList) {
 			http.Redirect(res, req, "/", http.StatusSeeOther)
 			return
 		}
 
 		ViewData := struct {
-			LoggedInUser *user.User
-			PageTitle    string
-			LoginFail    bool
+			page
+			LoginFail bool
 		}{
-			nil,
-			"Login",
+			newPage(req, "Login", ""),
 			false,
 		}
 
@@ -251,17 +284,18 @@ func loginHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 				}
 			}
 
+			metrics.CountLogin(!ViewData.LoginFail)
 			if !ViewData.LoginFail {
 				cookie := createNewSecureCookie()
 				go terminateOtherSession(cookie.Value, ViewData.LoggedInUser.Username)
 				http.SetCookie(res, cookie)
-				mapSessions[cookie.Value] = ViewData.LoggedInUser.Username
+				mapSessions.Set(cookie.Value, ViewData.LoggedInUser.Username)
 				logger.Info.Printf("%v: Login successful. user:%v", util.CurrFuncName(), ViewData.LoggedInUser.Username)
 				http.Redirect(res, req, "/appointments", http.StatusSeeOther)
 				return
 			}
 		}
-		if err := tpl.ExecuteTemplate(res, "login.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "login.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
@@ -274,13 +308,12 @@ func logoutHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 			http.Redirect(res, req, "/", http.StatusSeeOther)
 			return
 		}
-		cookie, err := req.Cookie(util.GetEnvVar("COOKIE_NAME"))
+		cookie, err := req.Cookie(config.Get().CookieName)
 		if err == nil {
 			// Get username
-			username, _ := mapSessions[cookie.Value]
-			logger.Info.Printf("%v: Logout... user [%v]", util.CurrFuncName(), username)
 			// delete the session
-			delete(mapSessions, cookie.Value)
+			username, _ := mapSessions.Delete(cookie.Value)
+			logger.Info.Printf("%v: Logout... user [%v]", util.CurrFuncName(), username)
 			// Expire the cookie
 			cookie = expireCookie()
 			http.SetCookie(res, cookie)
@@ -291,40 +324,30 @@ func logoutHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 
 // logoutHandler handles request to list all applications.
 // Admin has the ability to search all appointments.
-func appointmentListHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree) http.HandlerFunc {
+func appointmentListHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, false)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
+		myUser := getSessionUser(req)
 
 		ViewData := struct {
-			LoggedInUser *user.User
-			PageTitle    string
-			CurrentPage  string
+			page
 			Appointments []*app.Appointment
 			Sessions     []interface{}
 			Dentists     []*user.User
 			Option       string
 			TodayDate    string
+			Types        app.AppointmentTypes
+			Clinics      app.Clinics
+			ScheduleDate string
 		}{
-			myUser,
-			"Appointments",
-			"MA",
+			newPage(req, "Appointments", "MA"),
 			nil,
 			(**appointmentSessionList).GetList(),
 			(*userList).GetDentistList(),
 			"",
 			time.Now().Format("2006-01-02"),
+			appointmentTypes,
+			appointmentClinics,
+			"",
 		}
 
 		//var appointments []*bst.BinaryNode
@@ -335,6 +358,16 @@ func appointmentListHandler(userList *user.DoublyLinkedList, appointmentSessionL
 			ViewData.Appointments = (*appointmentTree).GetAllAppointments(nil, "")
 		}
 
+		// If logged in as dentist, display the schedule of the selected date, today by default
+		if myUser.Role == enumDentist {
+			ViewData.PageTitle = "My Schedule"
+			ViewData.ScheduleDate = ViewData.TodayDate
+			if scheduleDate, err := time.Parse("2006-01-02", strings.TrimSpace(req.FormValue("date"))); err == nil {
+				ViewData.ScheduleDate = scheduleDate.Format("2006-01-02")
+			}
+			ViewData.Appointments = getDailyAppointments(appointmentTree, ViewData.ScheduleDate, myUser)
+		}
+
 		// If logged in as patient, display appointments based on selection
 		if myUser.Role == enumPatient {
 			if len(ViewData.Option) == 0 {
@@ -353,21 +386,24 @@ func appointmentListHandler(userList *user.DoublyLinkedList, appointmentSessionL
 			inputDate := strings.TrimSpace(req.FormValue("inputDate"))
 			inputPatientMobileNumber := strings.TrimSpace(req.FormValue("inputPatientMobileNumber"))
 			inputSession := strings.TrimSpace(req.FormValue("inputSession"))
+			inputBranch := strings.TrimSpace(req.FormValue("inputBranch"))
 
 			// Data conversation
 			dentist := (*userList).FindByUsername(inputDentist)
 			appointmentDate, _ := time.Parse("2006-01-02", inputDate)
 			appointmentSession, _ := strconv.Atoi(inputSession)
 			patientMobileNumber, _ := strconv.Atoi(inputPatientMobileNumber)
+			clinic := appointmentClinics.Get(inputBranch)
 
 			// If inputs are valid
-			if !(dentist == nil && len(inputDate) == 0 && appointmentSession == 0 && len(inputPatientMobileNumber) == 0) {
+			if !(dentist == nil && len(inputDate) == 0 && appointmentSession == 0 && len(inputPatientMobileNumber) == 0 && clinic == nil) {
 
 				// Initialize channels
 				chSearchDate := make(chan []*app.Appointment)
 				chSearchPatient := make(chan []*app.Appointment)
 				chSearchDentist := make(chan []*app.Appointment)
 				chSearchSession := make(chan []*app.Appointment)
+				chSearchClinic := make(chan []*app.Appointment)
 				filterCount := 0
 
 				if dentist != nil {
@@ -387,6 +423,10 @@ func appointmentListHandler(userList *user.DoublyLinkedList, appointmentSessionL
 					filterCount++
 					go (*appointmentTree).SearchAllByField("session", appointmentSession, chSearchSession)
 				}
+				if clinic != nil {
+					filterCount++
+					go (*appointmentTree).SearchAllByField("clinic", clinic, chSearchClinic)
+				}
 
 				var result []*app.Appointment
 				for i := 0; i < filterCount; i++ {
@@ -399,12 +439,14 @@ func appointmentListHandler(userList *user.DoublyLinkedList, appointmentSessionL
 						result = append(result, ret3...)
 					case ret4 := <-chSearchSession:
 						result = append(result, ret4...)
+					case ret5 := <-chSearchClinic:
+						result = append(result, ret5...)
 					}
 				}
 				ViewData.Appointments = app.GetDuplicate(result, filterCount)
 			}
 		}
-		if err := tpl.ExecuteTemplate(res, "appointmentList.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "appointmentList.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
@@ -412,42 +454,28 @@ func appointmentListHandler(userList *user.DoublyLinkedList, appointmentSessionL
 
 // appointmentSearchHandler handles request search for dentist availability,
 // patients are able creates a new appointment using this function.
-func appointmentSearchHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree) http.HandlerFunc {
+func appointmentSearchHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, appointmentClinics app.Clinics) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, false)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
-
 		ViewData := struct {
-			LoggedInUser    *user.User
-			PageTitle       string
-			CurrentPage     string
+			page
 			Dentist         *user.User
 			Dentists        []*user.User
 			TodayDate       string
 			DentistsSession []app.AppSession
 			SelectedDate    string
 			FormProcessed   bool
+			ClinicClosed    *app.Holiday
+			FullyBooked     bool
 		}{
-			myUser,
-			"Search Available Appointment",
-			"SAA",
+			newPage(req, "Search Available Appointment", "SAA"),
 			nil,
 			userList.GetDentistList(),
 			time.Now().Format("2006-01-02"),
 			nil,
 			"",
 			false,
+			nil,
+			false,
 		}
 
 		// Process form submission
@@ -462,48 +490,37 @@ func appointmentSearchHandler(userList *user.DoublyLinkedList, appointmentSessio
 			// If valid dentist and input date is entered
 			if !(dentist == nil || len(inputDate) == 0) {
 				ViewData.Dentist = dentist
-				ViewData.DentistsSession = app.GetDentistAvailability(appointmentSessionList, appointmentTree, appointmentDate, ViewData.Dentist)
+				ViewData.DentistsSession = app.GetDentistAvailability(appointmentSessionList, appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentDate, ViewData.Dentist, "", 1)
 				ViewData.SelectedDate = appointmentDate.Format("2006-01-02")
+				ViewData.ClinicClosed = clinicCalendar.Get(ViewData.SelectedDate)
+				ViewData.FullyBooked = ViewData.ClinicClosed == nil && !hasAvailableSession(ViewData.DentistsSession)
 			}
 			ViewData.FormProcessed = true
 		}
-		if err := tpl.ExecuteTemplate(res, "appointmentSearch.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "appointmentSearch.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
 }
 
 // appointmentCreateHandler handles request to creates a new appointment,
-// patients will first need to select a dentist.
-func appointmentCreateHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
+// patients will first need to select a dentist, dentists can be filtered by branch.
+func appointmentCreateHandler(userList *user.DoublyLinkedList, appointmentClinics app.Clinics) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, false)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
-
 		ViewData := struct {
-			LoggedInUser *user.User
-			PageTitle    string
-			CurrentPage  string
-			Dentists     []*user.User
+			page
+			Dentists []*user.User
+			Clinics  app.Clinics
+			Branch   *app.Clinic
 		}{
-			myUser,
-			"Create New Appointment",
-			"CNA",
-			(*userList).GetDentistList(),
+			newPage(req, "Create New Appointment", "CNA"),
+			nil,
+			appointmentClinics,
+			appointmentClinics.Get(strings.TrimSpace(req.FormValue("branch"))),
 		}
+		ViewData.Dentists = ViewData.Branch.FilterDentists((*userList).GetDentistList())
 
-		if err := tpl.ExecuteTemplate(res, "appointmentCreate_step1.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "appointmentCreate_step1.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
@@ -511,55 +528,61 @@ func appointmentCreateHandler(userList *user.DoublyLinkedList) http.HandlerFunc
 
 // appointmentCreateHandler creates a new appointment, after dentist selection,
 // patients will need select a date and appointment slot.
-func appointmentCreatePart2Handler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree) http.HandlerFunc {
+func appointmentCreatePart2Handler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, false)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
-
 		ViewData := struct {
-			LoggedInUser *user.User
-			PageTitle    string
-			CurrentPage  string
+			page
 			Dentist      *user.User
 			TodayDate    string
 			Sessions     []app.AppSession
 			SelectedDate string
+			ClinicClosed *app.Holiday
+			FullyBooked  bool
+			Types        app.AppointmentTypes
+			SelectedType *app.AppointmentType
+			Clinics      app.Clinics
+			Branch       *app.Clinic
 		}{
-			myUser,
-			"Create New Appointment",
-			"CNA",
+			newPage(req, "Create New Appointment", "CNA"),
 			nil,
 			time.Now().Format("2006-01-02"),
 			nil,
 			"",
+			nil,
+			false,
+			nil,
+			nil,
+			nil,
+			nil,
 		}
 
 		// Get data from query string
 		vars := mux.Vars(req)
 		dentistReq := vars["dentist"]
 		ViewData.Dentist = (*userList).FindByUsername(dentistReq)
+		if ViewData.Dentist != nil {
+			ViewData.Types = appointmentTypes.GetForDentist(ViewData.Dentist.Username)
+			ViewData.SelectedType = ViewData.Types.Get(req.FormValue("appType"))
+			ViewData.Clinics = appointmentClinics.GetForDentist(ViewData.Dentist.Username)
+			ViewData.Branch = ViewData.Clinics.Get(req.FormValue("branch"))
+		}
 
 		// Process form submission
 		if req.Method == http.MethodPost {
 			inputDate := req.FormValue("appDate")
 			appointmentDate, err := time.Parse("2006-01-02", inputDate)
 			if err == nil {
-				ViewData.Sessions = app.GetDentistAvailability(appointmentSessionList, appointmentTree, appointmentDate, ViewData.Dentist)
+				branch := ""
+				if ViewData.Branch != nil {
+					branch = ViewData.Branch.Code
+				}
+				ViewData.Sessions = app.GetDentistAvailability(appointmentSessionList, appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentDate, ViewData.Dentist, branch, ViewData.SelectedType.GetDuration())
 				ViewData.SelectedDate = appointmentDate.Format("2006-01-02")
+				ViewData.ClinicClosed = clinicCalendar.Get(ViewData.SelectedDate)
+				ViewData.FullyBooked = ViewData.ClinicClosed == nil && !hasAvailableSession(ViewData.Sessions)
 			}
 		}
-		if err := tpl.ExecuteTemplate(res, "appointmentCreate_step2.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "appointmentCreate_step2.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
@@ -567,42 +590,44 @@ func appointmentCreatePart2Handler(userList *user.DoublyLinkedList, appointmentS
 
 // appointmentCreateConfirmHandler display patients the final appointment details
 // for patient's confirmation.
-func appointmentCreateConfirmHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree) http.HandlerFunc {
+func appointmentCreateConfirmHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, rulesEngine *app.RulesEngine, waitlist *app.Waitlist) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, false)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
+		myUser := getSessionUser(req)
 
 		vars := mux.Vars(req)
 		dentistReq := vars["dentist"]
 		dateReq := vars["date"]
 		sessionReq := vars["session"]
 
+		type OccurrenceStruct struct {
+			Date       string
+			Conflict   string
+			RuleBroken bool
+			Booked     bool
+		}
+
 		ViewData := struct {
-			LoggedInUser  *user.User
-			PageTitle     string
-			CurrentPage   string
-			Dentist       *user.User
-			Date          string
-			StartTime     string
-			EndTime       string
-			Successful    bool
-			FormSubmitted bool
-			IsInputError  bool
+			page
+			Dentist              *user.User
+			Date                 string
+			StartTime            string
+			EndTime              string
+			Successful           bool
+			FormSubmitted        bool
+			IsInputError         bool
+			RuleViolations       []*app.RuleViolation
+			Interval             int
+			Occurrences          int
+			Series               []OccurrenceStruct
+			SeriesConflicts      int
+			SeriesBooked         int
+			MaxSeriesInterval    int
+			MaxSeriesOccurrences int
+			Type                 *app.AppointmentType
+			Branch               *app.Clinic
+			Chair                *app.Chair
 		}{
-			myUser,
-			"Create New Appointment",
-			"CNA",
+			newPage(req, "Create New Appointment", "CNA"),
 			nil,
 			"",
 			"",
@@ -610,6 +635,17 @@ func appointmentCreateConfirmHandler(userList *user.DoublyLinkedList, appointmen
 			false,
 			false,
 			false,
+			nil,
+			1,
+			0,
+			nil,
+			0,
+			0,
+			app.MaxSeriesInterval,
+			app.MaxSeriesOccurrences,
+			nil,
+			nil,
+			nil,
 		}
 
 		// Validating inputs
@@ -627,8 +663,34 @@ func appointmentCreateConfirmHandler(userList *user.DoublyLinkedList, appointmen
 			ViewData.IsInputError = true
 			logger.Error.Printf("%v: Error Parsing Session Number [%v]", util.CurrFuncName(), sessionReq)
 		}
+		// Validate appointment type, all sessions covered by the type must be within the session list
+		if typeReq := strings.TrimSpace(req.FormValue("type")); typeReq != "" {
+			ViewData.Type = appointmentTypes.Get(typeReq)
+			if ViewData.Type == nil || ses+ViewData.Type.GetDuration()-1 > (**appointmentSessionList).GetSize() {
+				ViewData.IsInputError = true
+				logger.Error.Printf("%v: Invalid appointment type [%v]", util.CurrFuncName(), typeReq)
+			}
+		}
+		// Validate branch, branch is empty when any branch the dentist is working at can be used
+		branchReq := strings.TrimSpace(req.FormValue("branch"))
+		if branchReq != "" {
+			ViewData.Branch = appointmentClinics.Get(branchReq)
+			if ViewData.Branch == nil || ViewData.Dentist == nil || !ViewData.Branch.HasDentist(ViewData.Dentist.Username) {
+				ViewData.IsInputError = true
+				logger.Error.Printf("%v: Invalid branch [%v]", util.CurrFuncName(), branchReq)
+			}
+		}
+		// Validate recurring series, occurrences is empty for a single appointment
+		if occurrencesReq := strings.TrimSpace(req.FormValue("occurrences")); occurrencesReq != "" {
+			ViewData.Interval, _ = strconv.Atoi(strings.TrimSpace(req.FormValue("interval")))
+			ViewData.Occurrences, _ = strconv.Atoi(occurrencesReq)
+			if ViewData.Interval < 1 || ViewData.Interval > app.MaxSeriesInterval || ViewData.Occurrences < 2 || ViewData.Occurrences > app.MaxSeriesOccurrences {
+				ViewData.IsInputError = true
+				logger.Error.Printf("%v: Invalid series interval [%v], occurrences [%v]", util.CurrFuncName(), req.FormValue("interval"), occurrencesReq)
+			}
+		}
 		if ViewData.IsInputError {
-			if err := tpl.ExecuteTemplate(res, "appointmentCreateConfirm.gohtml", ViewData); err != nil {
+			if err := getTemplates(req).ExecuteTemplate(res, "appointmentCreateConfirm.gohtml", ViewData); err != nil {
 				logger.Error.Println(err)
 			}
 			return
@@ -639,49 +701,125 @@ func appointmentCreateConfirmHandler(userList *user.DoublyLinkedList, appointmen
 		ViewData.Date = appointmentDate.Format("2006-01-02")
 		session := (**appointmentSessionList).Get(ses).(app.AppSession)
 		ViewData.StartTime = session.StartTime
-		ViewData.EndTime = session.EndTime
+		ViewData.EndTime = (**appointmentSessionList).Get(ses + ViewData.Type.GetDuration() - 1).(app.AppSession).EndTime
+
+		// Evaluate booking rules, rules are evaluated again when the appointment is created
+		bookingReq := &app.BookingRequest{
+			Patient: myUser,
+			Dentist: ViewData.Dentist,
+			Date:    ViewData.Date,
+			Session: session,
+			Type:    ViewData.Type,
+			Now:     time.Now(),
+		}
+		ViewData.RuleViolations = (*rulesEngine).Evaluate(bookingReq, appointmentTree)
+
+		// Check every occurrence of a series against the collision check and booking rules,
+		// booking rules of the first occurrence applies to the whole series.
+		var seriesDates []string
+		if ViewData.Occurrences > 0 && len(ViewData.RuleViolations) == 0 {
+			seriesDates = app.GetSeriesDates(appointmentDate, ViewData.Interval, ViewData.Occurrences)
+			conflicts := (*appointmentTree).GetSeriesConflicts(seriesDates, session.Num, ViewData.Type, ViewData.Dentist, branchReq, appointmentRota, clinicCalendar, appointmentClinics)
+			for _, date := range seriesDates {
+				occurrence := OccurrenceStruct{Date: date}
+				for _, v := range conflicts {
+					if v.Date == date {
+						occurrence.Conflict = v.Reason
+					}
+				}
+				if occurrence.Conflict == "" && date != ViewData.Date {
+					occurrenceReq := *bookingReq
+					occurrenceReq.Date = date
+					if violations := (*rulesEngine).Evaluate(&occurrenceReq, appointmentTree); len(violations) > 0 {
+						occurrence.Conflict = violations[0].Reason
+						occurrence.RuleBroken = true
+					}
+				}
+				if occurrence.Conflict != "" {
+					ViewData.SeriesConflicts++
+				}
+				ViewData.Series = append(ViewData.Series, occurrence)
+			}
+		}
 
 		// Process form submission
-		if req.Method == http.MethodPost {
+		if req.Method == http.MethodPost && len(ViewData.RuleViolations) > 0 {
+			logger.Info.Printf("%v: Appointment rejected by booking rules. user:[%v], date:[%v], session:[%v]", util.CurrFuncName(), myUser.Username, ViewData.Date, session.Num)
+			ViewData.FormSubmitted = true
+		} else if req.Method == http.MethodPost && ViewData.Series != nil {
+			// Occurrences which break booking rules are never booked, remaining occurrences are
+			// checked again and reserved in a single step unless patient agreed to skip conflicting dates.
+			var openDates []string
+			for _, v := range ViewData.Series {
+				if !v.RuleBroken {
+					openDates = append(openDates, v.Date)
+				}
+			}
+			skipConflicts := req.FormValue("skipConflicts") == "on"
+			if ViewData.SeriesConflicts > 0 && !skipConflicts {
+				ViewData.FormSubmitted = true
+			} else {
+				seriesID := util.GenerateID()
+				created, conflicts := app.CreateNewSeries(seriesID, openDates, bookingReq, branchReq, rulesEngine, appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, skipConflicts)
+				for k, v := range ViewData.Series {
+					for _, a := range created {
+						if a.Date == v.Date {
+							ViewData.Series[k].Booked = true
+						}
+					}
+					for _, c := range conflicts {
+						if c.Date == v.Date {
+							ViewData.Series[k].Conflict = c.Reason
+						}
+					}
+				}
+				ViewData.SeriesBooked = len(created)
+				ViewData.Successful = len(created) > 0
+				if ViewData.Successful {
+					logger.Info.Printf("%v: Appointment series [%v] created successfully with [%v] appointments.", util.CurrFuncName(), seriesID, len(created))
+					for _, v := range created {
+						waitlist.Fulfil(myUser.Username, ViewData.Dentist.Username, v.Date, session.Num)
+						recordAudit(req, myUser.Username, "appointment.create", strconv.Itoa(v.ID), nil, v.GetJSONData())
+					}
+				}
+				ViewData.FormSubmitted = true
+			}
+		} else if req.Method == http.MethodPost {
 			var id = util.GenerateID()
 			chn := make(chan bool)
-			go app.CreateNewAppointment(id, ViewData.Date, session.Num, ViewData.Dentist, myUser, appointmentTree, chn)
+			go app.CreateNewAppointment(id, bookingReq, branchReq, rulesEngine, appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, chn)
 			successful := <-chn
 			if successful {
 				logger.Info.Printf("%v: Appointment created successfully.", util.CurrFuncName())
 				logger.Trace.Printf("%v: Adding appointment data into JSON: id:[%v], username:[%v], dentist:[%v], date:[%v], session:[%v]", util.CurrFuncName(), id, myUser.Username, ViewData.Dentist.Username, ViewData.Date, session.Num)
 				ViewData.Successful = true
+				waitlist.Fulfil(myUser.Username, ViewData.Dentist.Username, ViewData.Date, session.Num)
+				// Display the branch and chair reserved
+				for _, v := range (*appointmentTree).GetAppointmentByDate(ViewData.Date, enumPatient, myUser) {
+					if v.ID == id {
+						ViewData.Branch = appointmentClinics.GetForAppointment(v)
+						ViewData.Chair = ViewData.Branch.GetChair(v.Chair)
+						recordAudit(req, myUser.Username, "appointment.create", strconv.Itoa(id), nil, v.GetJSONData())
+					}
+				}
+			} else {
+				// Display the rules broken by bookings made after the rules were evaluated
+				ViewData.RuleViolations = (*rulesEngine).Evaluate(bookingReq, appointmentTree)
 			}
 			ViewData.FormSubmitted = true
 		}
 
-		if err = tpl.ExecuteTemplate(res, "appointmentCreateConfirm.gohtml", ViewData); err != nil {
+		if err = getTemplates(req).ExecuteTemplate(res, "appointmentCreateConfirm.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
 }
 
 // appointmentEditHandler handles request to edit an appointment.
-func appointmentEditHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree) http.HandlerFunc {
+func appointmentEditHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, false)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
-
 		ViewData := struct {
-			LoggedInUser    *user.User
-			PageTitle       string
-			CurrentPage     string
+			page
 			Appointment     *app.Appointment
 			Dentists        []*user.User
 			DentistsSession []app.AppSession
@@ -690,10 +828,10 @@ func appointmentEditHandler(userList *user.DoublyLinkedList, appointmentSessionL
 			SelectedDate    string
 			SelectedDentist string
 			IsInputError    bool
+			ClinicClosed    *app.Holiday
+			Branch          *app.Clinic
 		}{
-			myUser,
-			"Change Appointment",
-			"MA",
+			newPage(req, "Change Appointment", "MA"),
 			nil,
 			(*userList).GetDentistList(),
 			nil,
@@ -702,6 +840,8 @@ func appointmentEditHandler(userList *user.DoublyLinkedList, appointmentSessionL
 			"",
 			"",
 			false,
+			nil,
+			nil,
 		}
 
 		vars := mux.Vars(req)
@@ -712,39 +852,29 @@ func appointmentEditHandler(userList *user.DoublyLinkedList, appointmentSessionL
 		if ViewData.Appointment == nil {
 			ViewData.IsInputError = true
 			logger.Error.Printf("%v: Application does not exist ID:[%v]", util.CurrFuncName(), appointmentID)
-			if err := tpl.ExecuteTemplate(res, "appointmentEdit.gohtml", ViewData); err != nil {
+			if err := getTemplates(req).ExecuteTemplate(res, "appointmentEdit.gohtml", ViewData); err != nil {
 				logger.Error.Println(err)
 			}
 			return
 		}
 		ViewData.SelectedDentist = ViewData.Appointment.Dentist.(*user.User).Username
+		ViewData.Branch = appointmentClinics.GetForAppointment(ViewData.Appointment)
 
 		// Process form submission
 		if req.Method == http.MethodPost {
 			inputDate := strings.TrimSpace(req.FormValue("appDate"))
 			inputDentist := strings.TrimSpace(req.FormValue("appDentist"))
 			appointmentDate, err := time.Parse("2006-01-02", inputDate)
-			if err == nil {
-				dentist := (*userList).FindByUsername(inputDentist)
-				var sessionList []app.AppSession
-				schedule := (*appointmentTree).GetAppointmentByDate(appointmentDate.Format("2006-01-02"), dentist.Role, dentist)
-				retSessionList := ViewData.Sessions
-				for _, v := range retSessionList {
-					session := v.(app.AppSession)
-					for _, data := range schedule {
-						if data.Session == session.Num {
-							session.Available = false
-						}
-					}
-					sessionList = append(sessionList, session)
-				}
+			dentist := (*userList).FindByUsername(inputDentist)
+			if err == nil && dentist != nil {
 				ViewData.SelectedDentist = dentist.Username
 				ViewData.SelectedDate = appointmentDate.Format("2006-01-02")
-				ViewData.DentistsSession = sessionList
+				ViewData.DentistsSession = app.GetDentistAvailability(appointmentSessionList, appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentDate, dentist, "", ViewData.Appointment.GetDuration())
+				ViewData.ClinicClosed = clinicCalendar.Get(ViewData.SelectedDate)
 			}
 		}
 
-		if err := tpl.ExecuteTemplate(res, "appointmentEdit.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "appointmentEdit.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
@@ -752,21 +882,9 @@ func appointmentEditHandler(userList *user.DoublyLinkedList, appointmentSessionL
 
 // appointmentEditConfirmHandler display patients the updated appointment details
 // for patient's confirmation.
-func appointmentEditConfirmHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree) http.HandlerFunc {
+func appointmentEditConfirmHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, rulesEngine *app.RulesEngine, waitlist *app.Waitlist, notifier notification.Notifier) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, false)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
+		myUser := getSessionUser(req)
 
 		vars := mux.Vars(req)
 		appointmentReq := vars["id"]
@@ -775,9 +893,7 @@ func appointmentEditConfirmHandler(userList *user.DoublyLinkedList, appointmentS
 		sessionReq := vars["session"]
 
 		ViewData := struct {
-			LoggedInUser       *user.User
-			PageTitle          string
-			CurrentPage        string
+			page
 			CurrentAppointment *app.Appointment
 			OldDentist         *user.User
 			OldDate            string
@@ -790,10 +906,17 @@ func appointmentEditConfirmHandler(userList *user.DoublyLinkedList, appointmentS
 			Unsuccessful       bool
 			UnsuccessfulMsg    string
 			IsInputError       bool
+			RuleViolations     []*app.RuleViolation
+			Following          []*app.Appointment
+			SeriesConflicts    []app.SeriesConflict
+			Scope              string
+			Type               *app.AppointmentType
+			OldLastSession     int
+			EditedLastSession  int
+			OldBranch          *app.Clinic
+			EditedBranch       *app.Clinic
 		}{
-			myUser,
-			"Confirm Appointment Change",
-			"MA",
+			newPage(req, "Confirm Appointment Change", "MA"),
 			nil,
 			nil,
 			"",
@@ -806,6 +929,15 @@ func appointmentEditConfirmHandler(userList *user.DoublyLinkedList, appointmentS
 			false,
 			"",
 			false,
+			nil,
+			nil,
+			nil,
+			req.FormValue("scope"),
+			nil,
+			0,
+			0,
+			nil,
+			nil,
 		}
 
 		// Validate Data
@@ -823,6 +955,10 @@ func appointmentEditConfirmHandler(userList *user.DoublyLinkedList, appointmentS
 		ViewData.OldDentist = ViewData.CurrentAppointment.Dentist.(*user.User)
 		ViewData.OldDate = ViewData.CurrentAppointment.Date
 		ViewData.OldSession = ViewData.CurrentAppointment.Session
+		ViewData.OldLastSession = ViewData.CurrentAppointment.GetLastSession()
+		ViewData.OldBranch = appointmentClinics.GetForAppointment(ViewData.CurrentAppointment)
+		ViewData.Type = appointmentTypes.GetForAppointment(ViewData.CurrentAppointment)
+		duration := ViewData.CurrentAppointment.GetDuration()
 		// Validate Dentist
 		ViewData.EditedDentist = (*userList).FindByUsername(dentistReq)
 		if ViewData.EditedDentist == nil {
@@ -838,13 +974,14 @@ func appointmentEditConfirmHandler(userList *user.DoublyLinkedList, appointmentS
 		ViewData.EditedDate = parsedDate.Format("2006-01-02")
 		// Validate Session
 		ViewData.EditedSession, err = strconv.Atoi(sessionReq)
-		if err != nil {
+		if err != nil || ViewData.EditedSession+duration-1 > (**appointmentSessionList).GetSize() {
 			ViewData.IsInputError = true
 			logger.Error.Printf("%v: Error Parsing Session Number [%v]", util.CurrFuncName(), sessionReq)
 		}
+		ViewData.EditedLastSession = ViewData.EditedSession + duration - 1
 		// If validation fail
 		if ViewData.IsInputError {
-			if err := tpl.ExecuteTemplate(res, "appointmentEditConfirm.gohtml", ViewData); err != nil {
+			if err := getTemplates(req).ExecuteTemplate(res, "appointmentEditConfirm.gohtml", ViewData); err != nil {
 				logger.Error.Println(err)
 			}
 			return
@@ -852,82 +989,124 @@ func appointmentEditConfirmHandler(userList *user.DoublyLinkedList, appointmentS
 
 		logger.Trace.Printf("%v: Application ID [%v], Dentist [%v], Date [%v], Session [%v]", util.CurrFuncName(), appointmentReq, dentistReq, dateReq, sessionReq)
 
-		if req.Method == http.MethodPost {
-			currentAppointment := app.New(ViewData.CurrentAppointment.ID, ViewData.CurrentAppointment.Patient.(*user.User).Username, ViewData.CurrentAppointment.Dentist.(*user.User).Username, ViewData.CurrentAppointment.Date, ViewData.CurrentAppointment.Session)
-			newAppointment := app.New(ViewData.CurrentAppointment.ID, ViewData.CurrentAppointment.Patient.(*user.User).Username, ViewData.CurrentAppointment.Dentist.(*user.User).Username, ViewData.CurrentAppointment.Date, ViewData.CurrentAppointment.Session)
-			// If there's no change to appointment date
-			if ViewData.CurrentAppointment.Date == ViewData.EditedDate {
-				// If there's a change in dentist
-				if ViewData.CurrentAppointment.Dentist.(*user.User).Username != ViewData.EditedDentist.Username {
-					ViewData.CurrentAppointment.Dentist = ViewData.EditedDentist
-					newAppointment.Dentist = ViewData.EditedDentist.Username
+		// Evaluate cancellation cut-off of existing appointment and booking rules of updated appointment
+		currentSession := (**appointmentSessionList).Get(ViewData.OldSession).(app.AppSession)
+		if violation := (*rulesEngine).CanCancel(ViewData.CurrentAppointment, currentSession, myUser, time.Now()); violation != nil {
+			ViewData.RuleViolations = append(ViewData.RuleViolations, violation)
+		}
+		ViewData.RuleViolations = append(ViewData.RuleViolations, (*rulesEngine).Evaluate(&app.BookingRequest{
+			Patient:  ViewData.CurrentAppointment.Patient.(*user.User),
+			Dentist:  ViewData.EditedDentist,
+			Date:     ViewData.EditedDate,
+			Session:  (**appointmentSessionList).Get(ViewData.EditedSession).(app.AppSession),
+			Type:     ViewData.Type,
+			Existing: ViewData.CurrentAppointment,
+			Now:      time.Now(),
+		}, appointmentTree)...)
+		if len(ViewData.RuleViolations) > 0 {
+			ViewData.Unsuccessful = true
+		}
+		// Appointments after the current appointment in the same series
+		if ViewData.CurrentAppointment.SeriesID != 0 {
+			ViewData.Following = (*appointmentTree).GetFollowing(ViewData.CurrentAppointment)[1:]
+		}
+
+		if req.Method == http.MethodPost && !ViewData.Unsuccessful && ViewData.Scope == "following" && len(ViewData.Following) > 0 {
+			// Move this and following appointments of the series by the same number of days
+			oldDate, _ := time.Parse("2006-01-02", ViewData.OldDate)
+			days := int(parsedDate.Sub(oldDate).Hours() / 24)
+			following := (*appointmentTree).GetFollowing(ViewData.CurrentAppointment)
+			rescheduled, conflicts, err := (*appointmentTree).RescheduleSeries(following, days, ViewData.EditedSession, ViewData.EditedDentist, appointmentRota, clinicCalendar, appointmentClinics)
+			if err != nil {
+				logger.Error.Println(err)
+				ViewData.Unsuccessful = true
+				ViewData.UnsuccessfulMsg = "There's an error processing your transaction, please try again later."
+			} else if len(conflicts) > 0 {
+				ViewData.Unsuccessful = true
+				ViewData.SeriesConflicts = conflicts
+				ViewData.UnsuccessfulMsg = "Some appointments of the series cannot be changed, no appointment was changed."
+			} else {
+				ViewData.Successful = true
+				ViewData.EditedBranch = appointmentClinics.GetForAppointment(rescheduled[0])
+				logger.Info.Printf("%v: [%v] appointments of series [%v] changed successfully.", util.CurrFuncName(), len(rescheduled), ViewData.CurrentAppointment.SeriesID)
+				for k, v := range following {
+					offerFreedSlot(appointmentSessionList, appointmentTree, userList, waitlist, notifier, v.Dentist.(*user.User), v.Date, v.Session, v.GetDuration())
+					recordAudit(req, myUser.Username, "appointment.reschedule", strconv.Itoa(v.ID), v.GetJSONData(), rescheduled[k].GetJSONData())
 				}
-				// If there's a change in session
-				if ViewData.CurrentAppointment.Session != ViewData.EditedSession {
-					ViewData.CurrentAppointment.Session = ViewData.EditedSession
-					newAppointment.Session = ViewData.EditedSession
+			}
+		} else if req.Method == http.MethodPost && !ViewData.Unsuccessful {
+			currentAppointment := ViewData.CurrentAppointment.GetJSONData()
+			// If clinic is closed or dentist is not working on the selected date and session
+			if clinicCalendar.IsClosed(ViewData.EditedDate) {
+				ViewData.Unsuccessful = true
+				ViewData.UnsuccessfulMsg = "Clinic is closed on the selected date, please select another date."
+			} else if !appointmentRota.IsWorking(ViewData.EditedDentist.Username, parsedDate, ViewData.EditedSession) {
+				ViewData.Unsuccessful = true
+				ViewData.UnsuccessfulMsg = "Dentist is not available on the selected date and session, please select another slot."
+			} else if ViewData.CurrentAppointment.Date == ViewData.EditedDate {
+				// If there's no change to appointment date, dentist and session are changed in place
+				if conflict := (*appointmentTree).RescheduleInPlace(ViewData.CurrentAppointment, ViewData.EditedSession, ViewData.EditedDentist, appointmentRota, clinicCalendar, appointmentClinics); conflict != "" {
+					ViewData.Unsuccessful = true
+					ViewData.UnsuccessfulMsg = conflict + " Please select another slot."
+				} else {
+					ViewData.EditedBranch = appointmentClinics.GetForAppointment(ViewData.CurrentAppointment)
+					ViewData.Successful = true
+					recordAudit(req, myUser.Username, "appointment.reschedule", strconv.Itoa(currentAppointment.ID), currentAppointment, ViewData.CurrentAppointment.GetJSONData())
+					if ViewData.OldDentist.Username != ViewData.EditedDentist.Username || ViewData.OldSession != ViewData.EditedSession {
+						offerFreedSlot(appointmentSessionList, appointmentTree, userList, waitlist, notifier, ViewData.OldDentist, ViewData.OldDate, ViewData.OldSession, duration)
+					}
 				}
-				ViewData.Successful = true
-				// Update JSON
-				app.UpdateAppointmentData(currentAppointment, newAppointment)
 			} else {
 				// If there's change to appointment date
-				var id = util.GenerateID()
-				chn := make(chan bool)
-				go app.CreateNewAppointment(id, ViewData.EditedDate, ViewData.EditedSession, ViewData.EditedDentist, ViewData.CurrentAppointment.Patient.(*user.User), appointmentTree, chn)
-				successful := <-chn
-				if successful {
-					if err := (*appointmentTree).DeleteAppointment(ViewData.CurrentAppointment); err != nil {
-						logger.Error.Println(err)
-						ViewData.Unsuccessful = true
-						ViewData.UnsuccessfulMsg = "There's an error processing your transaction, please try again later."
-					} else {
-						ViewData.Successful = true
-					}
-				} else {
+				rescheduled, err := (*appointmentTree).RescheduleAppointment(ViewData.CurrentAppointment, ViewData.EditedDate, ViewData.EditedSession, ViewData.EditedDentist, appointmentRota, clinicCalendar, appointmentClinics, "")
+				if rescheduled == nil {
 					ViewData.Unsuccessful = true
+				} else if err != nil {
+					logger.Error.Println(err)
+					ViewData.Unsuccessful = true
+					ViewData.UnsuccessfulMsg = "There's an error processing your transaction, please try again later."
+				} else {
+					ViewData.Successful = true
+					ViewData.EditedBranch = appointmentClinics.GetForAppointment(rescheduled)
+					offerFreedSlot(appointmentSessionList, appointmentTree, userList, waitlist, notifier, ViewData.OldDentist, ViewData.OldDate, ViewData.OldSession, duration)
+					recordAudit(req, myUser.Username, "appointment.reschedule", strconv.Itoa(currentAppointment.ID), currentAppointment, rescheduled.GetJSONData())
 				}
 			}
 		}
-		if err := tpl.ExecuteTemplate(res, "appointmentEditConfirm.gohtml", ViewData); err != nil {
+		if ViewData.Successful {
+			waitlist.Fulfil(ViewData.CurrentAppointment.Patient.(*user.User).Username, ViewData.EditedDentist.Username, ViewData.EditedDate, ViewData.EditedSession)
+		}
+		if err := getTemplates(req).ExecuteTemplate(res, "appointmentEditConfirm.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
 }
 
 // appointmentDeleteHandler handles request to cancel an appointment.
-func appointmentDeleteHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree) http.HandlerFunc {
+func appointmentDeleteHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentTypes app.AppointmentTypes, rulesEngine *app.RulesEngine, waitlist *app.Waitlist, notifier notification.Notifier) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, false)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
+		myUser := getSessionUser(req)
 
 		ViewData := struct {
-			PageTitle    string
-			LoggedInUser *user.User
-			CurrentPage  string
+			page
 			Appointment  *app.Appointment
 			Sessions     []interface{}
 			Successful   bool
 			IsInputError bool
+			CutOff       *app.RuleViolation
+			Following    []*app.Appointment
+			Cancelled    int
+			Types        app.AppointmentTypes
 		}{
-			"Cancel Appointment",
-			myUser,
-			"MA",
+			newPage(req, "Cancel Appointment", "MA"),
 			nil,
 			nil,
 			false,
 			false,
+			nil,
+			nil,
+			0,
+			appointmentTypes,
 		}
 
 		vars := mux.Vars(req)
@@ -938,23 +1117,38 @@ func appointmentDeleteHandler(userList *user.DoublyLinkedList, appointmentSessio
 		if ViewData.Appointment == nil {
 			ViewData.IsInputError = true
 			logger.Error.Printf("%v: Application does not exist ID:[%v]", util.CurrFuncName(), appointmentID)
-			if err := tpl.ExecuteTemplate(res, "appointmentEdit.gohtml", ViewData); err != nil {
+			if err := getTemplates(req).ExecuteTemplate(res, "appointmentEdit.gohtml", ViewData); err != nil {
 				logger.Error.Println(err)
 			}
 			return
 		}
 
 		ViewData.Sessions = (**appointmentSessionList).GetList()
+		session := (**appointmentSessionList).Get(ViewData.Appointment.Session).(app.AppSession)
+		ViewData.CutOff = (*rulesEngine).CanCancel(ViewData.Appointment, session, myUser, time.Now())
+		// Appointments after the current appointment in the same series
+		if ViewData.Appointment.SeriesID != 0 {
+			ViewData.Following = (*appointmentTree).GetFollowing(ViewData.Appointment)[1:]
+		}
 
 		// Process form submission
-		if req.Method == http.MethodPost {
-			if err := (*appointmentTree).DeleteAppointment(ViewData.Appointment); err != nil {
-				logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
-			} else {
-				ViewData.Successful = true
+		if req.Method == http.MethodPost && ViewData.CutOff == nil {
+			cancelList := []*app.Appointment{ViewData.Appointment}
+			if req.FormValue("scope") == "following" {
+				cancelList = append(cancelList, ViewData.Following...)
+			}
+			for _, v := range cancelList {
+				if err := (*appointmentTree).DeleteAppointment(v, ""); err != nil {
+					logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+					break
+				}
+				ViewData.Cancelled++
+				offerFreedSlot(appointmentSessionList, appointmentTree, userList, waitlist, notifier, v.Dentist.(*user.User), v.Date, v.Session, v.GetDuration())
+				recordAudit(req, myUser.Username, "appointment.cancel", strconv.Itoa(v.ID), v.GetJSONData(), nil)
 			}
+			ViewData.Successful = ViewData.Cancelled > 0
 		}
-		if err := tpl.ExecuteTemplate(res, "appointmentDelete.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "appointmentDelete.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
@@ -963,39 +1157,21 @@ func appointmentDeleteHandler(userList *user.DoublyLinkedList, appointmentSessio
 // appointmentDeleteHandler handles request to list all users (Admin only).
 func userListHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, true)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
-
 		ViewData := struct {
-			LoggedInUser   *user.User
-			PageTitle      string
-			CurrentPage    string
+			page
 			Users          []interface{}
 			Successful     bool
 			ErrorDelete    bool
 			ErrorDeleteMsg string
 		}{
-			myUser,
-			"Manage Users",
-			"MU",
+			newPage(req, "Manage Users", "MU"),
 			(*userList).GetList(),
 			false,
 			false,
 			"",
 		}
 
-		if err := tpl.ExecuteTemplate(res, "userList.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "userList.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
@@ -1005,50 +1181,35 @@ func userListHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 // only admin has the privilege to edit any user's detail.
 func userEditHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				util.CheckEncryption()
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, false)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
+		myUser := getSessionUser(req)
 
 		vars := mux.Vars(req)
 		username := vars["username"]
 
 		if myUser.Role == enumPatient {
 			if username != myUser.Username {
-				http.Redirect(res, req, "/", http.StatusUnauthorized)
+				renderError(res, req, http.StatusForbidden)
 				return
 			}
 		}
 
 		ViewData := struct {
-			LoggedInUser         *user.User
-			PageTitle            string
-			CurrentPage          string
+			page
 			UserData             *user.User
 			ValidateFirstName    bool
 			ValidateLastName     bool
 			ValidateMobileNumber bool
+			ValidateEmail        bool
 			ValidatePassword     bool
 			Successful           bool
 		}{
-			myUser,
-			"Edit User Information",
-			"",
+			newPage(req, "Edit User Information", ""),
 			nil,
 			true,
 			true,
 			true,
 			true,
+			true,
 			false,
 		}
 		if ViewData.LoggedInUser.Role == enumAdmin {
@@ -1058,13 +1219,15 @@ func userEditHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 		ViewData.UserData = (*userList).FindByUsername(username)
 		if ViewData.UserData == nil {
 			logger.Error.Printf("%v: User Not Found: %v", util.CurrFuncName(), username)
-			if err := tpl.ExecuteTemplate(res, "userEdit.gohtml", ViewData); err != nil {
+			if err := getTemplates(req).ExecuteTemplate(res, "userEdit.gohtml", ViewData); err != nil {
 				logger.Error.Println(err)
 			}
 			return
 		}
 
 		copyUser := user.New(ViewData.UserData.Username, ViewData.UserData.Password, ViewData.UserData.Role, ViewData.UserData.FirstName, ViewData.UserData.LastName, ViewData.UserData.MobileNumber)
+		copyUser.Email = ViewData.UserData.Email
+		copyUser.Locale = ViewData.UserData.Locale
 
 		// process form submission
 		if req.Method == http.MethodPost {
@@ -1073,6 +1236,7 @@ func userEditHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 			inputFirstName := strings.TrimSpace(req.FormValue("firstName"))
 			inputLastName := strings.TrimSpace(req.FormValue("lastName"))
 			inputMobile := strings.TrimSpace(req.FormValue("mobileNum"))
+			inputEmail := strings.TrimSpace(req.FormValue("email"))
 			inputPassword := strings.TrimSpace(req.FormValue("password"))
 
 			// Validate first name input
@@ -1107,6 +1271,14 @@ func userEditHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 						edited = true
 					}
 				}
+				// Validate optional email input
+				if !validator.IsEmpty(inputEmail) && !validator.IsEmail(inputEmail) {
+					ViewData.ValidateEmail = false
+				}
+				if ViewData.ValidateEmail && inputEmail != ViewData.UserData.Email {
+					ViewData.UserData.Email = inputEmail
+					edited = true
+				}
 			}
 			// Change Password
 			if len(inputPassword) > 0 {
@@ -1115,7 +1287,7 @@ func userEditHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 					// Different password
 					bPassword, err := bcrypt.GenerateFromPassword([]byte(inputPassword), bcrypt.MinCost)
 					if err != nil {
-						logger.Error.Printf("%v: Error:", util.CurrFuncName(), err)
+						logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
 					} else {
 						ViewData.UserData.Password = string(bPassword)
 						edited = true
@@ -1130,25 +1302,28 @@ func userEditHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 			}
 
 			// Validation completed
-			if ViewData.ValidateFirstName && ViewData.ValidateLastName && ViewData.ValidateMobileNumber {
+			if ViewData.ValidateFirstName && ViewData.ValidateLastName && ViewData.ValidateMobileNumber && ViewData.ValidateEmail {
 				if edited {
 					user.UpdateUserData(copyUser, ViewData.UserData)
+					recordAudit(req, myUser.Username, "user.update", ViewData.UserData.Username, copyUser, ViewData.UserData)
 				}
 				if deleteChkBox && !ViewData.UserData.IsDeleted {
 					ViewData.UserData.IsDeleted = true
 					user.DeleteUserData(ViewData.UserData)
 					// Remove user for session if logged in
 					deleteSessionByUsername(ViewData.UserData.Username)
+					recordAudit(req, myUser.Username, "user.delete", ViewData.UserData.Username, nil, nil)
 				}
 				if !deleteChkBox && ViewData.UserData.IsDeleted {
 					ViewData.UserData.IsDeleted = false
 					user.DeleteUserData(ViewData.UserData)
+					recordAudit(req, myUser.Username, "user.restore", ViewData.UserData.Username, nil, nil)
 				}
 				ViewData.Successful = true
 			}
 		}
 
-		if err := tpl.ExecuteTemplate(res, "userEdit.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "userEdit.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
@@ -1157,36 +1332,19 @@ func userEditHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 // appointmentDeleteHandler handles request to delete a user. User will be "soft deleted".
 func userDeleteHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				util.CheckEncryption()
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
-
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, true)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
-		}
+		myUser := getSessionUser(req)
 
 		vars := mux.Vars(req)
 		username := vars["username"]
 
 		ViewData := struct {
-			LoggedInUser   *user.User
-			PageTitle      string
-			CurrentPage    string
+			page
 			Users          []interface{}
 			Successful     bool
 			ErrorDelete    bool
 			ErrorDeleteMsg string
 		}{
-			myUser,
-			"Manage Users",
-			"MU",
+			newPage(req, "Manage Users", "MU"),
 			(*userList).GetList(),
 			false,
 			false,
@@ -1198,7 +1356,7 @@ func userDeleteHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 			ViewData.ErrorDelete = true
 			ViewData.ErrorDeleteMsg = "Error deleting user: " + username + ", user does not exist."
 			logger.Error.Printf("%v: User does not exist: %v", util.CurrFuncName(), username)
-			if err := tpl.ExecuteTemplate(res, "userList.gohtml", ViewData); err != nil {
+			if err := getTemplates(req).ExecuteTemplate(res, "userList.gohtml", ViewData); err != nil {
 				logger.Error.Println(err)
 			}
 			return
@@ -1211,30 +1369,42 @@ func userDeleteHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 		user.DeleteUserData(userObj)
 		// Remove user for session if logged in
 		deleteSessionByUsername(userObj.Username)
+		recordAudit(req, myUser.Username, "user.delete", userObj.Username, nil, nil)
 		logger.Info.Printf("%v: User [%v] deleted successfully.", util.CurrFuncName(), username)
 
-		if err := tpl.ExecuteTemplate(res, "userList.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "userList.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
 }
 
-// sessionListHandler handles request to list all active sessions (Admin only).
-func sessionListHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
+// languageHandler handles request to change the locale of pages of the logged-in user, the user is
+// redirected back to the page the locale was changed on. Locales which are not supported are ignored.
+func languageHandler() http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Panic.Println(err)
-				http.Redirect(res, req, "/", http.StatusInternalServerError)
-				return
-			}
-		}()
+		myUser := getSessionUser(req)
 
-		myUser, authFail, httpStatusNum := authenticationCheck(res, req, userList, true)
-		if authFail {
-			http.Redirect(res, req, "/", httpStatusNum)
-			return
+		if locale := req.FormValue("locale"); req.Method == http.MethodPost && i18n.IsSupported(locale) && locale != myUser.Locale {
+			copyUser := *myUser
+			myUser.Locale = locale
+			user.UpdateUserData(&copyUser, myUser)
+			recordAudit(req, myUser.Username, "user.update", myUser.Username, &copyUser, myUser)
+			logger.Info.Printf("%v: Locale of user [%v] changed to [%v].", util.CurrFuncName(), myUser.Username, locale)
+		}
+
+		// Only redirect to pages of the server
+		path := "/"
+		if referer, err := url.Parse(req.Referer()); err == nil && referer.Host == req.Host && referer.Path != "" {
+			path = referer.RequestURI()
 		}
+		http.Redirect(res, req, path, http.StatusSeeOther)
+	}
+}
+
+// sessionListHandler handles request to list all active sessions (Admin only).
+func sessionListHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
 
 		type SessionStruct struct {
 			SessionID string
@@ -1243,18 +1413,14 @@ func sessionListHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 		}
 
 		ViewData := struct {
-			LoggedInUser *user.User
-			PageTitle    string
-			CurrentPage  string
-			Sessions     []SessionStruct
+			page
+			Sessions []SessionStruct
 		}{
-			myUser,
-			"Manage Session",
-			"MS",
+			newPage(req, "Manage Session", "MS"),
 			nil,
 		}
 
-		for k, v := range mapSessions {
+		for k, v := range mapSessions.GetAll() {
 			userObj := (*userList).FindByUsername(v)
 			ViewData.Sessions = append(ViewData.Sessions, SessionStruct{SessionID: k, Username: v, Role: userObj.Role})
 		}
@@ -1262,7 +1428,7 @@ func sessionListHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 		// Process form submission
 		if req.Method == http.MethodPost {
 			if err := req.ParseForm(); err != nil {
-				logger.Error.Printf("%v: Error:", util.CurrFuncName(), err)
+				logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
 			} else {
 				// Loop through form
 				for key, values := range req.Form {
@@ -1270,7 +1436,9 @@ func sessionListHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 					for _, sessionID := range values {
 						// Key equals to checkbox group
 						if key == "sessionsDel" {
-							delete(mapSessions, sessionID)
+							if username, ok := mapSessions.Delete(sessionID); ok {
+								recordAudit(req, myUser.Username, "session.terminate", username, nil, nil)
+							}
 						}
 					}
 				}
@@ -1278,8 +1446,1918 @@ func sessionListHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
 			http.Redirect(res, req, "/sessions", http.StatusSeeOther)
 		}
 
-		if err := tpl.ExecuteTemplate(res, "sessions.gohtml", ViewData); err != nil {
+		if err := getTemplates(req).ExecuteTemplate(res, "sessions.gohtml", ViewData); err != nil {
 			logger.Error.Println(err)
 		}
 	}
 }
+
+// availabilityHandler handles request to manage dentist's weekly rota, leave dates and blocked slots,
+// only admin and the dentist has the privilege to manage the dentist's availability.
+func availabilityHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentRota *app.Rota) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		vars := mux.Vars(req)
+		username := vars["dentist"]
+
+		if myUser.Role != enumAdmin && username != myUser.Username {
+			renderError(res, req, http.StatusForbidden)
+			return
+		}
+
+		ViewData := struct {
+			page
+			Dentist      *user.User
+			Availability *app.DentistAvailability
+			Weekdays     []time.Weekday
+			Sessions     []interface{}
+			TodayDate    string
+			Successful   bool
+			IsInputError bool
+		}{
+			newPage(req, "Manage Availability", "MAV"),
+			nil,
+			nil,
+			app.Weekdays,
+			(**appointmentSessionList).GetList(),
+			time.Now().Format("2006-01-02"),
+			false,
+			false,
+		}
+		if myUser.Role == enumAdmin {
+			ViewData.CurrentPage = "MU"
+		}
+
+		ViewData.Dentist = (*userList).FindByUsername(username)
+		if ViewData.Dentist == nil || ViewData.Dentist.Role != enumDentist {
+			ViewData.Dentist = nil
+			logger.Error.Printf("%v: Dentist Not Found: %v", util.CurrFuncName(), username)
+			if err := getTemplates(req).ExecuteTemplate(res, "availability.gohtml", ViewData); err != nil {
+				logger.Error.Println(err)
+			}
+			return
+		}
+
+		var sessionNums []int
+		for _, v := range ViewData.Sessions {
+			sessionNums = append(sessionNums, v.(app.AppSession).Num)
+		}
+		ViewData.Availability = appointmentRota.Get(ViewData.Dentist.Username, sessionNums)
+
+		// Process form submission
+		if req.Method == http.MethodPost {
+			// Copy of availability before changes for audit log
+			previous, _ := json.Marshal(ViewData.Availability)
+			if err := req.ParseForm(); err != nil {
+				logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+				ViewData.IsInputError = true
+			} else {
+				inputDate := strings.TrimSpace(req.FormValue("inputDate"))
+				inputSession, _ := strconv.Atoi(strings.TrimSpace(req.FormValue("inputSession")))
+				_, dateErr := time.Parse("2006-01-02", inputDate)
+
+				switch req.FormValue("action") {
+				case "saveRota":
+					for _, day := range app.Weekdays {
+						var sessions []int
+						for _, v := range req.Form["rota"+day.String()] {
+							if num, err := strconv.Atoi(v); err == nil {
+								sessions = append(sessions, num)
+							}
+						}
+						ViewData.Availability.SetWeeklyRota(day.String(), sessions)
+					}
+				case "addLeave":
+					if dateErr != nil {
+						ViewData.IsInputError = true
+					} else {
+						ViewData.Availability.AddLeave(inputDate)
+					}
+				case "removeLeave":
+					ViewData.Availability.RemoveLeave(inputDate)
+				case "addBlocked":
+					if dateErr != nil || inputSession < 1 || inputSession > len(sessionNums) {
+						ViewData.IsInputError = true
+					} else {
+						ViewData.Availability.AddBlockedSlot(inputDate, inputSession, strings.TrimSpace(req.FormValue("inputReason")))
+					}
+				case "removeBlocked":
+					ViewData.Availability.RemoveBlockedSlot(inputDate, inputSession)
+				default:
+					ViewData.IsInputError = true
+				}
+			}
+			if !ViewData.IsInputError {
+				appointmentRota.Set(ViewData.Availability)
+				app.SaveRotaData(appointmentRota)
+				recordAudit(req, myUser.Username, "availability.update", ViewData.Dentist.Username, json.RawMessage(previous), ViewData.Availability)
+				ViewData.Successful = true
+				logger.Info.Printf("%v: Availability of dentist [%v] updated by [%v].", util.CurrFuncName(), ViewData.Dentist.Username, myUser.Username)
+			}
+		}
+
+		if err := getTemplates(req).ExecuteTemplate(res, "availability.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// holidayListHandler handles request to manage clinic closure dates (Admin only),
+// closure dates can be added manually or imported from an iCalendar file.
+func holidayListHandler(appointmentTree *app.BinarySearchTree, clinicCalendar *app.Calendar) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		type HolidayStruct struct {
+			*app.Holiday
+			Appointments int
+		}
+
+		ViewData := struct {
+			page
+			Holidays      []HolidayStruct
+			TodayDate     string
+			ImportedCount int
+			Successful    bool
+			IsInputError  bool
+		}{
+			newPage(req, "Manage Holidays", "MH"),
+			nil,
+			time.Now().Format("2006-01-02"),
+			0,
+			false,
+			false,
+		}
+
+		// Process form submission
+		if req.Method == http.MethodPost {
+			inputDate := strings.TrimSpace(req.FormValue("inputDate"))
+			inputName := strings.TrimSpace(req.FormValue("inputName"))
+			_, dateErr := time.Parse("2006-01-02", inputDate)
+
+			switch req.FormValue("action") {
+			case "add":
+				if dateErr != nil || validator.IsEmpty(inputName) {
+					ViewData.IsInputError = true
+					break
+				}
+				clinicCalendar.Add(inputDate, inputName)
+				app.SaveHolidayData(clinicCalendar)
+				recordAudit(req, myUser.Username, "holiday.add", inputDate, nil, clinicCalendar.Get(inputDate))
+				logger.Info.Printf("%v: Clinic closure [%v] added by [%v].", util.CurrFuncName(), inputDate, myUser.Username)
+				// Display appointments affected by the new closure date
+				http.Redirect(res, req, "/holidays/"+inputDate, http.StatusSeeOther)
+				return
+			case "remove":
+				holiday := clinicCalendar.Remove(inputDate)
+				app.SaveHolidayData(clinicCalendar)
+				recordAudit(req, myUser.Username, "holiday.remove", inputDate, holiday, nil)
+				logger.Info.Printf("%v: Clinic closure [%v] removed by [%v].", util.CurrFuncName(), inputDate, myUser.Username)
+				ViewData.Successful = true
+			case "import":
+				file, _, err := req.FormFile("icsFile")
+				if err != nil {
+					logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+					ViewData.IsInputError = true
+					break
+				}
+				holidays, err := app.ParseICalendar(file)
+				_ = file.Close()
+				if err != nil {
+					logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+					ViewData.IsInputError = true
+					break
+				}
+				for _, v := range holidays {
+					if clinicCalendar.Add(v.Date, v.Name) {
+						ViewData.ImportedCount++
+						recordAudit(req, myUser.Username, "holiday.import", v.Date, nil, clinicCalendar.Get(v.Date))
+					}
+				}
+				app.SaveHolidayData(clinicCalendar)
+				logger.Info.Printf("%v: %v clinic closures imported by [%v].", util.CurrFuncName(), ViewData.ImportedCount, myUser.Username)
+				ViewData.Successful = true
+			default:
+				ViewData.IsInputError = true
+			}
+		}
+
+		for _, v := range clinicCalendar.GetHolidays(ViewData.TodayDate) {
+			ViewData.Holidays = append(ViewData.Holidays, HolidayStruct{v, len(getAppointmentsByDate(appointmentTree, v.Date))})
+		}
+
+		if err := getTemplates(req).ExecuteTemplate(res, "holidays.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// holidayAppointmentsHandler handles request to list appointments falling on a clinic closure date (Admin only),
+// admin is able to cancel all appointments or move them to the dentist's next available slot.
+// Patients are notified of the changes made.
+func holidayAppointmentsHandler(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, appointmentClinics app.Clinics) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		type ResultStruct struct {
+			Appointment *app.Appointment
+			Successful  bool
+			NewDate     string
+			NewSession  int
+		}
+
+		vars := mux.Vars(req)
+		dateReq := vars["date"]
+
+		ViewData := struct {
+			page
+			Holiday      *app.Holiday
+			Appointments []*app.Appointment
+			Sessions     []interface{}
+			Action       string
+			Results      []ResultStruct
+		}{
+			newPage(req, "Manage Holidays", "MH"),
+			clinicCalendar.Get(dateReq),
+			nil,
+			(**appointmentSessionList).GetList(),
+			"",
+			nil,
+		}
+
+		if ViewData.Holiday == nil {
+			logger.Error.Printf("%v: Clinic closure does not exist: %v", util.CurrFuncName(), dateReq)
+			if err := getTemplates(req).ExecuteTemplate(res, "holidayAppointments.gohtml", ViewData); err != nil {
+				logger.Error.Println(err)
+			}
+			return
+		}
+
+		ViewData.Appointments = getAppointmentsByDate(appointmentTree, dateReq)
+
+		// Process form submission
+		if req.Method == http.MethodPost {
+			ViewData.Action = req.FormValue("action")
+			closureDate, _ := time.Parse("2006-01-02", dateReq)
+			// Patients are notified of the change with the reason through appointment events
+			reason := fmt.Sprintf("the clinic is closed on %v (%v)", util.FormatDate(dateReq), ViewData.Holiday.Name)
+			for _, v := range ViewData.Appointments {
+				result := ResultStruct{Appointment: v}
+				switch ViewData.Action {
+				case "cancel":
+					if err := (*appointmentTree).DeleteAppointment(v, reason); err != nil {
+						logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+						break
+					}
+					recordAudit(req, myUser.Username, "appointment.cancel", strconv.Itoa(v.ID), v.GetJSONData(), nil)
+					result.Successful = true
+				case "move":
+					// Appointments are moved within the same branch
+					branch := appointmentClinics.GetForAppointment(v).Code
+					date, session, found := app.GetNextAvailableSlot(appointmentSessionList, appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, closureDate.AddDate(0, 0, 1), rescheduleSearchDays, v.Dentist.(*user.User), branch, v.GetDuration())
+					if !found {
+						logger.Warning.Printf("%v: No available slot for appointment ID:[%v]", util.CurrFuncName(), v.ID)
+						break
+					}
+					rescheduled, err := (*appointmentTree).RescheduleAppointment(v, date, session, v.Dentist.(*user.User), appointmentRota, clinicCalendar, appointmentClinics, reason)
+					if err != nil {
+						logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+						break
+					}
+					recordAudit(req, myUser.Username, "appointment.reschedule", strconv.Itoa(v.ID), v.GetJSONData(), rescheduled.GetJSONData())
+					result.Successful = true
+					result.NewDate = date
+					result.NewSession = session
+				}
+				ViewData.Results = append(ViewData.Results, result)
+			}
+			logger.Info.Printf("%v: Bulk %v of appointments on [%v] by [%v].", util.CurrFuncName(), ViewData.Action, dateReq, myUser.Username)
+		}
+
+		if err := getTemplates(req).ExecuteTemplate(res, "holidayAppointments.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// getAppointmentsByDate returns all appointments on a given date.
+func getAppointmentsByDate(appointmentTree *app.BinarySearchTree, date string) []*app.Appointment {
+	chn := make(chan []*app.Appointment)
+	go (*appointmentTree).SearchAllByField("date", date, chn)
+	return <-chn
+}
+
+// hasAvailableSession checks if any session in the list is available.
+func hasAvailableSession(sessions []app.AppSession) bool {
+	for _, v := range sessions {
+		if v.Available {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyUser sends a message to user through the notifier.
+func notifyUser(notifier notification.Notifier, recipient *user.User, subject, body string) {
+	msg := notification.NewMessage(recipient.Username, recipient.MobileNumber, subject, body).WithEmail(recipient.Email)
+	if err := notifier.Notify(msg); err != nil {
+		logger.Error.Printf("%v: Error notifying user [%v]: %v", util.CurrFuncName(), recipient.Username, err)
+	}
+}
+
+// getLogAlertSink returns an alert sink which logs tampering of log files and notifies all admins.
+func getLogAlertSink(userList *user.DoublyLinkedList, notifier notification.Notifier) logger.AlertSink {
+	return logger.AlertFunc(func(alert logger.Alert) error {
+		logger.Error.Log("Log file tampering detected.", "file", alert.File, "line", alert.Line, "reason", alert.Reason)
+		location := alert.File
+		if alert.Line > 0 {
+			location = fmt.Sprintf("line %d of %v", alert.Line, alert.File)
+		}
+		body := fmt.Sprintf("Tampering of %v was detected at %v: %v.", location, alert.Time.Format("02-Jan-2006 15:04"), alert.Reason)
+		for _, v := range (*userList).GetList() {
+			if admin := v.(*user.User); admin.Role == enumAdmin && !admin.IsDeleted {
+				notifyUser(notifier, admin, "Log file tampering detected", body)
+			}
+		}
+		return nil
+	})
+}
+
+// getIntegrityAlert returns the function alerting all admins of data, template and configuration files
+// modified outside of the application.
+func getIntegrityAlert(userList *user.DoublyLinkedList, notifier notification.Notifier) func(status integrity.FileStatus) {
+	return func(status integrity.FileStatus) {
+		logger.Error.Log("File tampering detected.", "file", status.Path, "reason", status.Reason, "mode", getIntegrityMode())
+		body := fmt.Sprintf("Tampering of %v was detected at %v: %v. Review the file at /integrity.", status.Path, status.DetectedAt.Format("02-Jan-2006 15:04"), status.Reason)
+		for _, v := range (*userList).GetList() {
+			if admin := v.(*user.User); admin.Role == enumAdmin && !admin.IsDeleted {
+				notifyUser(notifier, admin, "File tampering detected", body)
+			}
+		}
+	}
+}
+
+// waitlistHandler handles request to manage the waiting list.
+// Patients are able to join the waiting list of a dentist within a date range and view slots offered to them,
+// admin is able to view and remove all waitlist entries.
+func waitlistHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, waitlist *app.Waitlist) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		type EntryStruct struct {
+			app.WaitlistEntry
+			PatientUser *user.User
+			DentistUser *user.User
+			OfferActive bool
+			OfferTime   string
+		}
+
+		ViewData := struct {
+			page
+			Entries      []EntryStruct
+			Dentists     []*user.User
+			TodayDate    string
+			InputDentist string
+			InputDate    string
+			Successful   bool
+			IsInputError bool
+		}{
+			newPage(req, "Waiting List", "WL"),
+			nil,
+			(*userList).GetDentistList(),
+			time.Now().Format("2006-01-02"),
+			strings.TrimSpace(req.FormValue("dentist")),
+			strings.TrimSpace(req.FormValue("date")),
+			false,
+			false,
+		}
+
+		// Process form submission
+		if req.Method == http.MethodPost {
+			switch req.FormValue("action") {
+			case "join":
+				inputDentist := strings.TrimSpace(req.FormValue("inputDentist"))
+				inputFromDate := strings.TrimSpace(req.FormValue("inputFromDate"))
+				inputToDate := strings.TrimSpace(req.FormValue("inputToDate"))
+				dentist := (*userList).FindByUsername(inputDentist)
+				fromDate, fromErr := time.Parse("2006-01-02", inputFromDate)
+				toDate, toErr := time.Parse("2006-01-02", inputToDate)
+				if myUser.Role != enumPatient || dentist == nil || dentist.Role != enumDentist || fromErr != nil || toErr != nil ||
+					toDate.Before(fromDate) || inputFromDate < ViewData.TodayDate {
+					ViewData.IsInputError = true
+					break
+				}
+				entry := waitlist.Join(myUser.Username, dentist.Username, inputFromDate, inputToDate, time.Now())
+				recordAudit(req, myUser.Username, "waitlist.join", strconv.Itoa(entry.ID), nil, entry)
+				logger.Info.Printf("%v: Patient [%v] joined waiting list of dentist [%v] from [%v] to [%v].", util.CurrFuncName(), myUser.Username, dentist.Username, inputFromDate, inputToDate)
+				ViewData.Successful = true
+			case "remove":
+				entryID, _ := strconv.Atoi(req.FormValue("entryID"))
+				patient := myUser.Username
+				if myUser.Role == enumAdmin {
+					patient = ""
+				}
+				if !waitlist.Remove(entryID, patient) {
+					ViewData.IsInputError = true
+					break
+				}
+				recordAudit(req, myUser.Username, "waitlist.remove", strconv.Itoa(entryID), nil, nil)
+				ViewData.Successful = true
+			default:
+				ViewData.IsInputError = true
+			}
+		}
+
+		patient := myUser.Username
+		if myUser.Role == enumAdmin {
+			patient = ""
+		}
+		for _, v := range waitlist.GetEntries(patient) {
+			entry := EntryStruct{
+				WaitlistEntry: v,
+				PatientUser:   (*userList).FindByUsername(v.Patient),
+				DentistUser:   (*userList).FindByUsername(v.Dentist),
+			}
+			if v.Offer != nil && v.Offer.ExpiresAt.After(time.Now()) {
+				entry.OfferActive = true
+				session := (**appointmentSessionList).Get(v.Offer.Session).(app.AppSession)
+				entry.OfferTime = session.StartTime + " - " + session.EndTime
+			}
+			if entry.PatientUser != nil && entry.DentistUser != nil {
+				ViewData.Entries = append(ViewData.Entries, entry)
+			}
+		}
+
+		if err := getTemplates(req).ExecuteTemplate(res, "waitlist.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// offerFreedSlot offers each freed session to the earliest eligible patient on the waitlist,
+// the patient is notified of the slot held for them.
+func offerFreedSlot(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, userList *user.DoublyLinkedList, waitlist *app.Waitlist, notifier notification.Notifier, dentist *user.User, date string, sessionNum, duration int) {
+	for i := sessionNum; i < sessionNum+duration; i++ {
+		offerFreedSession(appointmentSessionList, appointmentTree, userList, waitlist, notifier, dentist, date, i)
+	}
+}
+
+// offerFreedSession offers a single freed session to the earliest eligible patient on the waitlist.
+func offerFreedSession(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, userList *user.DoublyLinkedList, waitlist *app.Waitlist, notifier notification.Notifier, dentist *user.User, date string, sessionNum int) {
+	// Check that slot was not booked by another patient
+	if appointmentTree.IsBooked(date, sessionNum, dentist) {
+		return
+	}
+	session := (**appointmentSessionList).Get(sessionNum).(app.AppSession)
+	entry := waitlist.OfferSlot(dentist.Username, date, session, time.Now(), app.GetWaitlistHoldDuration())
+	if entry == nil {
+		return
+	}
+	patient := (*userList).FindByUsername(entry.Patient)
+	if patient == nil {
+		return
+	}
+	logger.Info.Printf("%v: Slot dentist:[%v], date:[%v], session:[%v] offered to waitlisted patient [%v].", util.CurrFuncName(), dentist.Username, date, sessionNum, patient.Username)
+	notifyUser(notifier, patient, "Appointment Slot Available",
+		fmt.Sprintf("A slot with Dr. %v %v on %v, %v - %v is now available and held for you until %v. Log in and visit your waiting list to book it.",
+			dentist.FirstName, dentist.LastName, util.FormatDate(date), session.StartTime, session.EndTime, entry.Offer.ExpiresAt.Format("02-Jan-2006 15:04")))
+}
+
+// processWaitlistOffers run as Go routine to release expired holds every minute until ctx is done,
+// released slots are offered to the next eligible patient on the waitlist.
+func processWaitlistOffers(ctx context.Context, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, userList *user.DoublyLinkedList, waitlist *app.Waitlist, notifier notification.Notifier) {
+	for {
+		for _, v := range waitlist.ExpireOffers(time.Now()) {
+			if dentist := (*userList).FindByUsername(v.Dentist); dentist != nil {
+				offerFreedSlot(appointmentSessionList, appointmentTree, userList, waitlist, notifier, dentist, v.Date, v.Session, 1)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Minute):
+		}
+	}
+}
+
+// processReminders run as Go routine to send reminders of upcoming appointments every minute through each channel
+// the patient has not muted reminders for. Reminders are sent once per appointment and channel,
+// failed reminders are retried up to app.MaxReminderAttempts times. Deliveries of past appointments are pruned daily.
+// Reminders are sent REMINDER_LEAD_TIME before appointments as of the current configuration. Stops when ctx is done.
+func processReminders(ctx context.Context, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, reminderLog *app.ReminderLog, dispatcher *notification.Dispatcher, messageTemplates *notification.Templates) {
+	var lastPruned string
+	for {
+		now := time.Now()
+		if today := now.Format("2006-01-02"); today != lastPruned {
+			reminderLog.Prune(today)
+			lastPruned = today
+		}
+		for _, v := range appointmentTree.GetDueReminders(appointmentSessionList, now, app.GetReminderLeadTime()) {
+			patient, ok := v.Patient.(*user.User)
+			if !ok || patient == nil || patient.IsDeleted {
+				continue
+			}
+			msg := getReminderMessage(appointmentSessionList, appointmentClinics, appointmentTypes, messageTemplates, v, patient)
+			for channel, notifier := range dispatcher.Channels {
+				if !dispatcher.Preferences.Allows(patient.Username, channel, notification.CategoryReminder) || !reminderLog.Start(v, channel, now) {
+					continue
+				}
+				err := notifier.Notify(msg)
+				switch {
+				case err == nil:
+					reminderLog.Finish(v, channel, app.ReminderSent, nil, time.Now())
+				case errors.Is(err, notification.ErrNoRecipient):
+					reminderLog.Finish(v, channel, app.ReminderSkipped, err, time.Now())
+				default:
+					logger.Error.Printf("%v: Error sending %v reminder of appointment [%v]: %v", util.CurrFuncName(), channel, v.ID, err)
+					reminderLog.Finish(v, channel, app.ReminderFailed, err, time.Now())
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Minute):
+		}
+	}
+}
+
+// getReminderMessage returns the reminder message of an appointment to the patient,
+// rendered from the "reminder.patient" message template when defined.
+func getReminderMessage(appointmentSessionList **dll.DoublyLinkedList, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, messageTemplates *notification.Templates, appointment *app.Appointment, patient *user.User) *notification.Message {
+	if messageTemplates.Has("reminder.patient") {
+		data := getMessageData(appointmentSessionList, appointmentClinics, appointmentTypes, appointment, nil, "")
+		data.Recipient = patient
+		subject, body, err := messageTemplates.Render("reminder.patient", data)
+		if err == nil {
+			return notification.NewMessage(patient.Username, patient.MobileNumber, subject, body).WithEmail(patient.Email).WithCategory(notification.CategoryReminder)
+		}
+		logger.Error.Printf("%v: Error rendering reminder: %v", util.CurrFuncName(), err)
+	}
+	session := (**appointmentSessionList).Get(appointment.Session).(app.AppSession)
+	body := fmt.Sprintf("Reminder: you have an appointment on %v at %v", util.FormatDate(appointment.Date), session.StartTime)
+	if dentist, ok := appointment.Dentist.(*user.User); ok && dentist != nil {
+		body += fmt.Sprintf(" with Dr. %v %v", dentist.FirstName, dentist.LastName)
+	}
+	if clinic := appointmentClinics.GetForAppointment(appointment); clinic != nil {
+		body += fmt.Sprintf(" at %v", clinic.Name)
+		if clinic.Address != "" {
+			body += fmt.Sprintf(", %v", clinic.Address)
+		}
+	}
+	body += "."
+	return notification.NewMessage(patient.Username, patient.MobileNumber, "Appointment Reminder", body).WithEmail(patient.Email).WithCategory(notification.CategoryReminder)
+}
+
+// messageData struct stores the appointment details available to message templates.
+// Previous details are set for rescheduled appointments only.
+type messageData struct {
+	Recipient       *user.User
+	Patient         *user.User
+	Dentist         *user.User
+	Date            string
+	Time            string
+	Type            string
+	Branch          *app.Clinic
+	PreviousDentist *user.User
+	PreviousDate    string
+	PreviousTime    string
+	Reason          string
+}
+
+// getMessageData returns the details of an appointment for message templates.
+func getMessageData(appointmentSessionList **dll.DoublyLinkedList, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, appointment, previous *app.Appointment, reason string) messageData {
+	data := messageData{
+		Date:   util.FormatDate(appointment.Date),
+		Time:   getAppointmentTime(appointmentSessionList, appointment),
+		Branch: appointmentClinics.GetForAppointment(appointment),
+		Reason: reason,
+	}
+	data.Patient, _ = appointment.Patient.(*user.User)
+	data.Dentist, _ = appointment.Dentist.(*user.User)
+	if appointmentType := appointmentTypes.GetForAppointment(appointment); appointmentType != nil {
+		data.Type = appointmentType.Name
+	}
+	if previous != nil {
+		data.PreviousDentist, _ = previous.Dentist.(*user.User)
+		data.PreviousDate = util.FormatDate(previous.Date)
+		data.PreviousTime = getAppointmentTime(appointmentSessionList, previous)
+	}
+	return data
+}
+
+// getAppointmentTime returns the start time of the first session and end time of the last session of an appointment.
+func getAppointmentTime(appointmentSessionList **dll.DoublyLinkedList, appointment *app.Appointment) string {
+	size := (**appointmentSessionList).GetSize()
+	if appointment.Session < 1 || appointment.Session > size {
+		return ""
+	}
+	first := (**appointmentSessionList).Get(appointment.Session).(app.AppSession)
+	last := first
+	if appointment.GetLastSession() <= size {
+		last = (**appointmentSessionList).Get(appointment.GetLastSession()).(app.AppSession)
+	}
+	return first.StartTime + " - " + last.EndTime
+}
+
+// notifyAppointmentEvent sends the message of an appointment event to the patient and dentist,
+// the previous dentist of a rescheduled appointment is also notified. Messages are rendered from the
+// "<event>.patient" and "<event>.dentist" message templates, recipients without template are not notified.
+func notifyAppointmentEvent(appointmentSessionList **dll.DoublyLinkedList, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, messageTemplates *notification.Templates, notifier notification.Notifier, event app.Event) {
+	type recipientStruct struct {
+		role string
+		user *user.User
+	}
+
+	data := getMessageData(appointmentSessionList, appointmentClinics, appointmentTypes, event.Appointment, event.Previous, event.Reason)
+	recipients := []recipientStruct{{enumPatient, data.Patient}, {enumDentist, data.Dentist}}
+	if data.PreviousDentist != nil && data.Dentist != nil && data.PreviousDentist.Username != data.Dentist.Username {
+		recipients = append(recipients, recipientStruct{enumDentist, data.PreviousDentist})
+	}
+	for _, v := range recipients {
+		name := event.Type + "." + v.role
+		if v.user == nil || v.user.IsDeleted || !messageTemplates.Has(name) {
+			continue
+		}
+		data.Recipient = v.user
+		subject, body, err := messageTemplates.Render(name, data)
+		if err != nil {
+			logger.Error.Printf("%v: Error rendering message [%v]: %v", util.CurrFuncName(), name, err)
+			continue
+		}
+		msg := notification.NewMessage(v.user.Username, v.user.MobileNumber, subject, body).WithEmail(v.user.Email).WithCategory(event.Type)
+		if err = notifier.Notify(msg); err != nil {
+			logger.Error.Printf("%v: Error notifying user [%v]: %v", util.CurrFuncName(), v.user.Username, err)
+		}
+	}
+}
+
+// notificationSettingsHandler handles request to manage the notification preferences of the logged in user,
+// patients and dentists are able to mute delivery channels and categories of messages.
+func notificationSettingsHandler(dispatcher *notification.Dispatcher) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		ViewData := struct {
+			page
+			Channels   []string
+			Categories []string
+			Preference notification.Preference
+			Successful bool
+		}{
+			newPage(req, "Notification Settings", ""),
+			nil,
+			[]string{notification.CategoryBooked, notification.CategoryRescheduled, notification.CategoryCancelled},
+			dispatcher.Preferences.Get(myUser.Username),
+			false,
+		}
+		for channel := range dispatcher.Channels {
+			ViewData.Channels = append(ViewData.Channels, channel)
+		}
+		sort.Strings(ViewData.Channels)
+		if myUser.Role == enumPatient {
+			ViewData.Categories = append(ViewData.Categories, notification.CategoryReminder)
+		}
+
+		// Process form submission, unchecked channels and categories are muted
+		if req.Method == http.MethodPost {
+			preference := notification.Preference{Username: myUser.Username}
+			for _, v := range ViewData.Channels {
+				if req.FormValue("channel_"+v) == "" {
+					preference.MutedChannels = append(preference.MutedChannels, v)
+				}
+			}
+			for _, v := range ViewData.Categories {
+				if req.FormValue("category_"+v) == "" {
+					preference.MutedCategories = append(preference.MutedCategories, v)
+				}
+			}
+			dispatcher.Preferences.Set(preference)
+			recordAudit(req, myUser.Username, "notification.update", myUser.Username, ViewData.Preference, preference)
+			logger.Info.Printf("%v: Notification preferences of [%v] updated.", util.CurrFuncName(), myUser.Username)
+			ViewData.Preference = preference
+			ViewData.Successful = true
+		}
+		if err := getTemplates(req).ExecuteTemplate(res, "notificationSettings.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// getSlotQuery reads open slot search filters from request query or form values.
+// Dentist (username), branch (clinic code), type (appointment type code), from and to (YYYY-MM-DD), weekday (repeatable),
+// time (morning, afternoon or evening) and limit are accepted,
+// all dentists are searched over the next 30 days when no filter is given. Returns false if any filter is invalid.
+func getSlotQuery(req *http.Request, userList *user.DoublyLinkedList, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, rulesEngine *app.RulesEngine, waitlist *app.Waitlist, myUser *user.User) (app.SlotQuery, bool) {
+	now := time.Now()
+	today, _ := time.Parse("2006-01-02", now.Format("2006-01-02"))
+	query := app.SlotQuery{
+		Dentists:    userList.GetDentistList(),
+		FromDate:    today,
+		ToDate:      today.AddDate(0, 0, slotSearchDefaultDays-1),
+		TimeOfDay:   strings.TrimSpace(req.FormValue("time")),
+		Limit:       slotSearchDefaultLimit,
+		Duration:    1,
+		Now:         now,
+		MinLeadTime: rulesEngine.GetConfig().MinLeadTime,
+	}
+	if inputDentist := strings.TrimSpace(req.FormValue("dentist")); inputDentist != "" {
+		dentist := (*userList).FindByUsername(inputDentist)
+		if dentist == nil || dentist.Role != enumDentist {
+			return query, false
+		}
+		query.Dentists = []*user.User{dentist}
+	}
+	if inputBranch := strings.TrimSpace(req.FormValue("branch")); inputBranch != "" {
+		clinic := appointmentClinics.Get(inputBranch)
+		if clinic == nil {
+			return query, false
+		}
+		query.Branch = clinic.Code
+		query.Dentists = clinic.FilterDentists(query.Dentists)
+	}
+	if inputType := strings.TrimSpace(req.FormValue("type")); inputType != "" {
+		appointmentType := appointmentTypes.Get(inputType)
+		if appointmentType == nil {
+			return query, false
+		}
+		query.Duration = appointmentType.GetDuration()
+		// Only dentists performing the treatment are searched
+		var dentists []*user.User
+		for _, v := range query.Dentists {
+			if appointmentType.AllowsDentist(v.Username) {
+				dentists = append(dentists, v)
+			}
+		}
+		query.Dentists = dentists
+	}
+	if inputFromDate := strings.TrimSpace(req.FormValue("from")); inputFromDate != "" {
+		fromDate, err := time.Parse("2006-01-02", inputFromDate)
+		if err != nil {
+			return query, false
+		}
+		if fromDate.After(today) {
+			query.FromDate = fromDate
+		}
+		query.ToDate = query.FromDate.AddDate(0, 0, slotSearchDefaultDays-1)
+	}
+	if inputToDate := strings.TrimSpace(req.FormValue("to")); inputToDate != "" {
+		toDate, err := time.Parse("2006-01-02", inputToDate)
+		if err != nil || toDate.Before(query.FromDate) {
+			return query, false
+		}
+		query.ToDate = toDate
+	}
+	if maxDate := query.FromDate.AddDate(0, 0, slotSearchMaxDays-1); query.ToDate.After(maxDate) {
+		query.ToDate = maxDate
+	}
+	if horizon := rulesEngine.GetConfig().MaxBookingHorizon; horizon > 0 {
+		if maxDate := today.AddDate(0, 0, horizon); query.ToDate.After(maxDate) {
+			query.ToDate = maxDate
+		}
+	}
+	for _, v := range req.Form["weekday"] {
+		found := false
+		for _, day := range app.Weekdays {
+			if strings.EqualFold(v, day.String()) {
+				query.Weekdays = append(query.Weekdays, day)
+				found = true
+			}
+		}
+		if !found {
+			return query, false
+		}
+	}
+	switch query.TimeOfDay {
+	case app.TimeOfDayAny, app.TimeOfDayMorning, app.TimeOfDayAfternoon, app.TimeOfDayEvening:
+	default:
+		return query, false
+	}
+	if inputLimit := strings.TrimSpace(req.FormValue("limit")); inputLimit != "" {
+		limit, err := strconv.Atoi(inputLimit)
+		if err != nil || limit < 1 {
+			return query, false
+		}
+		if limit > slotSearchMaxLimit {
+			limit = slotSearchMaxLimit
+		}
+		query.Limit = limit
+	}
+	// Slots held for other patients on the waiting list are not open
+	query.Exclude = func(dentist *user.User, date string, session app.AppSession) bool {
+		holder, _ := waitlist.GetHolder(dentist.Username, date, session.Num, now)
+		return holder != "" && holder != myUser.Username
+	}
+	return query, true
+}
+
+// appointmentFindHandler handles request to search for the earliest open slots across dentists.
+func appointmentFindHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, rulesEngine *app.RulesEngine, waitlist *app.Waitlist) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		if err := req.ParseForm(); err != nil {
+			logger.Error.Println(err)
+		}
+
+		type WeekdayStruct struct {
+			Day     time.Weekday
+			Checked bool
+		}
+
+		ViewData := struct {
+			page
+			Dentists      []*user.User
+			Weekdays      []WeekdayStruct
+			TodayDate     string
+			Slots         []app.OpenSlot
+			Query         app.SlotQuery
+			InputDentist  string
+			InputTime     string
+			FormProcessed bool
+			IsInputError  bool
+			Types         app.AppointmentTypes
+			InputType     string
+			Clinics       app.Clinics
+			InputBranch   string
+		}{
+			newPage(req, "Find Earliest Appointment", "FEA"),
+			userList.GetDentistList(),
+			nil,
+			time.Now().Format("2006-01-02"),
+			nil,
+			app.SlotQuery{},
+			strings.TrimSpace(req.FormValue("dentist")),
+			strings.TrimSpace(req.FormValue("time")),
+			false,
+			false,
+			appointmentTypes,
+			strings.TrimSpace(req.FormValue("type")),
+			appointmentClinics,
+			strings.TrimSpace(req.FormValue("branch")),
+		}
+		for _, day := range app.Weekdays {
+			checked := false
+			for _, v := range req.Form["weekday"] {
+				if strings.EqualFold(v, day.String()) {
+					checked = true
+				}
+			}
+			ViewData.Weekdays = append(ViewData.Weekdays, WeekdayStruct{day, checked})
+		}
+
+		query, ok := getSlotQuery(req, userList, appointmentClinics, appointmentTypes, rulesEngine, waitlist, myUser)
+		ViewData.Query = query
+		if !ok {
+			ViewData.IsInputError = true
+		} else if len(req.Form) > 0 {
+			ViewData.Slots = app.FindOpenSlots(appointmentSessionList, appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, query)
+			ViewData.FormProcessed = true
+		}
+		if err := getTemplates(req).ExecuteTemplate(res, "appointmentFind.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// slotSearchAPIHandler handles request to search for open slots and responds in JSON,
+// accepts the same filters as appointmentFindHandler.
+func slotSearchAPIHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, rulesEngine *app.RulesEngine, waitlist *app.Waitlist) http.HandlerFunc {
+	type SlotJSON struct {
+		Dentist     string `json:"dentist"`
+		DentistName string `json:"dentistName"`
+		Date        string `json:"date"`
+		Session     int    `json:"session"`
+		Sessions    int    `json:"sessions"`
+		Branch      string `json:"branch,omitempty"`
+		StartTime   string `json:"startTime"`
+		EndTime     string `json:"endTime"`
+	}
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+		if req.Method != http.MethodGet {
+			writeJSON(res, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		query, ok := getSlotQuery(req, userList, appointmentClinics, appointmentTypes, rulesEngine, waitlist, myUser)
+		if !ok {
+			writeJSON(res, http.StatusBadRequest, map[string]string{"error": "invalid search filter"})
+			return
+		}
+		slots := []SlotJSON{}
+		for _, v := range app.FindOpenSlots(appointmentSessionList, appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, query) {
+			slots = append(slots, SlotJSON{
+				Dentist:     v.Dentist.Username,
+				DentistName: fmt.Sprintf("Dr. %v %v", v.Dentist.FirstName, v.Dentist.LastName),
+				Date:        v.Date,
+				Session:     v.Session.Num,
+				Sessions:    query.Duration,
+				Branch:      query.Branch,
+				StartTime:   v.Session.StartTime,
+				EndTime:     v.Session.EndTime,
+			})
+		}
+		writeJSON(res, http.StatusOK, map[string]interface{}{
+			"from":  query.FromDate.Format("2006-01-02"),
+			"to":    query.ToDate.Format("2006-01-02"),
+			"slots": slots,
+		})
+	}
+}
+
+// appointmentCalendarHandler handles request to download an appointment as iCalendar (.ics) file,
+// patients and dentists are only able to download their own appointments.
+func appointmentCalendarHandler(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, calendarFeeds *app.CalendarFeeds) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		appointmentID, _ := strconv.Atoi(mux.Vars(req)["id"])
+		appointment := (*appointmentTree).GetAppointmentByID(appointmentID)
+		if appointment == nil || (myUser.Role == enumPatient && appointment.Patient.(*user.User).Username != myUser.Username) ||
+			(myUser.Role == enumDentist && appointment.Dentist.(*user.User).Username != myUser.Username) {
+			logger.Error.Printf("%v: Appointment not found for [%v] ID:[%v]", util.CurrFuncName(), myUser.Username, appointmentID)
+			http.Error(res, "Appointment not found", http.StatusNotFound)
+			return
+		}
+		event, err := app.GetCalendarEvent(appointmentSessionList, appointmentClinics, appointmentTypes, appointment, myUser.Role, calendarFeeds.GetSequence(appointment.ID))
+		if err != nil {
+			logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+			http.Error(res, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		writeCalendar(res, fmt.Sprintf("attachment; filename=\"appointment-%d.ics\"", appointment.ID), "", []app.CalendarEvent{event})
+	}
+}
+
+// calendarFeedHandler handles request of a user's calendar feed identified by the secret token in the URL,
+// no login is required so that calendar applications are able to subscribe.
+// All appointments of the patient or dentist are included, recently cancelled appointments are marked as cancelled.
+func calendarFeedHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, calendarFeeds *app.CalendarFeeds) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		feedUser := (*userList).FindByUsername(calendarFeeds.GetUsername(mux.Vars(req)["token"]))
+		if feedUser == nil || feedUser.IsDeleted || (feedUser.Role != enumPatient && feedUser.Role != enumDentist) {
+			http.Error(res, "Calendar not found", http.StatusNotFound)
+			return
+		}
+
+		var events []app.CalendarEvent
+		for _, v := range (*appointmentTree).GetAllAppointments(feedUser, feedUser.Role) {
+			event, err := app.GetCalendarEvent(appointmentSessionList, appointmentClinics, appointmentTypes, v, feedUser.Role, calendarFeeds.GetSequence(v.ID))
+			if err != nil {
+				logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+				continue
+			}
+			events = append(events, event)
+		}
+		for _, v := range calendarFeeds.GetCancelled(feedUser.Username) {
+			// Cancelled appointments are stored with usernames, names are shown when users are found
+			appointment := *v.Appointment
+			if patient := (*userList).FindByUsername(appointment.Patient.(string)); patient != nil {
+				appointment.Patient = patient
+			}
+			if dentist := (*userList).FindByUsername(appointment.Dentist.(string)); dentist != nil {
+				appointment.Dentist = dentist
+			}
+			event, err := app.GetCalendarEvent(appointmentSessionList, appointmentClinics, appointmentTypes, &appointment, feedUser.Role, v.Sequence)
+			if err != nil {
+				logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+				continue
+			}
+			event.Cancelled = true
+			events = append(events, event)
+		}
+		writeCalendar(res, "inline; filename=\"appointments.ics\"", "Dental Appointments", events)
+	}
+}
+
+// writeCalendar responds with events as iCalendar object.
+func writeCalendar(res http.ResponseWriter, disposition, name string, events []app.CalendarEvent) {
+	res.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	res.Header().Set("Content-Disposition", disposition)
+	res.Header().Set("Cache-Control", "no-store")
+	if err := app.WriteCalendar(res, name, events, time.Now()); err != nil {
+		logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+	}
+}
+
+// calendarHandler handles request to view the calendar feed URL of the logged in patient or dentist,
+// users are able to reset the URL when it was shared by mistake.
+func calendarHandler(calendarFeeds *app.CalendarFeeds) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		ViewData := struct {
+			page
+			FeedURL    string
+			WebcalURL  string
+			Successful bool
+		}{
+			newPage(req, "Calendar Feed", ""),
+			"",
+			"",
+			false,
+		}
+
+		// Process form submission
+		token := ""
+		if req.Method == http.MethodPost && req.FormValue("action") == "reset" {
+			token = calendarFeeds.ResetToken(myUser.Username)
+			recordAudit(req, myUser.Username, "calendar.reset", myUser.Username, nil, nil)
+			logger.Info.Printf("%v: Calendar feed of [%v] reset.", util.CurrFuncName(), myUser.Username)
+			ViewData.Successful = true
+		} else {
+			token = calendarFeeds.GetToken(myUser.Username)
+		}
+		ViewData.FeedURL = fmt.Sprintf("https://%v/calendar/%v.ics", req.Host, token)
+		ViewData.WebcalURL = fmt.Sprintf("webcal://%v/calendar/%v.ics", req.Host, token)
+
+		if err := getTemplates(req).ExecuteTemplate(res, "calendar.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// importResult stores the validation result of a row in an imported file.
+type importResult struct {
+	Line    int
+	Name    string
+	Valid   bool
+	Message string
+}
+
+// importHandler handles request to import users or appointments from CSV file, only admin has the privilege to import data.
+// Files are validated and reported without changes first (dry run), a file is only imported when all rows are valid.
+func importHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		ViewData := struct {
+			page
+			InputKind     string
+			FileName      string
+			Results       []importResult
+			ValidCount    int
+			RejectedCount int
+			FormProcessed bool
+			Applied       bool
+			ErrorMsg      string
+		}{
+			newPage(req, "Import Data", "IMP"),
+			"users",
+			"",
+			nil,
+			0,
+			0,
+			false,
+			false,
+			"",
+		}
+
+		if req.Method == http.MethodPost {
+			req.Body = http.MaxBytesReader(res, req.Body, importMaxSize)
+			if err := req.ParseMultipartForm(importMaxSize); err != nil {
+				ViewData.ErrorMsg = "File is too large or cannot be read."
+			} else if file, header, err := req.FormFile("file"); err != nil {
+				ViewData.ErrorMsg = "Please select a CSV file to import."
+			} else {
+				defer file.Close()
+				ViewData.InputKind = req.FormValue("kind")
+				ViewData.FileName = header.Filename
+				apply := req.FormValue("action") == "import"
+				records, err := spreadsheet.ReadCSV(file)
+				if err != nil {
+					logger.Warning.Printf("%v: %v", util.CurrFuncName(), err)
+					ViewData.ErrorMsg = "File is not a valid CSV file with a header row."
+				} else if ViewData.InputKind == "appointments" {
+					ViewData.Results, ViewData.Applied = importAppointments(userList, appointmentSessionList, appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentTypes, records, apply)
+				} else {
+					ViewData.InputKind = "users"
+					ViewData.Results, ViewData.Applied = importUsers(userList, records, apply)
+				}
+				for _, v := range ViewData.Results {
+					if v.Valid {
+						ViewData.ValidCount++
+					} else {
+						ViewData.RejectedCount++
+					}
+				}
+				ViewData.FormProcessed = ViewData.ErrorMsg == ""
+				if ViewData.Applied {
+					logger.Info.Printf("%v: [%v] %v imported from [%v] by [%v].", util.CurrFuncName(), ViewData.ValidCount, ViewData.InputKind, ViewData.FileName, myUser.Username)
+					recordAudit(req, myUser.Username, "import."+ViewData.InputKind, ViewData.FileName, nil, map[string]int{"rows": ViewData.ValidCount})
+				}
+			}
+		}
+		if err := getTemplates(req).ExecuteTemplate(res, "import.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// importUsers validates imported users with the same rules as sign up, usernames and mobile numbers must not be
+// registered or repeated in the file. All users are added when apply is true and every row is valid.
+// Users without password are given a random password which has to be changed by admin before the user can log in.
+func importUsers(userList *user.DoublyLinkedList, records []spreadsheet.Record, apply bool) ([]importResult, bool) {
+	var (
+		results   []importResult
+		users     []*user.User
+		usernames = make(map[string]bool)
+		mobiles   = make(map[string]bool)
+		valid     = true
+	)
+	for _, record := range records {
+		var messages []string
+		newUser := &user.User{
+			Username:  record.Get("username"),
+			Password:  record.Get("password"),
+			Role:      strings.ToLower(record.Get("role")),
+			FirstName: record.Get("firstName"),
+			LastName:  record.Get("lastName"),
+			Email:     record.Get("email"),
+		}
+		mobileNumber := record.Get("mobileNumber")
+		if newUser.Role == "" {
+			newUser.Role = enumPatient
+		}
+
+		if validator.IsEmpty(newUser.Username) || !validator.IsValidUsername(newUser.Username) {
+			messages = append(messages, "Username is not valid.")
+		} else if (*userList).FindByUsername(newUser.Username) != nil {
+			messages = append(messages, "Username is already taken.")
+		} else if usernames[newUser.Username] {
+			messages = append(messages, "Username is repeated in the file.")
+		}
+		usernames[newUser.Username] = true
+		if newUser.Role != enumPatient && newUser.Role != enumDentist {
+			messages = append(messages, "Role must be patient or dentist.")
+		}
+		if !validator.IsEmpty(newUser.Password) && !validator.IsValidPassword(newUser.Password) {
+			messages = append(messages, "Password is not valid.")
+		}
+		if validator.IsEmpty(newUser.FirstName) || !validator.IsValidName(newUser.FirstName) {
+			messages = append(messages, "First name is not valid.")
+		}
+		if validator.IsEmpty(newUser.LastName) || !validator.IsValidName(newUser.LastName) {
+			messages = append(messages, "Last name is not valid.")
+		}
+		// Mobile number is required for patients only
+		if validator.IsEmpty(mobileNumber) {
+			if newUser.Role == enumPatient {
+				messages = append(messages, "Mobile number is required for patients.")
+			}
+		} else if !validator.IsMobileNumber(mobileNumber) {
+			messages = append(messages, "Mobile number is not valid.")
+		} else {
+			newUser.MobileNumber, _ = strconv.Atoi(mobileNumber)
+			if (*userList).SearchByMobileNumber(newUser.MobileNumber) != nil {
+				messages = append(messages, "Mobile number is already registered.")
+			} else if mobiles[mobileNumber] {
+				messages = append(messages, "Mobile number is repeated in the file.")
+			}
+			mobiles[mobileNumber] = true
+		}
+		if !validator.IsEmpty(newUser.Email) && !validator.IsEmail(newUser.Email) {
+			messages = append(messages, "Email is not valid.")
+		}
+
+		result := importResult{Line: record.Line, Name: newUser.Username, Valid: len(messages) == 0}
+		if result.Valid && validator.IsEmpty(newUser.Password) {
+			messages = append(messages, "No password provided, set a password before the user logs in.")
+		}
+		result.Message = strings.Join(messages, " ")
+		results = append(results, result)
+		valid = valid && result.Valid
+		users = append(users, newUser)
+	}
+	if !apply || !valid || len(users) == 0 {
+		return results, false
+	}
+
+	// Passwords are hashed before any user is added so that a failure leaves user data unchanged
+	for _, v := range users {
+		password := v.Password
+		if validator.IsEmpty(password) {
+			password = uuid.NewV4().String()
+		}
+		bPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			logger.Error.Printf("%v: %v", util.CurrFuncName(), err)
+			return results, false
+		}
+		v.Password = string(bPassword)
+	}
+	for _, v := range users {
+		if err := (*userList).Add(v); err != nil {
+			logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+		}
+	}
+	(*userList).InsertionSort()
+	user.AddUserDataList(users)
+	return results, true
+}
+
+// importAppointments validates imported appointments, patients are found by username or mobile number.
+// Appointments are checked against existing appointments, the rota, clinic closures, chairs and each other,
+// all appointments are added when apply is true and every row is valid. Notifications are not sent for imports.
+func importAppointments(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, records []spreadsheet.Record, apply bool) ([]importResult, bool) {
+	var (
+		results      []importResult
+		appointments []*app.Appointment
+		index        []int
+		ids          = make(map[int]bool)
+		sessionCount = (**appointmentSessionList).GetSize()
+	)
+	for _, record := range records {
+		var messages []string
+		patientReq, dentistReq := record.Get("patient"), record.Get("dentist")
+		dateReq, sessionReq := record.Get("date"), record.Get("session")
+		typeReq, branchReq := record.Get("type"), record.Get("branch")
+
+		patient := (*userList).FindByUsername(patientReq)
+		if patient == nil && validator.IsMobileNumber(patientReq) {
+			mobileNumber, _ := strconv.Atoi(patientReq)
+			patient = (*userList).SearchByMobileNumber(mobileNumber)
+		}
+		if patient == nil || patient.Role != enumPatient || patient.IsDeleted {
+			messages = append(messages, "Patient does not exist.")
+		}
+		dentist := (*userList).FindByUsername(dentistReq)
+		if dentist == nil || dentist.Role != enumDentist || dentist.IsDeleted {
+			messages = append(messages, "Dentist does not exist.")
+		}
+		if _, err := time.Parse("2006-01-02", dateReq); err != nil {
+			messages = append(messages, "Date must be in YYYY-MM-DD format.")
+		}
+		session, err := strconv.Atoi(sessionReq)
+		if err != nil || session < 1 || session > sessionCount {
+			messages = append(messages, fmt.Sprintf("Session must be between 1 and %v.", sessionCount))
+		}
+		var appointmentType *app.AppointmentType
+		if !validator.IsEmpty(typeReq) {
+			if appointmentType = appointmentTypes.Get(typeReq); appointmentType == nil {
+				messages = append(messages, "Appointment type does not exist.")
+			} else if dentist != nil && !appointmentType.AllowsDentist(dentist.Username) {
+				messages = append(messages, "Dentist does not perform the appointment type.")
+			}
+		}
+		if !validator.IsEmpty(branchReq) && appointmentClinics.Get(branchReq) == nil {
+			messages = append(messages, "Branch does not exist.")
+		}
+		if len(messages) == 0 && session+appointmentType.GetDuration()-1 > sessionCount {
+			messages = append(messages, "Appointment ends after the last session.")
+		}
+
+		results = append(results, importResult{Line: record.Line, Name: fmt.Sprintf("%v, %v, %v", patientReq, dentistReq, dateReq), Valid: len(messages) == 0, Message: strings.Join(messages, " ")})
+		if len(messages) > 0 {
+			continue
+		}
+		id := util.GenerateID()
+		for ids[id] || (*appointmentTree).GetAppointmentByID(id) != nil {
+			id = util.GenerateID()
+		}
+		ids[id] = true
+		appointment := app.New(id, patient, dentist, dateReq, session)
+		appointment.SetType(appointmentType)
+		appointment.Clinic = branchReq
+		appointments = append(appointments, appointment)
+		index = append(index, len(results)-1)
+	}
+
+	valid := len(appointments) == len(results)
+	rejected := app.ImportAppointments(appointments, appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, apply && valid && len(appointments) > 0)
+	for k, reason := range rejected {
+		results[index[k]].Valid = false
+		results[index[k]].Message = reason
+	}
+	return results, apply && valid && len(appointments) > 0 && len(rejected) == 0
+}
+
+// appointmentExportHandler handles request to export appointments as CSV or XLSX file, only admin has the privilege to export appointments.
+// Appointments are filtered by dentist, branch, type and date range.
+func appointmentExportHandler(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		dentistReq := strings.TrimSpace(req.FormValue("dentist"))
+		branchReq := strings.TrimSpace(req.FormValue("branch"))
+		typeReq := strings.TrimSpace(req.FormValue("type"))
+		fromReq := strings.TrimSpace(req.FormValue("from"))
+		toReq := strings.TrimSpace(req.FormValue("to"))
+
+		rows := [][]string{{"ID", "Date", "Session", "Start", "End", "Dentist", "Dentist Name", "Patient", "Patient Name", "Mobile Number", "Type", "Branch", "Chair", "Series"}}
+		for _, v := range (*appointmentTree).GetAllAppointments(nil, "") {
+			dentist, _ := v.Dentist.(*user.User)
+			patient, _ := v.Patient.(*user.User)
+			if dentist == nil || patient == nil {
+				continue
+			}
+			// Dates are in YYYY-MM-DD format which sorts in the same order as strings
+			if (dentistReq != "" && dentist.Username != dentistReq) || (branchReq != "" && v.Clinic != branchReq) ||
+				(typeReq != "" && v.Type != typeReq) || (fromReq != "" && v.Date < fromReq) || (toReq != "" && v.Date > toReq) {
+				continue
+			}
+			var startTime, endTime, typeName, branchName, chairName, series string
+			if v.Session >= 1 && v.GetLastSession() <= (**appointmentSessionList).GetSize() {
+				startTime = (**appointmentSessionList).Get(v.Session).(app.AppSession).StartTime
+				endTime = (**appointmentSessionList).Get(v.GetLastSession()).(app.AppSession).EndTime
+			}
+			if appointmentType := appointmentTypes.GetForAppointment(v); appointmentType != nil {
+				typeName = appointmentType.Name
+			}
+			if clinic := appointmentClinics.GetForAppointment(v); clinic != nil {
+				branchName = clinic.Name
+				if chair := clinic.GetChair(v.Chair); chair != nil {
+					chairName = chair.Name
+				}
+			}
+			if v.SeriesID != 0 {
+				series = strconv.Itoa(v.SeriesID)
+			}
+			var mobileNumber string
+			if patient.MobileNumber != 0 {
+				mobileNumber = strconv.Itoa(patient.MobileNumber)
+			}
+			rows = append(rows, []string{strconv.Itoa(v.ID), v.Date, strconv.Itoa(v.Session), startTime, endTime,
+				dentist.Username, dentist.FirstName + " " + dentist.LastName, patient.Username, patient.FirstName + " " + patient.LastName,
+				mobileNumber, typeName, branchName, chairName, series})
+		}
+		logger.Info.Printf("%v: [%v] appointments exported by [%v].", util.CurrFuncName(), len(rows)-1, myUser.Username)
+		writeSpreadsheet(res, req.FormValue("format"), "appointments", rows)
+	}
+}
+
+// userExportHandler handles request to export users as CSV or XLSX file, only admin has the privilege to export users.
+// Users are filtered by role, passwords are never exported.
+func userExportHandler(userList *user.DoublyLinkedList) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		roleReq := strings.TrimSpace(req.FormValue("role"))
+		rows := [][]string{{"Username", "Role", "First Name", "Last Name", "Mobile Number", "Email", "Status"}}
+		for _, v := range (*userList).GetList() {
+			u := v.(*user.User)
+			if u.Role == enumAdmin || (roleReq != "" && u.Role != roleReq) {
+				continue
+			}
+			var mobileNumber string
+			if u.MobileNumber != 0 {
+				mobileNumber = strconv.Itoa(u.MobileNumber)
+			}
+			status := "Active"
+			if u.IsDeleted {
+				status = "Deleted"
+			}
+			rows = append(rows, []string{u.Username, u.Role, u.FirstName, u.LastName, mobileNumber, u.Email, status})
+		}
+		logger.Info.Printf("%v: [%v] users exported by [%v].", util.CurrFuncName(), len(rows)-1, myUser.Username)
+		writeSpreadsheet(res, req.FormValue("format"), "users", rows)
+	}
+}
+
+// writeSpreadsheet writes rows as a CSV or XLSX (format) file download, the file is named after name and today's date.
+func writeSpreadsheet(res http.ResponseWriter, format, name string, rows [][]string) {
+	fileName := fmt.Sprintf("%v-%v", name, time.Now().Format("2006-01-02"))
+	var err error
+	if format == "xlsx" {
+		res.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName+".xlsx"))
+		err = spreadsheet.WriteXLSX(res, util.FirstCharToUpper(name), rows)
+	} else {
+		res.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName+".csv"))
+		err = spreadsheet.WriteCSV(res, rows)
+	}
+	if err != nil {
+		logger.Error.Printf("%v: %v", util.CurrFuncName(), err)
+	}
+}
+
+// writeJSON writes data as JSON response with a HTTP status code.
+func writeJSON(res http.ResponseWriter, status int, data interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	if err := json.NewEncoder(res).Encode(data); err != nil {
+		logger.Error.Println(err)
+	}
+}
+
+// formatPercent formats a rate between 0 and 1 as percentage with one decimal place.
+func formatPercent(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
+// getReportRange reads the date range of a report, the last 30 days up to today (now) by default.
+// Returns false when dates are not valid, to is before from or the range is longer than app.MaxReportDays.
+func getReportRange(req *http.Request, now time.Time) (time.Time, time.Time, bool) {
+	today, _ := time.Parse("2006-01-02", now.Format("2006-01-02"))
+	from, to := today.AddDate(0, 0, -29), today
+	var err error
+	if fromReq := strings.TrimSpace(req.FormValue("from")); fromReq != "" {
+		if from, err = time.Parse("2006-01-02", fromReq); err != nil {
+			return from, to, false
+		}
+	}
+	if toReq := strings.TrimSpace(req.FormValue("to")); toReq != "" {
+		if to, err = time.Parse("2006-01-02", toReq); err != nil {
+			return from, to, false
+		}
+	}
+	if to.Before(from) || to.Sub(from) >= app.MaxReportDays*24*time.Hour {
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// reportsHandler handles request to view utilization, cancellation, no-show and new patient reports,
+// only admin has the privilege to view reports.
+func reportsHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, cancellationLog *app.CancellationLog) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		ViewData := struct {
+			page
+			InputFrom    string
+			InputTo      string
+			MaxDays      int
+			IsInputError bool
+			Report       *app.Report
+		}{
+			newPage(req, "Reports", "RPT"),
+			strings.TrimSpace(req.FormValue("from")),
+			strings.TrimSpace(req.FormValue("to")),
+			app.MaxReportDays,
+			false,
+			nil,
+		}
+
+		from, to, ok := getReportRange(req, time.Now())
+		if !ok {
+			ViewData.IsInputError = true
+		} else {
+			ViewData.InputFrom, ViewData.InputTo = from.Format("2006-01-02"), to.Format("2006-01-02")
+			ViewData.Report = app.GetReport(appointmentSessionList, appointmentTree, appointmentRota, clinicCalendar, cancellationLog, (*userList).GetDentistList(), from, to, time.Now())
+		}
+		if err := getTemplates(req).ExecuteTemplate(res, "reports.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// reportsAPIHandler handles request to get the report of a date range as JSON, only admin has the privilege to view reports.
+func reportsAPIHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentRota *app.Rota, clinicCalendar *app.Calendar, cancellationLog *app.CancellationLog) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			writeJSON(res, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		from, to, ok := getReportRange(req, time.Now())
+		if !ok {
+			writeJSON(res, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid date range, dates must be YYYY-MM-DD and at most %v days apart", app.MaxReportDays)})
+			return
+		}
+		writeJSON(res, http.StatusOK, app.GetReport(appointmentSessionList, appointmentTree, appointmentRota, clinicCalendar, cancellationLog, (*userList).GetDentistList(), from, to, time.Now()))
+	}
+}
+
+// appointmentNoShowHandler handles request to mark a past appointment as not attended by the patient,
+// only admin has the privilege to mark no-shows.
+func appointmentNoShowHandler(appointmentTree *app.BinarySearchTree) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+		if req.Method != http.MethodPost {
+			http.Redirect(res, req, "/appointments", http.StatusSeeOther)
+			return
+		}
+
+		vars := mux.Vars(req)
+		appointmentID, _ := strconv.Atoi(vars["id"])
+		appointment := (*appointmentTree).GetAppointmentByID(appointmentID)
+		if appointment == nil {
+			logger.Error.Printf("%v: Application does not exist ID:[%v]", util.CurrFuncName(), appointmentID)
+			http.Redirect(res, req, "/appointments", http.StatusSeeOther)
+			return
+		}
+		// Only appointments which have started can be missed
+		if appointment.Date > time.Now().Format("2006-01-02") {
+			http.Redirect(res, req, "/appointments", http.StatusSeeOther)
+			return
+		}
+		noShow := req.FormValue("noShow") == "true"
+		previous := appointment.GetJSONData()
+		(*appointmentTree).SetNoShow(appointment, noShow)
+		recordAudit(req, myUser.Username, "appointment.noshow", strconv.Itoa(appointmentID), previous, appointment.GetJSONData())
+		logger.Info.Printf("%v: Appointment [%v] no-show set to [%v] by [%v].", util.CurrFuncName(), appointmentID, noShow, myUser.Username)
+		http.Redirect(res, req, "/appointments", http.StatusSeeOther)
+	}
+}
+
+// getDailyAppointments returns the appointments of a dentist on a date (YYYY-MM-DD) in session order,
+// appointments of all dentists are returned when dentist is nil.
+func getDailyAppointments(appointmentTree *app.BinarySearchTree, date string, dentist *user.User) []*app.Appointment {
+	var list []*app.Appointment
+	for _, v := range (*appointmentTree).GetAppointmentsInRange(date, date) {
+		if dentist == nil || v.Dentist.(*user.User).Username == dentist.Username {
+			list = append(list, v)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Session < list[j].Session
+	})
+	return list
+}
+
+// getAppointmentLocation returns the branch and chair of an appointment.
+func getAppointmentLocation(appointmentClinics app.Clinics, appointment *app.Appointment) string {
+	clinic := appointmentClinics.GetForAppointment(appointment)
+	if clinic == nil {
+		return ""
+	}
+	if chair := clinic.GetChair(appointment.Chair); chair != nil {
+		return fmt.Sprintf("%v (%v)", clinic.Name, chair.Name)
+	}
+	return clinic.Name
+}
+
+// getAppointmentTypeName returns the name of the type of an appointment.
+func getAppointmentTypeName(appointmentTypes app.AppointmentTypes, appointment *app.Appointment) string {
+	if appointmentType := appointmentTypes.GetForAppointment(appointment); appointmentType != nil {
+		return appointmentType.Name
+	}
+	return "Standard"
+}
+
+// getRunSheet returns the run-sheet of a dentist's appointments on a date.
+func getRunSheet(appointmentSessionList **dll.DoublyLinkedList, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, dentist *user.User, date time.Time, appointments []*app.Appointment) document.RunSheet {
+	sheet := document.RunSheet{
+		Clinic:  clinicName,
+		Dentist: dentist.FirstName + " " + dentist.LastName,
+		Date:    date,
+	}
+	for _, v := range appointments {
+		patient := v.Patient.(*user.User)
+		row := document.RunSheetRow{
+			Time:     getAppointmentTime(appointmentSessionList, v),
+			Patient:  patient.FirstName + " " + patient.LastName,
+			Type:     getAppointmentTypeName(appointmentTypes, v),
+			Location: getAppointmentLocation(appointmentClinics, v),
+		}
+		if patient.MobileNumber != 0 {
+			row.MobileNumber = strconv.Itoa(patient.MobileNumber)
+		}
+		var notes []string
+		if v.SeriesID != 0 {
+			notes = append(notes, "Recurring")
+		}
+		if v.NoShow {
+			notes = append(notes, "No-show")
+		}
+		row.Notes = strings.Join(notes, ", ")
+		sheet.Rows = append(sheet.Rows, row)
+	}
+	return sheet
+}
+
+// getLetter returns the confirmation letter of an appointment addressed to the patient.
+func getLetter(appointmentSessionList **dll.DoublyLinkedList, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes, appointment *app.Appointment, now time.Time) document.Letter {
+	patient := appointment.Patient.(*user.User)
+	dentist := appointment.Dentist.(*user.User)
+	letter := document.Letter{
+		Clinic:    clinicName,
+		Date:      now,
+		Recipient: patient.FirstName + " " + patient.LastName,
+		Reference: strconv.Itoa(appointment.ID),
+		Body:      "We are pleased to confirm your dental appointment with the following details.",
+		Details: []document.Detail{
+			{Label: "Date", Value: util.FormatDate(appointment.Date) + " (" + util.GetDay(appointment.Date) + ")"},
+			{Label: "Time", Value: getAppointmentTime(appointmentSessionList, appointment)},
+			{Label: "Dentist", Value: "Dr. " + dentist.FirstName + " " + dentist.LastName},
+			{Label: "Appointment Type", Value: getAppointmentTypeName(appointmentTypes, appointment)},
+		},
+		Closing: "Please arrive 10 minutes before your appointment. If you are unable to attend, please change or cancel your appointment online at least one day in advance.",
+	}
+	if clinic := appointmentClinics.GetForAppointment(appointment); clinic != nil {
+		letter.Address = clinic.Address
+		letter.Details = append(letter.Details, document.Detail{Label: "Location", Value: getAppointmentLocation(appointmentClinics, appointment)})
+	}
+	return letter
+}
+
+// writePDF sets the headers of a PDF file named name displayed by the browser.
+func writePDF(res http.ResponseWriter, name string) {
+	res.Header().Set("Content-Type", "application/pdf")
+	res.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", name))
+}
+
+// runSheetHandler handles request to print the daily run-sheet of dentists as PDF,
+// admin is able to print any or all dentists' run-sheets, dentists are only able to print their own.
+func runSheetHandler(userList *user.DoublyLinkedList, appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(req.FormValue("date")))
+		if err != nil {
+			date, _ = time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+		}
+		formattedDate := date.Format("2006-01-02")
+
+		// Dentists only print their own run-sheet, admin prints all dentists with appointments unless a dentist is selected
+		var dentists []*user.User
+		if myUser.Role == enumDentist {
+			dentists = []*user.User{myUser}
+		} else if dentistReq := strings.TrimSpace(req.FormValue("dentist")); dentistReq != "" {
+			dentist := (*userList).FindByUsername(dentistReq)
+			if dentist == nil || dentist.Role != enumDentist {
+				http.Error(res, "Dentist not found", http.StatusNotFound)
+				return
+			}
+			dentists = []*user.User{dentist}
+		} else {
+			for _, v := range (*userList).GetDentistList() {
+				if len(getDailyAppointments(appointmentTree, formattedDate, v)) > 0 {
+					dentists = append(dentists, v)
+				}
+			}
+		}
+
+		var sheets []document.RunSheet
+		for _, v := range dentists {
+			sheets = append(sheets, getRunSheet(appointmentSessionList, appointmentClinics, appointmentTypes, v, date, getDailyAppointments(appointmentTree, formattedDate, v)))
+		}
+		writePDF(res, fmt.Sprintf("run-sheet-%v.pdf", formattedDate))
+		if err = document.WriteRunSheets(res, sheets, time.Now()); err != nil {
+			logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+		}
+	}
+}
+
+// appointmentLetterHandler handles request to print the confirmation letter of an appointment as PDF,
+// patients and dentists are only able to print letters of their own appointments.
+func appointmentLetterHandler(appointmentSessionList **dll.DoublyLinkedList, appointmentTree *app.BinarySearchTree, appointmentClinics app.Clinics, appointmentTypes app.AppointmentTypes) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		appointmentID, _ := strconv.Atoi(mux.Vars(req)["id"])
+		appointment := (*appointmentTree).GetAppointmentByID(appointmentID)
+		if appointment == nil || (myUser.Role == enumPatient && appointment.Patient.(*user.User).Username != myUser.Username) ||
+			(myUser.Role == enumDentist && appointment.Dentist.(*user.User).Username != myUser.Username) {
+			logger.Error.Printf("%v: Appointment not found for [%v] ID:[%v]", util.CurrFuncName(), myUser.Username, appointmentID)
+			http.Error(res, "Appointment not found", http.StatusNotFound)
+			return
+		}
+		letter := getLetter(appointmentSessionList, appointmentClinics, appointmentTypes, appointment, time.Now())
+		writePDF(res, fmt.Sprintf("appointment-%d.pdf", appointment.ID))
+		if err := document.WriteLetters(res, []document.Letter{letter}, time.Now()); err != nil {
+			logger.Error.Printf("%v: Error: %v", util.CurrFuncName(), err)
+		}
+	}
+}
+
+// recordAudit appends an action performed by actor on target into the audit log with the fields changed
+// between before and after, either may be nil when a record is created or deleted. Passwords are never stored.
+func recordAudit(req *http.Request, actor, action, target string, before, after interface{}) {
+	if auditLog == nil {
+		return
+	}
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	entry := audit.Entry{Actor: actor, Action: action, Target: target, Changes: audit.Diff(before, after, "password"), IP: ip}
+	if _, err = auditLog.Append(entry); err != nil {
+		logger.Error.Printf("%v: Error writing audit log: %v", util.CurrFuncName(), err)
+	}
+}
+
+// auditHandler handles request to search the audit log, only admin has the privilege to view the audit log.
+// Entries are filtered by actor, action, target, a search query and date range (YYYY-MM-DD),
+// matching entries are exported as CSV or XLSX file when format is given.
+func auditHandler() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		ViewData := struct {
+			page
+			Actions      []string
+			InputActor   string
+			InputAction  string
+			InputTarget  string
+			InputQuery   string
+			InputFrom    string
+			InputTo      string
+			IsInputError bool
+			Entries      []*audit.Entry
+			Total        int
+			Verified     int
+			ChainError   string
+		}{
+			newPage(req, "Audit Log", "AUD"),
+			nil,
+			strings.TrimSpace(req.FormValue("actor")),
+			strings.TrimSpace(req.FormValue("action")),
+			strings.TrimSpace(req.FormValue("target")),
+			strings.TrimSpace(req.FormValue("q")),
+			strings.TrimSpace(req.FormValue("from")),
+			strings.TrimSpace(req.FormValue("to")),
+			false,
+			nil,
+			0,
+			0,
+			"",
+		}
+
+		if auditLog == nil {
+			ViewData.ChainError = "Audit log is not available."
+			if err := getTemplates(req).ExecuteTemplate(res, "audit.gohtml", ViewData); err != nil {
+				logger.Error.Println(err)
+			}
+			return
+		}
+
+		filter := audit.Filter{Actor: ViewData.InputActor, Action: ViewData.InputAction, Target: ViewData.InputTarget, Query: ViewData.InputQuery}
+		var err error
+		if ViewData.InputFrom != "" {
+			if filter.From, err = time.Parse("2006-01-02", ViewData.InputFrom); err != nil {
+				ViewData.IsInputError = true
+			}
+		}
+		if ViewData.InputTo != "" {
+			// Entries on the last day are included
+			if filter.To, err = time.Parse("2006-01-02", ViewData.InputTo); err != nil {
+				ViewData.IsInputError = true
+			}
+			filter.To = filter.To.AddDate(0, 0, 1)
+		}
+		if !ViewData.IsInputError {
+			ViewData.Entries = auditLog.Search(filter)
+		}
+
+		if format := req.FormValue("format"); format != "" && !ViewData.IsInputError {
+			rows := [][]string{{"Seq", "Time", "Actor", "Action", "Target", "Field", "Before", "After", "IP", "Hash"}}
+			for _, v := range ViewData.Entries {
+				changes := v.Changes
+				if len(changes) == 0 {
+					changes = []audit.Change{{}}
+				}
+				for _, c := range changes {
+					rows = append(rows, []string{strconv.Itoa(v.Seq), v.Time.Format(time.RFC3339), v.Actor, v.Action, v.Target, c.Field, c.Before, c.After, v.IP, v.Hash})
+				}
+			}
+			logger.Info.Printf("%v: [%v] audit log entries exported by [%v].", util.CurrFuncName(), len(ViewData.Entries), myUser.Username)
+			writeSpreadsheet(res, format, "audit", rows)
+			return
+		}
+
+		ViewData.Actions = auditLog.GetActions()
+		ViewData.Total = len(ViewData.Entries)
+		if len(ViewData.Entries) > auditPageSize {
+			ViewData.Entries = ViewData.Entries[:auditPageSize]
+		}
+		if ViewData.Verified, err = auditLog.Verify(); err != nil {
+			logger.Error.Printf("%v: %v", util.CurrFuncName(), err)
+			ViewData.ChainError = err.Error()
+		}
+		if err = getTemplates(req).ExecuteTemplate(res, "audit.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// integrityHandler handles request to view the integrity of monitored data, template and configuration files,
+// only admin has the privilege to view the files. Admin is able to accept the current content of tampered files
+// after reviewing the changes, which resumes serving requests refused on tampering.
+func integrityHandler() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		myUser := getSessionUser(req)
+
+		ViewData := struct {
+			page
+			Mode       string
+			Files      []integrity.FileStatus
+			IsTampered bool
+		}{
+			newPage(req, "File Integrity", "INT"),
+			getIntegrityMode(),
+			nil,
+			false,
+		}
+
+		// Process form submission
+		if req.Method == http.MethodPost && integrityMonitor != nil {
+			path := req.FormValue("accept")
+			for _, v := range integrityMonitor.GetStatus() {
+				if v.Path != path || !v.Tampered {
+					continue
+				}
+				if err := integrityMonitor.Accept(path); err != nil {
+					logger.Error.Printf("%v: Error accepting [%v]: %v", util.CurrFuncName(), path, err)
+				} else {
+					logger.Warning.Printf("%v: Content of tampered file [%v] accepted by [%v].", util.CurrFuncName(), path, myUser.Username)
+					recordAudit(req, myUser.Username, "integrity.accept", path, map[string]string{"sha256": v.Expected}, map[string]string{"sha256": v.Actual})
+				}
+			}
+			http.Redirect(res, req, "/integrity", http.StatusSeeOther)
+			return
+		}
+
+		if integrityMonitor != nil {
+			integrityMonitor.Check()
+			ViewData.Files = integrityMonitor.GetStatus()
+			ViewData.IsTampered = integrityMonitor.IsTampered()
+		}
+		if err := getTemplates(req).ExecuteTemplate(res, "integrity.gohtml", ViewData); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+}
+
+// healthCheck struct stores the result of a health check, Error is empty when the check passed.
+type healthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// getHealthChecks checks the data directories are writable, the encryption key decrypts user data, templates
+// are loaded and requests are not refused on tampering. Returns the result of every check and whether all passed.
+func getHealthChecks() (map[string]healthCheck, bool) {
+	checks := make(map[string]healthCheck)
+	healthy := true
+	check := func(name, reason string) {
+		if reason == "" {
+			checks[name] = healthCheck{Status: "ok"}
+			return
+		}
+		checks[name] = healthCheck{Status: "error", Error: reason}
+		healthy = false
+	}
+
+	// User data is decrypted into the plain file while being written
+	data := config.Get().Data
+	storage, key := "", ""
+	if _, err := os.Stat(data.EncryptedUsers); err == nil {
+		if err = cryptography.CheckKey(config.Get().Key, data.EncryptedUsers); err != nil {
+			logger.Error.Printf("%v: Error decrypting user data: %v", util.CurrFuncName(), err)
+			key = "encryption key does not decrypt user data"
+		}
+	} else if _, err = os.Stat(data.Users); err != nil {
+		storage = "user data file is missing"
+	}
+	dirs := make(map[string]bool)
+	for _, path := range []string{data.EncryptedUsers, data.Appointments, data.Holidays, data.Availability, data.Waitlist,
+		data.Reminders, data.CalendarFeeds, data.Cancellations, data.NotificationPreferences, data.AuditLog} {
+		if path != "" {
+			dirs[filepath.Dir(path)] = true
+		}
+	}
+	for dir := range dirs {
+		file, err := os.CreateTemp(dir, ".healthz-*")
+		if err != nil {
+			logger.Error.Printf("%v: Error writing data directory: %v", util.CurrFuncName(), err)
+			storage = "data directory is not writable"
+			continue
+		}
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}
+	check("storage", storage)
+	check("encryptionKey", key)
+
+	templates := ""
+	if set := getTemplateSet(); set == nil {
+		templates = "templates are not loaded"
+	} else {
+		for _, locale := range i18n.Locales {
+			for _, name := range []string{"header", "footer", "index.gohtml", "login.gohtml"} {
+				if set[locale] == nil || set[locale].Lookup(name) == nil {
+					templates = fmt.Sprintf("template %v is not defined in %v", name, locale)
+				}
+			}
+		}
+	}
+	check("templates", templates)
+
+	tampered := ""
+	if integrityMonitor != nil && integrityMonitor.IsTampered() && getIntegrityMode() == integrity.ModeRefuse {
+		tampered = "requests are refused as monitored files are tampered"
+	}
+	check("integrity", tampered)
+	return checks, healthy
+}
+
+// healthHandler handles health check requests of the load balancer, authentication is not required.
+// Liveness (/healthz) responds 200 OK with the result of all checks while the server is running,
+// readiness (/readyz) responds 503 Service Unavailable when any check failed.
+func healthHandler(readiness bool) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		checks, healthy := getHealthChecks()
+		status, code := "ok", http.StatusOK
+		if !healthy {
+			status = "error"
+			if readiness {
+				code = http.StatusServiceUnavailable
+			}
+		}
+		res.Header().Set("Cache-Control", "no-store")
+		writeJSON(res, code, map[string]interface{}{"status": status, "checks": checks})
+	}
+}
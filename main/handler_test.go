@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shiweii/config"
+)
+
+// loadTestConfig loads a configuration with the required settings and data files in dir.
+func loadTestConfig(t *testing.T, dir string) {
+	for _, name := range []string{"cert.pem", "key.pem", "users.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("[]"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env := strings.Join([]string{
+		"PORT=:5221",
+		"SSL_CERT=" + filepath.Join(dir, "cert.pem"),
+		"SSL_KEY=" + filepath.Join(dir, "key.pem"),
+		"KEY=0123456789abcdef0123456789abcdef",
+		"COOKIE_NAME=test",
+		"USER_DATA=" + filepath.Join(dir, "users.json"),
+		"USER_DATA_ENCRYPT=" + filepath.Join(dir, "users.enc"),
+		"APPOINTMENT_DATA=" + filepath.Join(dir, "appointments.json"),
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(env), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Load(filepath.Join(dir, ".env")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHealthHandler checks readiness fails when any check fails while liveness still responds 200 OK.
+func TestHealthHandler(t *testing.T) {
+	dir := t.TempDir()
+	loadTestConfig(t, dir)
+	setTestTemplates(t)
+
+	tests := []struct {
+		name      string
+		setup     func()
+		readiness bool
+		code      int
+		status    string
+	}{
+		{"liveness", func() {}, false, http.StatusOK, "ok"},
+		{"readiness", func() {}, true, http.StatusOK, "ok"},
+		{"liveness with user data missing", func() { os.Remove(filepath.Join(dir, "users.json")) }, false, http.StatusOK, "error"},
+		{"readiness with user data missing", func() {}, true, http.StatusServiceUnavailable, "error"},
+	}
+	for _, tt := range tests {
+		tt.setup()
+		res := httptest.NewRecorder()
+		healthHandler(tt.readiness)(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var body struct {
+			Status string                 `json:"status"`
+			Checks map[string]healthCheck `json:"checks"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if res.Code != tt.code || body.Status != tt.status {
+			t.Errorf("%v: got %d %v, want %d %v", tt.name, res.Code, body.Status, tt.code, tt.status)
+		}
+		if tt.status == "error" && body.Checks["storage"].Status != "error" {
+			t.Errorf("%v: got storage check %+v, want error", tt.name, body.Checks["storage"])
+		}
+		if got := res.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("%v: got Cache-Control %q, want no-store", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/shiweii/i18n"
+)
+
+// getLocale returns the locale of a request, the locale chosen by the logged-in user is preferred
+// over the languages accepted by the browser.
+func getLocale(req *http.Request) string {
+	var preference string
+	if myUser := getSessionUser(req); myUser != nil {
+		preference = myUser.Locale
+	}
+	return i18n.Negotiate(preference, req.Header.Get("Accept-Language"))
+}
+
+// translate translates a message into the locale of a request.
+func translate(req *http.Request, message string, args ...interface{}) string {
+	return i18n.T(getLocale(req), message, args...)
+}
+
+// getLocaleFuncs returns the functions of templates which translate messages and format dates in locale.
+func getLocaleFuncs(locale string) template.FuncMap {
+	return template.FuncMap{
+		"locale": func() string {
+			return locale
+		},
+		"t": func(message string, args ...interface{}) string {
+			return i18n.T(locale, message, args...)
+		},
+		"formatDate": func(date string) string {
+			return i18n.FormatDate(locale, date)
+		},
+		"getDay": func(date string) string {
+			return i18n.GetDay(locale, date)
+		},
+		"weekday": func(day time.Weekday) string {
+			return i18n.Weekday(locale, day)
+		},
+		"formatDateTime": func(t time.Time) string {
+			return i18n.FormatDateTime(locale, t)
+		},
+		"formatTimestamp": func(t time.Time) string {
+			return i18n.FormatTimestamp(locale, t)
+		},
+	}
+}
+
+// localeOption stores a supported locale and its name displayed to choose the locale of pages.
+type localeOption struct {
+	Code string
+	Name string
+}
+
+// getLocaleOptions returns all supported locales displayed to choose the locale of pages.
+func getLocaleOptions() []localeOption {
+	options := make([]localeOption, len(i18n.Locales))
+	for i, v := range i18n.Locales {
+		options[i] = localeOption{v, i18n.Name(v)}
+	}
+	return options
+}
@@ -1,59 +1,83 @@
 package main
 
 import (
+	"context"
 	"html/template"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	app "github.com/shiweii/appointment"
+	"github.com/shiweii/audit"
 	bst "github.com/shiweii/binarysearchtree"
+	"github.com/shiweii/config"
 	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/integrity"
 	"github.com/shiweii/logger"
+	"github.com/shiweii/metrics"
+	"github.com/shiweii/notification"
 	"github.com/shiweii/user"
 	util "github.com/shiweii/utility"
 )
 
 // initialize of variables
 var (
-	tpl         *template.Template
-	mapSessions = map[string]string{}
-	fm          = template.FuncMap{
+	tpl              templateSet
+	auditLog         *audit.Log
+	integrityMonitor *integrity.Monitor
+	mapSessions      = newSessionMap()
+	backgroundTasks  taskGroup
+	fm               = template.FuncMap{
 		"addOne":           util.AddOne,
-		"getDay":           util.GetDay,
-		"formatDate":       util.FormatDate,
 		"firstCharToUpper": util.FirstCharToUpper,
+		"locales":          getLocaleOptions,
+		"percent":          formatPercent,
+		"asset":            getAssetURL,
 	}
 )
 
-func init() {
+// setup loads the configuration and templates before the server is started.
+func setup() {
 	defer func() {
 		if err := recover(); err != nil {
 			logger.Panic.Println(err)
 		}
 	}()
 
-	// Go routine to verify checksum every 10 minutes
-	go util.VerifyCheckSum()
+	// Configuration is loaded once, the server is not started with invalid settings
+	if err := config.Load(".env"); err != nil {
+		logger.Fatal.Fatalln(err)
+	}
+	configureLogger()
 
-	tpl = template.Must(template.New("").Funcs(fm).ParseGlob("templates/*"))
+	templates, err := parseTemplates(getWebFiles())
+	if err != nil {
+		logger.Fatal.Fatalln(err)
+	}
+	tpl = templates
 
 	// Go Routine to perform encryption if file was left decrypted due to panic
 	util.CheckEncryption()
 }
 
 func main() {
+	setup()
 	logger.Info.Println("[Server Start]")
 
-	// Channel to detect ctrl-c and exit the server gracefully
-	sigchld := make(chan os.Signal, 1)
-	signal.Notify(sigchld, os.Interrupt)
+	// Channel to detect SIGHUP and reload the configuration
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
 	go func() {
-		<-sigchld
-		logger.Info.Println("[Server Stop]")
-		logger.CloseLogger()
-		os.Exit(0)
+		for range sighup {
+			if err := config.Reload(); err != nil {
+				logger.Error.Printf("Configuration not reloaded: %v", err)
+			}
+		}
 	}()
 
 	// Initialize new doubly linked-list and binary search tree
@@ -72,19 +96,123 @@ func main() {
 	appointmentSessionList.Add(app.AppSession{Num: 6, StartTime: "15:00", EndTime: "16:00", Available: true})
 	appointmentSessionList.Add(app.AppSession{Num: 7, StartTime: "16:00", EndTime: "17:00", Available: true})
 
+	// Data, template and configuration files are checked before data is loaded to detect changes made while the server was stopped
+	integrityMonitor = newIntegrityMonitor()
+	tampered := integrityMonitor.Check()
+
 	users := user.GetEncryptedUserData()
 	for _, userObj := range users {
 		userList.Add(userObj)
 	}
 	userList.InsertionSort()
 
+	appointmentRota := app.GetRotaData()
+	clinicCalendar := app.GetHolidayData()
+	rulesEngine := app.NewRulesEngine(app.GetBookingRules())
+	waitlist := app.GetWaitlistData()
+	appointmentTypes := app.GetAppointmentTypeData()
+	appointmentClinics := app.GetClinicData()
+	calendarFeeds := app.GetCalendarFeedData()
+	cancellationLog := app.GetCancellationData()
+	rulesEngine.AddRule(app.NewWaitlistHoldRule(waitlist))
+	config.OnReload(func(old, new *config.Config) {
+		rulesEngine.SetConfig(app.GetBookingRules())
+	})
+
+	// Messages are delivered through all configured channels the recipient has not muted
+	notifier := notification.NewDispatcher(getNotificationChannels(), notification.LoadPreferences(config.Get().Data.NotificationPreferences))
+	messageTemplates, err := notification.ParseTemplates(config.Get().Data.MessageTemplates)
+	if err != nil {
+		logger.Error.Println(err)
+	}
+
+	// Entries of the audit log are verified on every start to report tampering
+	auditLog, err = audit.LoadLog(config.Get().Data.AuditLog, []byte(config.Get().Log.HMACKey))
+	if err != nil {
+		logger.Error.Println(err)
+	} else if _, err = auditLog.Verify(); err != nil {
+		logger.Error.Println(err)
+	}
+
 	appointments := app.GetAppointmentData()
 	for _, v := range appointments {
 		appointment := app.New(v.ID, userList.FindByUsername(v.Patient.(string)), userList.FindByUsername(v.Dentist.(string)), v.Date, v.Session)
+		appointment.SeriesID = v.SeriesID
+		appointment.Type = v.Type
+		appointment.Duration = v.Duration
+		appointment.Clinic = v.Clinic
+		appointment.Chair = v.Chair
+		appointment.NoShow = v.NoShow
 		appointmentTree.Add(v.Date, appointment)
 	}
 
+	// Go routines of notifications, reminders and waitlist offers are stopped and waited for on shutdown
+	tasksCtx, stopTasks := context.WithCancel(context.Background())
+	runTask := func(task func()) {
+		if !backgroundTasks.Go(task) {
+			logger.Warning.Printf("%v: Background task not started, server is stopping.", util.CurrFuncName())
+		}
+	}
+
+	// Go routine to release expired waitlist holds and offer slots to the next patient
+	runTask(func() {
+		processWaitlistOffers(tasksCtx, &appointmentSessionList, &appointmentTree, &userList, waitlist, notifier)
+	})
+
+	// Go routine to verify log files every LOG_VERIFY_INTERVAL (10 minutes by default) and alert admins of tampering
+	if key := config.Get().Log.HMACKey; key != "" {
+		runTask(func() {
+			logger.Monitor(config.Get().Log.Dir, []byte(key), config.Get().Log.VerifyInterval, getLogAlertSink(&userList, notifier), tasksCtx.Done())
+		})
+	} else {
+		logger.Warning.Println("LOG_HMAC_KEY is not declared, log files and the audit log are not protected from tampering.")
+	}
+
+	// Go routine to check monitored files on change and every INTEGRITY_SCAN_INTERVAL (5 minutes by default) and alert admins of tampering
+	integrityAlert := getIntegrityAlert(&userList, notifier)
+	integrityMonitor.OnTamper(integrityAlert)
+	for _, v := range tampered {
+		integrityAlert(v)
+	}
+	runTask(func() {
+		integrityMonitor.Watch(config.Get().Integrity.ScanInterval, tasksCtx.Done())
+	})
+
+	// Go routine to send reminders of upcoming appointments
+	reminderLog := app.GetReminderData()
+	runTask(func() {
+		processReminders(tasksCtx, &appointmentSessionList, &appointmentTree, appointmentClinics, appointmentTypes, reminderLog, notifier, messageTemplates)
+	})
+
+	// Notify patients and dentists of booked, rescheduled and cancelled appointments
+	app.Subscribe(func(event app.Event) {
+		runTask(func() {
+			notifyAppointmentEvent(&appointmentSessionList, appointmentClinics, appointmentTypes, messageTemplates, notifier, event)
+		})
+	})
+	// Keep calendar feeds up to date with changed and cancelled appointments
+	app.Subscribe(calendarFeeds.Record)
+	// Keep cancelled appointments for reports
+	app.Subscribe(cancellationLog.Record)
+
+	// Active sessions are counted from the session map on every scrape of /metrics
+	metrics.RegisterSessions(mapSessions.Len)
+	if config.Get().AssetSource == "local" && !hasVendoredAssets {
+		logger.Warning.Println("ASSET_SOURCE is local but Bootstrap files were not downloaded by static/vendor/fetch.sh, files are loaded from the CDN.")
+	}
+	if config.Get().MetricsToken == "" {
+		logger.Warning.Println("METRICS_TOKEN is not declared, /metrics is public.")
+	}
+
+	// Every request is logged, measured and recovered from panics, handlers wrapped by requireUser are only called
+	// for logged-in users of the given roles (loggedIn allows every role) with the user stored in the request context
+	middleware := getMiddleware()
+	loggedIn := requireUser(&userList)
+	adminOnly := requireUser(&userList, enumAdmin)
+
 	router := mux.NewRouter()
+	router.Use(middleware...)
+	router.NotFoundHandler = chain(errorHandler(http.StatusNotFound), middleware...)
 
 	// Handler functions
 	router.HandleFunc("/", indexHandler(&userList))
@@ -94,24 +222,223 @@ func main() {
 	router.Handle("/favicon.ico", http.NotFoundHandler())
 
 	// Appointment
-	router.HandleFunc("/appointments", appointmentListHandler(&userList, &appointmentSessionList, &appointmentTree))
-	router.HandleFunc("/appointments/search", appointmentSearchHandler(&userList, &appointmentSessionList, &appointmentTree))
-	router.HandleFunc("/appointment/create", appointmentCreateHandler(&userList))
-	router.HandleFunc("/appointment/create/{dentist}", appointmentCreatePart2Handler(&userList, &appointmentSessionList, &appointmentTree))
-	router.HandleFunc(`/appointment/create/{dentist}/{date:\d{4}-\d{2}-\d{2}}/{session:[1-7]+}`, appointmentCreateConfirmHandler(&userList, &appointmentSessionList, &appointmentTree))
-	router.HandleFunc("/appointment/edit/{id:[0-9]+}", appointmentEditHandler(&userList, &appointmentSessionList, &appointmentTree))
-	router.HandleFunc(`/appointment/edit/{id:[0-9]+}/{dentist}/{date:\d{4}-\d{2}-\d{2}}/{session:[1-7]+}`, appointmentEditConfirmHandler(&userList, &appointmentSessionList, &appointmentTree))
-	router.HandleFunc("/appointment/delete/{id:[0-9]+}", appointmentDeleteHandler(&userList, &appointmentSessionList, &appointmentTree))
+	router.Handle("/appointments", loggedIn(appointmentListHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentClinics, appointmentTypes)))
+	router.Handle("/appointments/search", loggedIn(appointmentSearchHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, appointmentClinics)))
+	router.Handle("/appointments/find", requireUser(&userList, enumPatient, enumAdmin)(appointmentFindHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentTypes, rulesEngine, waitlist)))
+	router.Handle("/api/slots", loggedIn(slotSearchAPIHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentTypes, rulesEngine, waitlist)))
+	router.Handle("/appointment/create", loggedIn(appointmentCreateHandler(&userList, appointmentClinics)))
+	router.Handle("/appointment/create/{dentist}", loggedIn(appointmentCreatePart2Handler(&userList, &appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentTypes)))
+	router.Handle(`/appointment/create/{dentist}/{date:\d{4}-\d{2}-\d{2}}/{session:[1-7]+}`, loggedIn(appointmentCreateConfirmHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentTypes, rulesEngine, waitlist)))
+	router.Handle("/appointment/edit/{id:[0-9]+}", loggedIn(appointmentEditHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentTypes)))
+	router.Handle(`/appointment/edit/{id:[0-9]+}/{dentist}/{date:\d{4}-\d{2}-\d{2}}/{session:[1-7]+}`, loggedIn(appointmentEditConfirmHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentTypes, rulesEngine, waitlist, notifier)))
+	router.Handle("/appointments/runsheet", requireUser(&userList, enumAdmin, enumDentist)(runSheetHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentClinics, appointmentTypes)))
+	router.Handle("/appointment/letter/{id:[0-9]+}", loggedIn(appointmentLetterHandler(&appointmentSessionList, &appointmentTree, appointmentClinics, appointmentTypes)))
+	router.Handle("/appointment/noshow/{id:[0-9]+}", adminOnly(appointmentNoShowHandler(&appointmentTree)))
+	router.Handle("/appointment/delete/{id:[0-9]+}", loggedIn(appointmentDeleteHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentTypes, rulesEngine, waitlist, notifier)))
+	router.Handle("/waitlist", requireUser(&userList, enumPatient, enumAdmin)(waitlistHandler(&userList, &appointmentSessionList, waitlist)))
+
+	// Calendar
+	router.Handle("/appointment/calendar/{id:[0-9]+}", loggedIn(appointmentCalendarHandler(&appointmentSessionList, &appointmentTree, appointmentClinics, appointmentTypes, calendarFeeds)))
+	router.Handle("/calendar", requireUser(&userList, enumPatient, enumDentist)(calendarHandler(calendarFeeds)))
+	router.HandleFunc("/calendar/{token:[0-9a-f]+}.ics", calendarFeedHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentClinics, appointmentTypes, calendarFeeds))
+
+	// Clinic closure
+	router.Handle("/holidays", adminOnly(holidayListHandler(&appointmentTree, clinicCalendar)))
+	router.Handle(`/holidays/{date:\d{4}-\d{2}-\d{2}}`, adminOnly(holidayAppointmentsHandler(&appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, appointmentClinics)))
+
+	// Dentist availability
+	router.Handle("/availability/{dentist}", loggedIn(availabilityHandler(&userList, &appointmentSessionList, appointmentRota)))
 
 	// User
-	router.HandleFunc("/users", userListHandler(&userList))
-	router.HandleFunc("/user/edit/{username}", userEditHandler(&userList))
-	router.HandleFunc("/user/delete/{username}", userDeleteHandler(&userList))
+	router.Handle("/users", adminOnly(userListHandler(&userList)))
+	router.Handle("/user/edit/{username}", loggedIn(userEditHandler(&userList)))
+	router.Handle("/user/delete/{username}", adminOnly(userDeleteHandler(&userList)))
+	router.Handle("/notifications", requireUser(&userList, enumPatient, enumDentist)(notificationSettingsHandler(notifier)))
+	router.Handle("/language", loggedIn(languageHandler()))
 
 	// Admin
-	router.HandleFunc("/sessions", sessionListHandler(&userList))
+	router.Handle("/sessions", adminOnly(sessionListHandler(&userList)))
+	router.Handle("/import", adminOnly(importHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, appointmentClinics, appointmentTypes)))
+	router.Handle("/appointments/export", adminOnly(appointmentExportHandler(&appointmentSessionList, &appointmentTree, appointmentClinics, appointmentTypes)))
+	router.Handle("/users/export", adminOnly(userExportHandler(&userList)))
+
+	// Reports
+	router.Handle("/reports", adminOnly(reportsHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, cancellationLog)))
+	router.Handle("/api/reports", adminOnly(reportsAPIHandler(&userList, &appointmentSessionList, &appointmentTree, appointmentRota, clinicCalendar, cancellationLog)))
+
+	// Audit log
+	router.Handle("/audit", adminOnly(auditHandler()))
+
+	// File integrity
+	router.Handle("/integrity", adminOnly(integrityHandler()))
+
+	// Health checks of the load balancer
+	router.HandleFunc("/healthz", healthHandler(false))
+	router.HandleFunc("/readyz", healthHandler(true))
+
+	// Stylesheets, scripts and vendored Bootstrap files
+	router.PathPrefix("/static/").Handler(staticHandler())
+
+	// Metrics of the Prometheus server
+	router.Handle("/metrics", metricsAuthHandler(metrics.Handler()))
+
+	server := &http.Server{Addr: config.Get().Port, Handler: router}
+
+	// Channel to detect ctrl-c and SIGTERM and shut down the server gracefully
+	stop := make(chan os.Signal, 1)
+	stopped := make(chan struct{})
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		shutdown(server, stopTasks)
+		close(stopped)
+	}()
 
-	if err := http.ListenAndServeTLS(util.GetEnvVar("PORT"), util.GetEnvVar("SSL_CERT"), util.GetEnvVar("SSL_KEY"), router); err != nil {
+	if err := server.ListenAndServeTLS(config.Get().SSLCert, config.Get().SSLKey); err != http.ErrServerClosed {
 		logger.Fatal.Fatalln("ListenAndServe: ", err)
 	}
+	<-stopped
+}
+
+// shutdown stops accepting requests and waits up to SHUTDOWN_TIMEOUT for requests in progress, background tasks are then
+// stopped and notifications in progress are waited for within the same timeout. Writes of data files in progress are
+// then waited for within the time left and user data left decrypted is encrypted before the log files are closed.
+func shutdown(server *http.Server, stopTasks context.CancelFunc) {
+	logger.Info.Println("[Server Stopping]")
+	timeout := config.Get().ShutdownTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error.Printf("Requests in progress were not completed within %v: %v", timeout, err)
+	}
+	backgroundTasks.Stop()
+	stopTasks()
+	if !backgroundTasks.Wait(ctx) {
+		logger.Error.Printf("Notifications and background tasks in progress were not completed within %v.", timeout)
+	}
+	deadline, _ := ctx.Deadline()
+	if !integrity.Wait(time.Until(deadline)) {
+		logger.Error.Printf("Writes of data files in progress were not completed within %v.", timeout)
+	}
+	util.CheckEncryption()
+	logger.Info.Println("[Server Stop]")
+	logger.CloseLogger()
+}
+
+// taskGroup runs the Go routines of background tasks which are waited for on shutdown,
+// tasks are no longer started once the group is stopped so that none is started while waiting.
+type taskGroup struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	stopped bool
+}
+
+// Go runs task in a new Go routine, returns false without running task when the group is stopped.
+func (g *taskGroup) Go(task func()) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopped {
+		return false
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		task()
+	}()
+	return true
+}
+
+// Stop stops new tasks from being started, tasks already running are not stopped.
+func (g *taskGroup) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stopped = true
+}
+
+// Wait waits until the Go routines of the group are done, returns false when ctx is done first.
+func (g *taskGroup) Wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// configureLogger sets the minimum level (LOG_LEVEL) and format (LOG_FORMAT, logfmt or json) of logs.
+// Logs are written into stderr and files in LOG_DIR which are rotated daily or when LOG_MAX_SIZE (MB) is reached,
+// files are kept for LOG_MAX_AGE days. All logs are written into the log directory without rotation by size when not declared.
+// Lines of log files are chained by HMAC with LOG_HMAC_KEY and rotated files are sealed when the key is declared.
+// Level and format are changed when the configuration is reloaded.
+func configureLogger() {
+	settings := config.Get().Log
+	level, _ := logger.ParseLevel(settings.Level)
+	file := logger.NewRotatingFile(settings.Dir, settings.MaxSize<<20, time.Duration(settings.MaxAge)*24*time.Hour)
+	if settings.HMACKey != "" {
+		file.Key = []byte(settings.HMACKey)
+	}
+	logger.Configure(logger.Config{
+		Level:   level,
+		Format:  settings.Format,
+		Writers: []io.Writer{file, os.Stderr},
+	})
+	config.OnReload(func(old, new *config.Config) {
+		level, _ := logger.ParseLevel(new.Log.Level)
+		logger.SetLevel(level)
+		logger.SetFormat(new.Log.Format)
+	})
+}
+
+// newIntegrityMonitor returns the monitor of data files, .env and CONFIG_FILE which records the writes of the
+// persistence layer, templates are embedded into the binary. Expected hashes are kept in INTEGRITY_MANIFEST signed with INTEGRITY_KEY,
+// changes made while the server is stopped are only detected when both are declared.
+func newIntegrityMonitor() *integrity.Monitor {
+	key := config.Get().Integrity.Key
+	if key == "" {
+		logger.Warning.Println("INTEGRITY_KEY is not declared, changes made while the server is stopped are not detected.")
+	}
+	monitor := integrity.New(config.Get().Integrity.Manifest, []byte(key))
+	data := config.Get().Data
+	for _, path := range []string{data.EncryptedUsers, data.Appointments, data.Holidays, data.Availability, data.Waitlist,
+		data.AppointmentTypes, data.Clinics, data.Reminders, data.CalendarFeeds, data.Cancellations,
+		data.NotificationPreferences, data.MessageTemplates, config.Get().ConfigFile} {
+		if path != "" {
+			monitor.Add(path)
+		}
+	}
+	monitor.Add(".env")
+	integrity.SetDefault(monitor)
+	return monitor
+}
+
+// getIntegrityMode returns the response to tampering of monitored files (INTEGRITY_MODE), tampering is only alerted by default.
+func getIntegrityMode() string {
+	return config.Get().Integrity.Mode
+}
+
+// getNotificationChannels returns the channels messages are sent through as configured.
+// SMS is sent through the HTTP gateway when configured, otherwise written into the SMS outbox file,
+// email is sent through the SMTP server when configured, otherwise written into the email outbox file.
+// Messages are written into the application log when no channel is configured.
+func getNotificationChannels() map[string]notification.Notifier {
+	var channels = make(map[string]notification.Notifier)
+	if url := config.Get().Notification.SMSGatewayURL; url != "" {
+		channels["sms"] = notification.NewSMSNotifier(url, config.Get().Notification.SMSAPIKey, config.Get().Notification.SMSSender)
+	} else if path := config.Get().Notification.SMSOutbox; path != "" {
+		channels["sms"] = notification.NewFileNotifier(path)
+	}
+	if host := config.Get().Notification.SMTPHost; host != "" {
+		channels["email"] = notification.NewEmailNotifier(host, config.Get().Notification.SMTPPort, config.Get().Notification.SMTPUsername, config.Get().Notification.SMTPPassword, config.Get().Notification.SMTPFrom)
+	} else if path := config.Get().Notification.EmailOutbox; path != "" {
+		channels["email"] = notification.NewFileNotifier(path)
+	}
+	if len(channels) == 0 {
+		channels["log"] = &notification.LogNotifier{}
+	}
+	return channels
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestTaskGroup checks tasks are waited for and no task is started once the group is stopped,
+// including tasks started by events of tasks still running.
+func TestTaskGroup(t *testing.T) {
+	var tasks taskGroup
+	release := make(chan struct{})
+	started := make(chan bool, 1)
+	if !tasks.Go(func() {
+		<-release
+		started <- tasks.Go(func() {})
+	}) {
+		t.Fatal("got task not started, want started")
+	}
+
+	tasks.Stop()
+	if tasks.Go(func() {}) {
+		t.Error("got task started after Stop, want not started")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if tasks.Wait(ctx) {
+		t.Error("got Wait done with a task running, want false")
+	}
+
+	close(release)
+	if <-started {
+		t.Error("got task started by a running task after Stop, want not started")
+	}
+	if !tasks.Wait(context.Background()) {
+		t.Error("got Wait not done, want true")
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shiweii/config"
+	"github.com/shiweii/integrity"
+	"github.com/shiweii/logger"
+	"github.com/shiweii/metrics"
+	"github.com/shiweii/user"
+	util "github.com/shiweii/utility"
+)
+
+// getMiddleware returns the middleware of every request in order, the first middleware is the outermost.
+// Requests are logged and measured with the status written by the recovery of panics.
+func getMiddleware() []mux.MiddlewareFunc {
+	return []mux.MiddlewareFunc{
+		requestIDHandler,
+		securityHeadersHandler,
+		accessLogHandler,
+		metricsHandler,
+		recoveryHandler,
+		integrityModeHandler,
+	}
+}
+
+// chain wraps handler with middleware, the first middleware is the outermost as in mux.Router.Use.
+func chain(handler http.Handler, middleware ...mux.MiddlewareFunc) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// statusRecorder records the status code and number of bytes written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status code before writing it.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the number of bytes written.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// requestIDHandler stores the ID of every request in the request context, records logged with the context
+// include the ID. The X-Request-ID header of the load balancer is passed through when valid, otherwise a new ID is used.
+// The ID is returned in the X-Request-ID header to find the records of a response.
+func requestIDHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.NewV4().String()
+		}
+		res.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(res, req.WithContext(logger.NewContext(req.Context(), id)))
+	})
+}
+
+// requestIDPattern matches request IDs which are passed through, other IDs could break records logged.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// securityHeadersHandler sets the headers which stop browsers from guessing content types, displaying pages in frames,
+// sending page URLs to other sites and connecting without TLS. The Content Security Policy only allows scripts,
+// styles and fonts of the server and the CDN of Bootstrap, inline scripts are only allowed with the nonce of the request.
+// No inline script is allowed when a nonce cannot be generated.
+func securityHeadersHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		sources := "'self'"
+		if !useLocalAssets() {
+			sources += " " + cdnOrigin
+		}
+		scriptSources := sources
+		nonce, err := newNonce()
+		if err != nil {
+			logger.Error.WithContext(req.Context()).Printf("%v: Inline scripts are not allowed: %v", util.CurrFuncName(), err)
+		} else {
+			scriptSources += fmt.Sprintf(" 'nonce-%v'", nonce)
+		}
+		csp := fmt.Sprintf("default-src 'self'; script-src %v; style-src %v; font-src %v; img-src 'self' data:; "+
+			"object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'", scriptSources, sources, sources)
+
+		res.Header().Set("Content-Security-Policy", csp)
+		res.Header().Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		res.Header().Set("X-Content-Type-Options", "nosniff")
+		res.Header().Set("X-Frame-Options", "DENY")
+		res.Header().Set("Referrer-Policy", "same-origin")
+		next.ServeHTTP(res, req.WithContext(context.WithValue(req.Context(), nonceKey{}, nonce)))
+	})
+}
+
+// nonceKey is the key of the nonce of inline scripts in the request context.
+type nonceKey struct{}
+
+// nonceReader is the source of random bytes of nonces.
+var nonceReader io.Reader = rand.Reader
+
+// newNonce returns a random nonce of inline scripts.
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(nonceReader, b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// getNonce returns the nonce of inline scripts of a request stored by securityHeadersHandler.
+func getNonce(req *http.Request) string {
+	nonce, _ := req.Context().Value(nonceKey{}).(string)
+	return nonce
+}
+
+// accessLogHandler logs the method, path, status code, size and duration of every request.
+func accessLogHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: res, status: http.StatusOK}
+		next.ServeHTTP(recorder, req)
+		logger.Info.WithContext(req.Context()).Log("Request completed.", "method", req.Method, "path", req.URL.Path,
+			"status", recorder.status, "bytes", recorder.bytes, "duration", time.Since(start), "remote", req.RemoteAddr)
+	})
+}
+
+// metricsHandler records the number and latency of requests by the template of the matched route,
+// so that requests of different appointments or users are counted as one route. Requests which did not match
+// any route are counted as "unmatched" and methods other than the standard methods as "other".
+func metricsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		route := "unmatched"
+		if current := mux.CurrentRoute(req); current != nil {
+			if path, err := current.GetPathTemplate(); err == nil {
+				route = path
+			}
+		}
+		method := req.Method
+		if !metricsMethods[method] {
+			method = "other"
+		}
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: res, status: http.StatusOK}
+		next.ServeHTTP(recorder, req)
+		metrics.ObserveRequest(route, method, recorder.status, time.Since(start))
+	})
+}
+
+// metricsMethods are the methods recorded by metricsHandler, any method can be sent by clients.
+var metricsMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// recoveryHandler answers requests which panic with the error page, user data left decrypted by the panic is encrypted.
+func recoveryHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				util.CheckEncryption()
+				logger.Panic.WithContext(req.Context()).Println(err)
+				renderError(res, req, http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(res, req)
+	})
+}
+
+// integrityModeHandler refuses requests while monitored files are tampered as declared by INTEGRITY_MODE,
+// requests which change data are refused in readonly mode and all requests are refused in refuse mode.
+// Admin is still able to log in and accept the tampered files at /integrity.
+func integrityModeHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if mode := getIntegrityMode(); mode != integrity.ModeAlert && integrityMonitor != nil && integrityMonitor.IsTampered() && !isIntegrityExempt(req) {
+			if mode == integrity.ModeRefuse || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+				logger.Warning.WithContext(req.Context()).Printf("%v: [%v %v] refused, monitored files are tampered.", util.CurrFuncName(), req.Method, req.URL.Path)
+				http.Error(res, "Service is unavailable as data files were modified outside of the application, please contact the administrator.", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// isIntegrityExempt checks if a request is served while monitored files are tampered,
+// admin is able to log in with the static files of the login page, check health and scrape metrics.
+func isIntegrityExempt(req *http.Request) bool {
+	switch req.URL.Path {
+	case "/login", "/logout", "/integrity", "/healthz", "/readyz", "/metrics":
+		return true
+	}
+	return strings.HasPrefix(req.URL.Path, "/static/")
+}
+
+// requireUser returns the middleware which only allows requests of logged-in users with one of roles, users of
+// any role are allowed when no role is given. The logged-in user is stored in the request context for handlers.
+// Requests not logged in are redirected to the index page and requests of other roles are answered with the error page,
+// requests of the JSON API are answered with JSON errors.
+func requireUser(userList *user.DoublyLinkedList, roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			myUser := authenticate(res, req, userList)
+			if myUser == nil {
+				if isAPIRequest(req) {
+					renderError(res, req, http.StatusUnauthorized)
+					return
+				}
+				http.Redirect(res, req, "/", http.StatusSeeOther)
+				return
+			}
+			if len(roles) > 0 && !hasRole(myUser, roles) {
+				logger.Warning.WithContext(req.Context()).Printf("%v: [%v %v] refused, user [%v] is not %v.", util.CurrFuncName(), req.Method, req.URL.Path, myUser.Username, strings.Join(roles, " or "))
+				renderError(res, req.WithContext(withSessionUser(req.Context(), myUser)), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(res, req.WithContext(withSessionUser(req.Context(), myUser)))
+		})
+	}
+}
+
+// hasRole checks if the role of a user is one of roles.
+func hasRole(myUser *user.User, roles []string) bool {
+	for _, v := range roles {
+		if myUser.Role == v {
+			return true
+		}
+	}
+	return false
+}
+
+// metricsAuthHandler requires the bearer token declared by METRICS_TOKEN to scrape metrics.
+func metricsAuthHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if token := config.Get().MetricsToken; token != "" {
+			if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+				res.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// errorHandler answers every request with the error page of status, used for requests which match no route.
+func errorHandler(status int) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		renderError(res, req, status)
+	})
+}
+
+// isAPIRequest checks if a request is made to the JSON API.
+func isAPIRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, "/api/")
+}
+
+// renderError writes the error page of status, or a JSON error for requests of the JSON API.
+func renderError(res http.ResponseWriter, req *http.Request, status int) {
+	if isAPIRequest(req) {
+		writeJSON(res, status, map[string]string{"error": strings.ToLower(http.StatusText(status))})
+		return
+	}
+
+	ViewData := struct {
+		page
+		Status  int
+		Message string
+	}{
+		newPage(req, http.StatusText(status), ""),
+		status,
+		getErrorMessage(status),
+	}
+
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(status)
+	if err := getTemplates(req).ExecuteTemplate(res, "error.gohtml", ViewData); err != nil {
+		logger.Error.Println(err)
+	}
+}
+
+// getErrorMessage returns the message displayed on the error page of status.
+func getErrorMessage(status int) string {
+	switch status {
+	case http.StatusNotFound:
+		return "The page you are looking for does not exist."
+	case http.StatusForbidden:
+		return "You do not have permission to access this page."
+	case http.StatusUnauthorized:
+		return "Please log in to access this page."
+	default:
+		return "Something went wrong while processing your request, please try again later."
+	}
+}
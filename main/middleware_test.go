@@ -0,0 +1,288 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gorilla/mux"
+	"github.com/shiweii/config"
+	dll "github.com/shiweii/doublylinkedlist"
+	"github.com/shiweii/logger"
+	"github.com/shiweii/metrics"
+	"github.com/shiweii/user"
+)
+
+// setTestTemplates parses the embedded templates used by error pages, templates are unset when the test ends.
+func setTestTemplates(t *testing.T) {
+	set, err := parseTemplates(webFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl = set
+	t.Cleanup(func() { tpl = nil })
+}
+
+// TestRequireUser checks requests are redirected when not logged in and refused for other roles,
+// the logged-in user is stored in the request context of allowed requests.
+func TestRequireUser(t *testing.T) {
+	loadTestConfig(t, t.TempDir())
+	setTestTemplates(t)
+	userList := user.DoublyLinkedList{DoublyLinkedList: dll.New()}
+	for _, v := range []*user.User{
+		user.New("admin", "", enumAdmin, "Admin", "", 0),
+		user.New("dentist1", "", enumDentist, "John", "Lim", 0),
+		user.New("patient1", "", enumPatient, "Jane", "Tan", 91234567),
+	} {
+		userList.Add(v)
+		mapSessions.Set("session-"+v.Username, v.Username)
+		defer mapSessions.Delete("session-" + v.Username)
+	}
+	userList.InsertionSort()
+
+	tests := []struct {
+		name     string
+		roles    []string
+		path     string
+		username string
+		code     int
+		location string
+	}{
+		{"not logged in", nil, "/appointments", "", http.StatusSeeOther, "/"},
+		{"API not logged in", nil, "/api/slots", "", http.StatusUnauthorized, ""},
+		{"session ended", nil, "/appointments", "ended", http.StatusSeeOther, "/"},
+		{"logged in", nil, "/appointments", "patient1", http.StatusOK, ""},
+		{"admin only as patient", []string{enumAdmin}, "/users", "patient1", http.StatusForbidden, ""},
+		{"admin only as dentist", []string{enumAdmin}, "/users", "dentist1", http.StatusForbidden, ""},
+		{"API admin only as patient", []string{enumAdmin}, "/api/reports", "patient1", http.StatusForbidden, ""},
+		{"admin only as admin", []string{enumAdmin}, "/users", "admin", http.StatusOK, ""},
+		{"admin or dentist as dentist", []string{enumAdmin, enumDentist}, "/appointments/runsheet", "dentist1", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		var got *user.User
+		handler := requireUser(&userList, tt.roles...)(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			got = getSessionUser(req)
+		}))
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.username != "" {
+			req.AddCookie(&http.Cookie{Name: config.Get().CookieName, Value: "session-" + tt.username})
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != tt.code || res.Header().Get("Location") != tt.location {
+			t.Errorf("%v: got %d %q, want %d %q", tt.name, res.Code, res.Header().Get("Location"), tt.code, tt.location)
+		}
+		if tt.code == http.StatusOK && (got == nil || got.Username != tt.username) {
+			t.Errorf("%v: got user %v in context, want %v", tt.name, got, tt.username)
+		}
+		if tt.code != http.StatusOK && got != nil {
+			t.Errorf("%v: handler was called", tt.name)
+		}
+		if tt.code == http.StatusForbidden && strings.HasPrefix(tt.path, "/api/") != strings.HasPrefix(res.Header().Get("Content-Type"), "application/json") {
+			t.Errorf("%v: got Content-Type %q", tt.name, res.Header().Get("Content-Type"))
+		}
+	}
+}
+
+// TestRecoveryHandler checks panics are answered with the error page through every middleware.
+func TestRecoveryHandler(t *testing.T) {
+	setTestTemplates(t)
+	handler := chain(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		panic("handler failed")
+	}), getMiddleware()...)
+
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+	}{
+		{"/appointments", "text/html; charset=utf-8", getErrorMessage(http.StatusInternalServerError)},
+		{"/api/slots", "application/json", `{"error":"internal server error"}`},
+	}
+	for _, tt := range tests {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if res.Code != http.StatusInternalServerError {
+			t.Errorf("%v: got %d, want %d", tt.path, res.Code, http.StatusInternalServerError)
+		}
+		if got := res.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%v: got Content-Type %q, want %q", tt.path, got, tt.contentType)
+		}
+		if !strings.Contains(res.Body.String(), tt.body) {
+			t.Errorf("%v: got body %q, want %q", tt.path, res.Body.String(), tt.body)
+		}
+		if res.Header().Get("X-Request-ID") == "" {
+			t.Errorf("%v: X-Request-ID is not set", tt.path)
+		}
+	}
+}
+
+// TestRequestIDHandler checks the ID of every request is returned and stored in the request context,
+// valid IDs of the load balancer are passed through.
+func TestRequestIDHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"new", "", ""},
+		{"passed through", "lb-8f14e45f.1", "lb-8f14e45f.1"},
+		{"not valid", "id\nlevel=error", ""},
+		{"too long", strings.Repeat("a", 129), ""},
+	}
+	for _, tt := range tests {
+		var got string
+		handler := requestIDHandler(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			got = logger.RequestID(req.Context())
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set("X-Request-ID", tt.header)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		id := res.Header().Get("X-Request-ID")
+		if id == "" || id != got {
+			t.Errorf("%v: got X-Request-ID %q and %q in context, want the same ID", tt.name, id, got)
+		}
+		if tt.want != "" && id != tt.want {
+			t.Errorf("%v: got X-Request-ID %q, want %q", tt.name, id, tt.want)
+		}
+		if tt.want == "" && id == tt.header {
+			t.Errorf("%v: got X-Request-ID %q, want a new ID", tt.name, id)
+		}
+	}
+
+	// Every request is given a different ID
+	first, second := httptest.NewRecorder(), httptest.NewRecorder()
+	handler := requestIDHandler(http.NotFoundHandler())
+	handler.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/", nil))
+	handler.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/", nil))
+	if first.Header().Get("X-Request-ID") == second.Header().Get("X-Request-ID") {
+		t.Error("requests were given the same ID")
+	}
+}
+
+// TestSecurityHeadersHandler checks every request is given a new nonce which is the only way inline scripts
+// of pages are allowed, the nonce of the header is rendered into the scripts of pages.
+func TestSecurityHeadersHandler(t *testing.T) {
+	setTestTemplates(t)
+	viewData := getTestViewData()
+	headerNonce := regexp.MustCompile(`'nonce-([A-Za-z0-9_-]+)'`)
+	scriptNonce := regexp.MustCompile(`<script nonce="([^"]*)">`)
+	nonces := make(map[string]bool)
+
+	for _, name := range []string{"appointmentList.gohtml", "appointmentSearch.gohtml"} {
+		handler := securityHeadersHandler(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			data := viewData[name]
+			page := newPage(req, "Appointments", "")
+			data["LoggedInUser"] = user.New("admin", "", enumAdmin, "Admin", "", 0)
+			data["PageTitle"], data["CurrentPage"], data["Nonce"] = page.PageTitle, page.CurrentPage, page.Nonce
+			if err := getTemplates(req).ExecuteTemplate(res, name, data); err != nil {
+				t.Errorf("%v: %v", name, err)
+			}
+		}))
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/appointments", nil))
+
+		var scriptSrc string
+		for _, v := range strings.Split(res.Header().Get("Content-Security-Policy"), ";") {
+			if v = strings.TrimSpace(v); strings.HasPrefix(v, "script-src ") {
+				scriptSrc = v
+			}
+		}
+		if scriptSrc == "" || strings.Contains(scriptSrc, "'unsafe-inline'") {
+			t.Errorf("%v: got script-src %q, want inline scripts allowed by nonce only", name, scriptSrc)
+		}
+		match := headerNonce.FindStringSubmatch(scriptSrc)
+		if match == nil {
+			t.Fatalf("%v: got script-src %q, want a nonce", name, scriptSrc)
+		}
+		if nonces[match[1]] {
+			t.Errorf("%v: nonce %v was used by another request", name, match[1])
+		}
+		nonces[match[1]] = true
+
+		scripts := scriptNonce.FindAllStringSubmatch(res.Body.String(), -1)
+		if len(scripts) == 0 {
+			t.Errorf("%v: no inline script with nonce is rendered", name)
+		}
+		for _, v := range scripts {
+			if v[1] != match[1] {
+				t.Errorf("%v: got script nonce %q, want %q of the header", name, v[1], match[1])
+			}
+		}
+		if strings.Contains(res.Body.String(), "<script>") {
+			t.Errorf("%v: inline script without nonce is rendered", name)
+		}
+		if got := res.Header().Get("Strict-Transport-Security"); !strings.HasPrefix(got, "max-age=") {
+			t.Errorf("%v: got Strict-Transport-Security %q", name, got)
+		}
+	}
+}
+
+// TestSecurityHeadersHandlerNonceError checks no inline script is allowed when a nonce cannot be generated.
+func TestSecurityHeadersHandlerNonceError(t *testing.T) {
+	nonceReader = iotest.ErrReader(errors.New("entropy is not available"))
+	defer func() { nonceReader = rand.Reader }()
+	var nonce string
+	handler := securityHeadersHandler(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		nonce = getNonce(req)
+	}))
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/appointments", nil))
+
+	csp := res.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "script-src 'self'") || strings.Contains(csp, "'nonce-") || strings.Contains(csp, "'unsafe-inline'") {
+		t.Errorf("got Content-Security-Policy %q, want no inline scripts allowed", csp)
+	}
+	if nonce != "" {
+		t.Errorf("got nonce %q in context, want empty", nonce)
+	}
+}
+
+// TestMetricsHandler checks requests are counted by the template of the matched route,
+// paths which did not match any route and unknown methods are not used as labels.
+func TestMetricsHandler(t *testing.T) {
+	setTestTemplates(t)
+	router := mux.NewRouter()
+	router.Use(metricsHandler)
+	router.NotFoundHandler = chain(errorHandler(http.StatusNotFound), metricsHandler)
+	router.HandleFunc("/metrics-test/{id:[0-9]+}", func(res http.ResponseWriter, req *http.Request) {})
+
+	for _, v := range []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/metrics-test/42"},
+		{http.MethodGet, "/metrics-test/unknown-1"},
+		{"PROPFIND", "/metrics-test/unknown-2"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(v.method, v.path, nil))
+	}
+
+	res := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := res.Body.String()
+	for _, v := range []string{
+		`dental_http_requests_total{code="200",method="GET",route="/metrics-test/{id:[0-9]+}"} 1`,
+		`dental_http_requests_total{code="404",method="GET",route="unmatched"}`,
+		`dental_http_requests_total{code="404",method="other",route="unmatched"}`,
+	} {
+		if !strings.Contains(body, v) {
+			t.Errorf("got no %v in metrics", v)
+		}
+	}
+	for _, v := range []string{"unknown-1", "unknown-2", "PROPFIND"} {
+		if strings.Contains(body, v) {
+			t.Errorf("got %v in metrics, want no labels from requests which did not match", v)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Behaviour of form controls marked with data attributes, inline event handlers are blocked by the Content Security Policy.
+// Inputs marked with data-autosubmit submit their form when changed, buttons marked with data-confirm
+// only submit their form when the message is confirmed.
+document.addEventListener("DOMContentLoaded", function () {
+    document.querySelectorAll("[data-autosubmit]").forEach(function (input) {
+        input.addEventListener("change", function () {
+            input.form.submit();
+        });
+    });
+    document.querySelectorAll("[data-confirm]").forEach(function (button) {
+        button.addEventListener("click", function (event) {
+            if (!confirm(button.dataset.confirm)) {
+                event.preventDefault();
+            }
+        });
+    });
+});
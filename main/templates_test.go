@@ -0,0 +1,322 @@
+package main
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	app "github.com/shiweii/appointment"
+	"github.com/shiweii/audit"
+	"github.com/shiweii/i18n"
+	"github.com/shiweii/integrity"
+	"github.com/shiweii/notification"
+	"github.com/shiweii/user"
+)
+
+// getTestViewData returns representative data of every page, including optional fields displayed on success or error.
+// Data is declared as maps so that keys misspelled in templates are reported as errors.
+func getTestViewData() map[string]map[string]interface{} {
+	patient := user.New("patient1", "", enumPatient, "Jane", "Tan", 91234567)
+	patient.Email = "jane@example.com"
+	dentist := user.New("dentist1", "", enumDentist, "John", "Lim", 0)
+	dentists := []*user.User{dentist}
+
+	appointment := app.New(1, patient, dentist, "2022-05-02", 2)
+	appointment.SeriesID = 1
+	appointment.Type = "CLN"
+	appointment.Duration = 2
+	appointment.Clinic = "HQ"
+	appointment.Chair = "C1"
+	following := app.New(2, patient, dentist, "2022-05-09", 2)
+
+	chair := &app.Chair{Code: "C1", Name: "Chair 1"}
+	clinic := &app.Clinic{Code: "HQ", Name: "Headquarters", Chairs: []*app.Chair{chair}, Dentists: []string{"dentist1"}, Default: true}
+	clinics := app.Clinics{clinic, {Code: "EA", Name: "East", Chairs: []*app.Chair{{Code: "C1", Name: "Chair 1"}}}}
+	appointmentType := &app.AppointmentType{Code: "CLN", Name: "Cleaning", Sessions: 2}
+	types := app.AppointmentTypes{appointmentType, {Code: "CHK", Name: "Check-up", Sessions: 1}}
+	holiday := &app.Holiday{Date: "2022-05-16", Name: "Vesak Day"}
+	violations := []*app.RuleViolation{{Rule: "minLeadTime", Reason: "Appointment must be booked in advance."}}
+
+	appSessions := []app.AppSession{
+		{Num: 1, StartTime: "09:00", EndTime: "10:00", Available: true},
+		{Num: 2, StartTime: "10:00", EndTime: "11:00"},
+		{Num: 3, StartTime: "11:00", EndTime: "12:00", Available: true},
+	}
+	sessions := make([]interface{}, len(appSessions))
+	for i, v := range appSessions {
+		sessions[i] = v
+	}
+
+	availability := app.NewDentistAvailability("dentist1", []int{1, 2})
+	availability.AddLeave("2022-05-03")
+	availability.AddBlockedSlot("2022-05-04", 2, "Training")
+
+	now := time.Date(2022, 5, 1, 9, 30, 0, 0, time.Local)
+
+	return map[string]map[string]interface{}{
+		"index.gohtml": {},
+		"login.gohtml": {"LoginFail": true},
+		"error.gohtml": {"Status": 404, "Message": getErrorMessage(404)},
+		"signup.gohtml": {
+			"ValidateFirstName": false, "ValidateLastName": false, "ValidateUserName": false, "UserNameTaken": true,
+			"ValidatePassword": false, "ValidateMobileNumber": false, "ValidateEmail": false,
+			"InputUserName": "patient1", "InputPassword": "", "InputFirstName": "Jane", "InputLastName": "Tan",
+			"InputMobileNumber": "91234567", "InputEmail": "jane@example.com",
+		},
+		"appointmentList.gohtml": {
+			"Appointments": []*app.Appointment{appointment, following}, "Sessions": sessions, "Dentists": dentists,
+			"Option": "upcoming", "TodayDate": "2022-05-02", "Types": types, "Clinics": clinics, "ScheduleDate": "2022-05-02",
+		},
+		"appointmentSearch.gohtml": {
+			"Dentist": dentist, "Dentists": dentists, "TodayDate": "2022-05-01", "DentistsSession": appSessions,
+			"SelectedDate": "2022-05-02", "FormProcessed": true, "ClinicClosed": holiday, "FullyBooked": true,
+		},
+		"appointmentCreate_step1.gohtml": {"Dentists": dentists, "Clinics": clinics, "Branch": clinic},
+		"appointmentCreate_step2.gohtml": {
+			"Dentist": dentist, "TodayDate": "2022-05-01", "Sessions": appSessions, "SelectedDate": "2022-05-02",
+			"ClinicClosed": holiday, "FullyBooked": true, "Types": types, "SelectedType": appointmentType,
+			"Clinics": clinics, "Branch": clinic,
+		},
+		"appointmentCreateConfirm.gohtml": {
+			"Dentist": dentist, "Date": "2022-05-02", "StartTime": "10:00", "EndTime": "12:00", "Successful": false,
+			"FormSubmitted": true, "IsInputError": false, "RuleViolations": violations, "Interval": 1, "Occurrences": 2,
+			"Series": []map[string]interface{}{
+				{"Date": "2022-05-02", "Conflict": "", "RuleBroken": false, "Booked": true},
+				{"Date": "2022-05-09", "Conflict": "Dentist is not available.", "RuleBroken": false, "Booked": false},
+			},
+			"SeriesConflicts": 1, "SeriesBooked": 1, "MaxSeriesInterval": 4, "MaxSeriesOccurrences": 12,
+			"Type": appointmentType, "Branch": clinic, "Chair": chair,
+		},
+		"appointmentEdit.gohtml": {
+			"Appointment": appointment, "Dentists": dentists, "DentistsSession": appSessions, "Sessions": sessions,
+			"TodayDate": "2022-05-01", "SelectedDate": "2022-05-02", "SelectedDentist": "dentist1",
+			"IsInputError": false, "ClinicClosed": holiday, "Branch": clinic,
+		},
+		"appointmentEditConfirm.gohtml": {
+			"CurrentAppointment": appointment, "OldDentist": dentist, "OldDate": "2022-05-02", "OldSession": 2,
+			"EditedDentist": dentist, "EditedDate": "2022-05-03", "EditedSession": 1, "SessionList": sessions,
+			"Successful": false, "Unsuccessful": true, "UnsuccessfulMsg": "Session is not available.",
+			"IsInputError": false, "RuleViolations": violations, "Following": []*app.Appointment{following},
+			"SeriesConflicts": []app.SeriesConflict{{Date: "2022-05-10", Reason: "Dentist is on leave."}},
+			"Scope":           "following", "Type": appointmentType, "OldLastSession": 3, "EditedLastSession": 2,
+			"OldBranch": clinic, "EditedBranch": clinic,
+		},
+		"appointmentDelete.gohtml": {
+			"Appointment": appointment, "Sessions": sessions, "Successful": true, "IsInputError": false,
+			"CutOff": violations[0], "Following": []*app.Appointment{following}, "Cancelled": 2, "Types": types,
+		},
+		"userList.gohtml": {
+			"Users":      []interface{}{patient, dentist, &user.User{Username: "old", Role: enumPatient, IsDeleted: true}},
+			"Successful": true, "ErrorDelete": true, "ErrorDeleteMsg": "User has upcoming appointments.",
+		},
+		"userEdit.gohtml": {
+			"UserData": patient, "ValidateFirstName": false, "ValidateLastName": false, "ValidateMobileNumber": false,
+			"ValidateEmail": false, "ValidatePassword": true, "Successful": true,
+		},
+		"sessions.gohtml": {
+			"Sessions": []map[string]interface{}{
+				{"SessionID": "1", "Username": "admin", "Role": enumAdmin},
+				{"SessionID": "2", "Username": "patient1", "Role": enumPatient},
+			},
+		},
+		"availability.gohtml": {
+			"Dentist": dentist, "Availability": availability, "Weekdays": []time.Weekday{time.Monday, time.Tuesday},
+			"Sessions": sessions, "TodayDate": "2022-05-01", "Successful": true, "IsInputError": true,
+		},
+		"holidays.gohtml": {
+			"Holidays":  []map[string]interface{}{{"Date": "2022-05-16", "Name": "Vesak Day", "Appointments": 1}},
+			"TodayDate": "2022-05-01", "ImportedCount": 1, "Successful": true, "IsInputError": true,
+		},
+		"holidayAppointments.gohtml": {
+			"Holiday": holiday, "Appointments": []*app.Appointment{appointment}, "Sessions": sessions, "Action": "reschedule",
+			"Results": []map[string]interface{}{
+				{"Appointment": appointment, "Successful": true, "NewDate": "2022-05-17", "NewSession": 1},
+				{"Appointment": following, "Successful": false, "NewDate": "", "NewSession": 0},
+			},
+		},
+		"waitlist.gohtml": {
+			"Entries": []map[string]interface{}{{
+				"ID": 1, "Dentist": "dentist1", "FromDate": "2022-05-02", "ToDate": "2022-05-31",
+				"Offer":       &app.SlotOffer{Dentist: "dentist1", Date: "2022-05-03", Session: 1, ExpiresAt: now},
+				"PatientUser": patient, "DentistUser": dentist, "OfferActive": true, "OfferTime": "09:00 - 10:00",
+			}},
+			"Dentists": dentists, "TodayDate": "2022-05-01", "InputDentist": "dentist1", "InputDate": "2022-05-02",
+			"Successful": true, "IsInputError": true,
+		},
+		"notificationSettings.gohtml": {
+			"Channels":   []string{"sms", "email", "log"},
+			"Categories": []string{"booked", "rescheduled", "cancelled", "reminder"},
+			"Preference": notification.Preference{Username: "patient1", MutedChannels: []string{"sms"}, MutedCategories: []string{"reminder"}},
+			"Successful": true,
+		},
+		"appointmentFind.gohtml": {
+			"Dentists":  dentists,
+			"Weekdays":  []map[string]interface{}{{"Day": time.Monday, "Checked": true}, {"Day": time.Tuesday, "Checked": false}},
+			"TodayDate": "2022-05-01",
+			"Slots":     []app.OpenSlot{{Dentist: dentist, Date: "2022-05-02", Session: appSessions[0], StartTime: now}},
+			"Query":     app.SlotQuery{FromDate: now, ToDate: now.AddDate(0, 0, 14)}, "InputDentist": "dentist1",
+			"InputTime": "morning", "FormProcessed": true, "IsInputError": true, "Types": types, "InputType": "CLN",
+			"Clinics": clinics, "InputBranch": "HQ",
+		},
+		"calendar.gohtml": {"FeedURL": "https://localhost/calendar/token.ics", "WebcalURL": "webcal://localhost/calendar/token.ics", "Successful": true},
+		"import.gohtml": {
+			"InputKind": "appointments", "FileName": "appointments.csv",
+			"Results":    []importResult{{Line: 2, Name: "patient1", Valid: true}, {Line: 3, Name: "patient2", Message: "User does not exist."}},
+			"ValidCount": 1, "RejectedCount": 1, "FormProcessed": true, "Applied": false, "ErrorMsg": "",
+		},
+		"reports.gohtml": {
+			"InputFrom": "2022-05-01", "InputTo": "2022-05-31", "MaxDays": 366, "IsInputError": true,
+			"Report": &app.Report{
+				From: "2022-05-01", To: "2022-05-31", Appointments: 2, Cancellations: 1, CancellationRate: 0.5,
+				PastAppointments: 1, NoShows: 1, NoShowRate: 1, NewPatients: 1,
+				Dentists: []*app.DentistReport{{
+					Dentist: "dentist1", Name: "Dr. John Lim", Appointments: 2,
+					Total:     app.UsageReport{Label: "Total", Booked: 2, Available: 40, Rate: 0.05},
+					BySession: []app.UsageReport{{Label: "09:00 - 10:00", Booked: 1, Available: 20, Rate: 0.05}},
+					ByWeekday: []app.UsageReport{{Label: "Monday", Booked: 2, Available: 8, Rate: 0.25}},
+				}},
+				BusiestSlots: []app.SlotReport{{Weekday: "Monday", Session: 2, Appointments: 2}},
+			},
+		},
+		"audit.gohtml": {
+			"Actions": []string{"login", "appointment.create"}, "InputActor": "admin", "InputAction": "login",
+			"InputTarget": "", "InputQuery": "", "InputFrom": "2022-05-01", "InputTo": "2022-05-31", "IsInputError": true,
+			"Entries": []*audit.Entry{{Seq: 1, Time: now, Actor: "admin", Action: "user.update", Target: "patient1",
+				Changes: []audit.Change{{Field: "email", Before: "old@example.com", After: "jane@example.com"}}, IP: "127.0.0.1"}},
+			"Total": 2, "Verified": 1, "ChainError": "entry 2: hash does not match",
+		},
+		"integrity.gohtml": {
+			"Mode": "readonly",
+			"Files": []integrity.FileStatus{
+				{Path: "data/users.json", Expected: "abc", Actual: "def", Tampered: true, Reason: "hash does not match", DetectedAt: now},
+				{Path: "data/appointments.json", Expected: "abc", Actual: "abc"},
+			},
+			"IsTampered": true,
+		},
+	}
+}
+
+// TestTemplates executes every page with the representative data for every role in every locale.
+func TestTemplates(t *testing.T) {
+	files, err := fs.Glob(webFiles, "templates/*.gohtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := parseTemplates(webFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viewData := getTestViewData()
+	users := []*user.User{
+		user.New("admin", "", enumAdmin, "Admin", "", 0),
+		user.New("dentist1", "", enumDentist, "John", "Lim", 0),
+		user.New("patient1", "", enumPatient, "Jane", "Tan", 91234567),
+	}
+
+	for _, locale := range i18n.Locales {
+		tmpl := set[locale].Option("missingkey=error")
+		for _, file := range files {
+			name := path.Base(file)
+			if name == "header.gohtml" || name == "footer.gohtml" {
+				continue
+			}
+			data, ok := viewData[name]
+			if !ok {
+				t.Errorf("%v: no representative data", name)
+				continue
+			}
+			for _, u := range users {
+				data["LoggedInUser"] = u
+				data["PageTitle"] = strings.TrimSuffix(name, ".gohtml")
+				data["CurrentPage"] = ""
+				data["Nonce"] = "nonce"
+				if err := tmpl.ExecuteTemplate(ioutil.Discard, name, data); err != nil {
+					t.Errorf("%v as %v in %v: %v", name, u.Role, locale, err)
+				}
+			}
+		}
+
+		// Pages displayed to users who are not logged in
+		for _, name := range []string{"index.gohtml", "login.gohtml", "signup.gohtml", "error.gohtml"} {
+			data := viewData[name]
+			data["LoggedInUser"] = (*user.User)(nil)
+			if err := tmpl.ExecuteTemplate(ioutil.Discard, name, data); err != nil {
+				t.Errorf("%v logged out in %v: %v", name, locale, err)
+			}
+		}
+	}
+}
+
+// TestTranslations checks every message of templates and every page title is translated.
+// Catalogs of all locales are checked to have the same messages by the i18n package.
+func TestTranslations(t *testing.T) {
+	var messages []string
+	templateMessage := regexp.MustCompile(`[{(]\s*t\s+"((?:[^"\\]|\\.)*)"`)
+	files, err := fs.Glob(webFiles, "templates/*.gohtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		b, err := webFiles.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range templateMessage.FindAllStringSubmatch(string(b), -1) {
+			message, err := strconv.Unquote(`"` + v[1] + `"`)
+			if err != nil {
+				t.Fatalf("%v: %v", file, err)
+			}
+			messages = append(messages, message)
+		}
+	}
+
+	title := regexp.MustCompile(`newPage\(req, "([^"]+)"`)
+	b, err := os.ReadFile("handler.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range title.FindAllStringSubmatch(string(b), -1) {
+		messages = append(messages, v[1])
+	}
+	for _, v := range []int{http.StatusNotFound, http.StatusForbidden, http.StatusUnauthorized, http.StatusInternalServerError} {
+		messages = append(messages, http.StatusText(v), getErrorMessage(v))
+	}
+
+	for _, v := range messages {
+		if i18n.T("zh-Hans", v) == v {
+			t.Errorf("%q is not translated", v)
+		}
+	}
+}
+
+// TestParseTemplatesOverride checks templates are parsed from the override directory.
+func TestParseTemplatesOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "templates", "index.gohtml"), []byte(`{{.PageTitle | firstCharToUpper}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := parseTemplates(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := tmpl[i18n.Default].ExecuteTemplate(&b, "index.gohtml", page{PageTitle: "home"}); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "Home" {
+		t.Errorf("got %q, want %q", b.String(), "Home")
+	}
+}
@@ -0,0 +1,111 @@
+// Package metrics exposes the metrics of the server in the Prometheus text format, HTTP requests and latency by route,
+// active sessions, appointment events, login attempts and write latency of data files. Metrics are kept in a registry
+// of the package so that only the metrics of the server and the Go runtime are exposed.
+package metrics
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the names of all metrics.
+const Namespace = "dental"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	appointmentEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "appointment_events_total",
+		Help:      "Number of appointments booked, rescheduled and cancelled.",
+	}, []string{"event"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "logins_total",
+		Help:      "Number of login attempts by result.",
+	}, []string{"result"})
+
+	writeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "persistence_write_duration_seconds",
+		Help:      "Latency of writes of data files by file name.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 12),
+	}, []string{"file"})
+
+	sessionsOnce sync.Once
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests, requestDuration, appointmentEvents, logins, writeDuration,
+	)
+}
+
+// Handler returns the handler which exposes all metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a completed HTTP request. Route is the template of the matched route
+// rather than the request path, so that the number of series does not grow with IDs in paths.
+// Requests which did not match any route are to be recorded with the same route such as "unmatched".
+func ObserveRequest(route, method string, code int, duration time.Duration) {
+	requests.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	requestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// CountAppointmentEvent records an appointment event (booked, rescheduled or cancelled).
+func CountAppointmentEvent(event string) {
+	appointmentEvents.WithLabelValues(event).Inc()
+}
+
+// CountLogin records a successful or failed login attempt.
+func CountLogin(success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	logins.WithLabelValues(result).Inc()
+}
+
+// ObserveWrite records the latency of writing a data file, files are labelled by name.
+func ObserveWrite(path string, duration time.Duration) {
+	writeDuration.WithLabelValues(filepath.Base(path)).Observe(duration.Seconds())
+}
+
+// RegisterSessions exposes the number of active sessions returned by count on every scrape.
+// Only the first count registered is used.
+func RegisterSessions(count func() int) {
+	sessionsOnce.Do(func() {
+		registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "active_sessions",
+			Help:      "Number of active login sessions.",
+		}, func() float64 {
+			return float64(count())
+		}))
+	})
+}
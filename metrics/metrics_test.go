@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	ObserveRequest("/appointment/edit/{id:[0-9]+}", "GET", 200, 20*time.Millisecond)
+	ObserveRequest("/appointment/edit/{id:[0-9]+}", "GET", 200, 30*time.Millisecond)
+	CountAppointmentEvent("booked")
+	CountLogin(false)
+	ObserveWrite("data/appointments.json", time.Millisecond)
+	RegisterSessions(func() int { return 3 })
+	RegisterSessions(func() int { return 5 })
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("/appointment/edit/{id:[0-9]+}", "GET", "200")); got != 2 {
+		t.Errorf("got %v requests, want 2", got)
+	}
+	if got := testutil.ToFloat64(logins.WithLabelValues("failure")); got != 1 {
+		t.Errorf("got %v failed logins, want 1", got)
+	}
+
+	res := httptest.NewRecorder()
+	Handler().ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	for _, v := range []string{
+		`dental_active_sessions 3`,
+		`dental_appointment_events_total{event="booked"} 1`,
+		`dental_persistence_write_duration_seconds_count{file="appointments.json"} 1`,
+		`dental_http_request_duration_seconds_count{method="GET",route="/appointment/edit/{id:[0-9]+}"} 2`,
+	} {
+		if !strings.Contains(res.Body.String(), v) {
+			t.Errorf("got no %v in metrics", v)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Dispatcher delivers a message through every channel the recipient has not muted for the message category.
+type Dispatcher struct {
+	Channels    map[string]Notifier
+	Preferences *Preferences
+}
+
+// NewDispatcher will return a newly created dispatcher over channels by name.
+func NewDispatcher(channels map[string]Notifier, preferences *Preferences) *Dispatcher {
+	return &Dispatcher{
+		Channels:    channels,
+		Preferences: preferences,
+	}
+}
+
+// Notify sends the message through all allowed channels in order of channel name.
+// Channels without contact of the recipient are skipped, the last delivery error is returned.
+func (d *Dispatcher) Notify(msg *Message) error {
+	var names []string
+	for name := range d.Channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lastErr error
+	for _, name := range names {
+		if !d.Preferences.Allows(msg.Username, name, msg.Category) {
+			continue
+		}
+		if err := d.Channels[name].Notify(msg); err != nil && !errors.Is(err, ErrNoRecipient) {
+			lastErr = fmt.Errorf("%v: %w", name, err)
+		}
+	}
+	return lastErr
+}
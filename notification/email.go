@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// EmailNotifier sends messages as plain text emails through an SMTP server.
+// PLAIN authentication is used when Username is set.
+type EmailNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// sendMail is replaced in tests, smtp.SendMail is used when nil
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailNotifier will return a newly created email notifier.
+func NewEmailNotifier(host, port, username, password, from string) *EmailNotifier {
+	return &EmailNotifier{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     from,
+	}
+}
+
+// Notify sends the message to the email address of the user.
+func (n *EmailNotifier) Notify(msg *Message) error {
+	if msg.Email == "" {
+		return ErrNoRecipient
+	}
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+	sendMail := n.sendMail
+	if sendMail == nil {
+		sendMail = smtp.SendMail
+	}
+	return sendMail(net.JoinHostPort(n.Host, n.Port), auth, n.From, []string{msg.Email}, buildEmail(n.From, msg))
+}
+
+// buildEmail returns the message in RFC 822 format, line breaks in headers are removed.
+func buildEmail(from string, msg *Message) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %v\r\n", header.Replace(from))
+	fmt.Fprintf(&b, "To: %v\r\n", header.Replace(msg.Email))
+	fmt.Fprintf(&b, "Subject: %v\r\n", header.Replace(msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
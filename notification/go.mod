@@ -0,0 +1,29 @@
+module github.com/shiweii/notification
+
+go 1.18
+
+require (
+	github.com/shiweii/integrity v0.0.0-00010101000000-000000000000
+	github.com/shiweii/logger v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_golang v1.14.0 // indirect
+	github.com/prometheus/client_model v0.3.0 // indirect
+	github.com/prometheus/common v0.37.0 // indirect
+	github.com/prometheus/procfs v0.8.0 // indirect
+	github.com/shiweii/metrics v0.0.0-00010101000000-000000000000 // indirect
+	golang.org/x/sys v0.7.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+)
+
+replace github.com/shiweii/logger => ../logger
+
+replace github.com/shiweii/integrity => ../integrity
+
+replace github.com/shiweii/metrics => ../metrics
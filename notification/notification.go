@@ -0,0 +1,58 @@
+// Package notification implements delivery of messages to users through pluggable channels.
+package notification
+
+import (
+	"errors"
+
+	"github.com/shiweii/logger"
+)
+
+// ErrNoRecipient is returned by notifiers when the user has no contact for the channel.
+var ErrNoRecipient = errors.New("notification: user has no contact for this channel")
+
+// Message struct stores a notification to be delivered to a user.
+type Message struct {
+	Username     string `json:"username"`
+	MobileNumber int    `json:"mobileNumber,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Subject      string `json:"subject"`
+	Body         string `json:"body"`
+	Category     string `json:"category,omitempty"`
+}
+
+// Notifier is implemented by any channel which is able to deliver a message to a user.
+type Notifier interface {
+	Notify(msg *Message) error
+}
+
+// LogNotifier writes messages into the application log,
+// used when no other delivery channel is configured.
+type LogNotifier struct{}
+
+// NewMessage will return a newly created instance of a message.
+func NewMessage(username string, mobileNumber int, subject, body string) *Message {
+	return &Message{
+		Username:     username,
+		MobileNumber: mobileNumber,
+		Subject:      subject,
+		Body:         body,
+	}
+}
+
+// WithCategory sets the category of the message used to check the recipient's preferences and returns the message.
+func (msg *Message) WithCategory(category string) *Message {
+	msg.Category = category
+	return msg
+}
+
+// WithEmail sets the email address of the recipient and returns the message.
+func (msg *Message) WithEmail(email string) *Message {
+	msg.Email = email
+	return msg
+}
+
+// Notify writes message into the application log.
+func (n *LogNotifier) Notify(msg *Message) error {
+	logger.Info.Printf("Notification to [%v] (%v): %v - %v", msg.Username, msg.MobileNumber, msg.Subject, msg.Body)
+	return nil
+}
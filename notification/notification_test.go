@@ -0,0 +1,104 @@
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+func TestSMSNotifier(t *testing.T) {
+	var got smsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(req.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	notifier := NewSMSNotifier(server.URL, "secret", "Clinic")
+	if err := notifier.Notify(NewMessage("patient1", 91234567, "Reminder", "See you tomorrow.")); err != nil {
+		t.Fatalf("Notify() = %v; want nil", err)
+	}
+	if got.To != "91234567" || got.From != "Clinic" || got.Message != "Reminder: See you tomorrow." {
+		t.Errorf("Notify() sent %+v", got)
+	}
+	if err := notifier.Notify(NewMessage("patient1", 0, "Reminder", "")); !errors.Is(err, ErrNoRecipient) {
+		t.Errorf("Notify() without mobile number = %v; want %v", err, ErrNoRecipient)
+	}
+	notifier.APIKey = "wrong"
+	if err := notifier.Notify(NewMessage("patient1", 91234567, "Reminder", "")); err == nil {
+		t.Errorf("Notify() with rejected request = nil; want error")
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	var gotAddr string
+	var gotMsg []byte
+	notifier := NewEmailNotifier("smtp.example.com", "587", "", "", "clinic@example.com")
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr = addr
+		gotMsg = msg
+		return nil
+	}
+	msg := NewMessage("patient1", 0, "Reminder\r\nBcc: x@example.com", "See you tomorrow.").WithEmail("amy@example.com")
+	if err := notifier.Notify(msg); err != nil {
+		t.Fatalf("Notify() = %v; want nil", err)
+	}
+	if gotAddr != "smtp.example.com:587" {
+		t.Errorf("Notify() addr = %v; want smtp.example.com:587", gotAddr)
+	}
+	if strings.Contains(string(gotMsg), "\r\nBcc:") {
+		t.Errorf("Notify() allowed header injection: %q", gotMsg)
+	}
+	if err := notifier.Notify(NewMessage("patient1", 0, "Reminder", "")); !errors.Is(err, ErrNoRecipient) {
+		t.Errorf("Notify() without email = %v; want %v", err, ErrNoRecipient)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	sms := &MemoryNotifier{}
+	email := &MemoryNotifier{Err: ErrNoRecipient}
+	preferences := NewPreferences("")
+	preferences.Set(Preference{Username: "patient2", MutedChannels: []string{"sms"}})
+	preferences.Set(Preference{Username: "patient3", MutedCategories: []string{CategoryReminder}})
+	dispatcher := NewDispatcher(map[string]Notifier{"sms": sms, "email": email}, preferences)
+
+	for _, username := range []string{"patient1", "patient2", "patient3"} {
+		if err := dispatcher.Notify(NewMessage(username, 91234567, "Reminder", "").WithCategory(CategoryReminder)); err != nil {
+			t.Errorf("Notify(%v) = %v; want nil", username, err)
+		}
+	}
+	if got := sms.GetMessages(); len(got) != 1 || got[0].Username != "patient1" {
+		t.Errorf("Notify() sent %v SMS; want 1 to patient1", len(got))
+	}
+
+	email.Err = errors.New("connection refused")
+	if err := dispatcher.Notify(NewMessage("patient1", 0, "Reminder", "")); err == nil {
+		t.Errorf("Notify() with failed channel = nil; want error")
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	templates, err := parseTemplates(`{{define "booked.patient.subject"}} Confirmed {{end}}{{define "booked.patient.body"}}
+Dear {{.Name}}, see you on {{.Date}}.
+{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, body, err := templates.Render("booked.patient", struct{ Name, Date string }{"Amy", "06-Jun-2022"})
+	if err != nil || subject != "Confirmed" || body != "Dear Amy, see you on 06-Jun-2022." {
+		t.Errorf("Render() = %q, %q, %v", subject, body, err)
+	}
+	if templates.Has("cancelled.patient") {
+		t.Errorf("Has(cancelled.patient) = true; want false")
+	}
+	if _, _, err = templates.Render("cancelled.patient", nil); err == nil {
+		t.Errorf("Render(cancelled.patient) = nil; want error")
+	}
+}
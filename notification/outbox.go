@@ -0,0 +1,58 @@
+package notification
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileNotifier appends messages as JSON lines into a local file,
+// used in place of the SMS gateway or SMTP server during development.
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// MemoryNotifier keeps messages in memory, used in tests.
+// Err is returned by Notify when set to simulate failed deliveries.
+type MemoryNotifier struct {
+	Err      error
+	mu       sync.Mutex
+	messages []*Message
+}
+
+// NewFileNotifier will return a newly created file notifier writing into path.
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{Path: path}
+}
+
+// Notify appends the message into the file.
+func (n *FileNotifier) Notify(msg *Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	file, err := os.OpenFile(n.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(msg)
+}
+
+// Notify keeps a copy of the message unless Err is set.
+func (n *MemoryNotifier) Notify(msg *Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.Err != nil {
+		return n.Err
+	}
+	copied := *msg
+	n.messages = append(n.messages, &copied)
+	return nil
+}
+
+// GetMessages returns all messages delivered.
+func (n *MemoryNotifier) GetMessages() []*Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*Message(nil), n.messages...)
+}
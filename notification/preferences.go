@@ -0,0 +1,116 @@
+package notification
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+
+	"github.com/shiweii/integrity"
+	"github.com/shiweii/logger"
+)
+
+// Message categories users are able to mute.
+const (
+	CategoryBooked      = "booked"
+	CategoryRescheduled = "rescheduled"
+	CategoryCancelled   = "cancelled"
+	CategoryReminder    = "reminder"
+)
+
+// Preference struct stores the channels and message categories a user opted out of,
+// all messages are delivered through all channels by default.
+type Preference struct {
+	Username        string   `json:"username"`
+	MutedChannels   []string `json:"mutedChannels,omitempty"`
+	MutedCategories []string `json:"mutedCategories,omitempty"`
+}
+
+// Preferences holds the notification preferences of all users and writes every change into JSON file,
+// changes are kept in memory only when path is empty.
+type Preferences struct {
+	mu   sync.Mutex
+	path string
+	list []*Preference
+}
+
+// NewPreferences will return newly created notification preferences persisted into path.
+func NewPreferences(path string) *Preferences {
+	return &Preferences{path: path}
+}
+
+// LoadPreferences will open, read and unmarshal notification preferences from JSON file.
+func LoadPreferences(path string) *Preferences {
+	preferences := NewPreferences(path)
+	JSONData, err := ioutil.ReadFile(preferences.path)
+	if err != nil {
+		logger.Warning.Println(err)
+		return preferences
+	}
+	if err = json.Unmarshal(JSONData, &preferences.list); err != nil {
+		logger.Error.Println(err)
+	}
+	return preferences
+}
+
+// savePreferenceData will marshal and write all notification preferences into JSON file.
+func (p *Preferences) savePreferenceData() {
+	if p.path == "" {
+		return
+	}
+	JSONData, _ := json.MarshalIndent(p.list, "", " ")
+	if err := integrity.WriteFile(p.path, JSONData, 0644); err != nil {
+		logger.Error.Println(err)
+	}
+}
+
+// Get returns a copy of the notification preference of a user.
+func (p *Preferences) Get(username string) Preference {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, v := range p.list {
+		if v.Username == username {
+			return *v
+		}
+	}
+	return Preference{Username: username}
+}
+
+// Set replaces the notification preference of a user.
+func (p *Preferences) Set(preference Preference) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k, v := range p.list {
+		if v.Username == preference.Username {
+			p.list[k] = &preference
+			p.savePreferenceData()
+			return
+		}
+	}
+	p.list = append(p.list, &preference)
+	p.savePreferenceData()
+}
+
+// Allows checks if a user accepts messages of a category through a channel,
+// all messages are allowed when preferences is nil.
+func (p *Preferences) Allows(username, channel, category string) bool {
+	if p == nil {
+		return true
+	}
+	preference := p.Get(username)
+	return !preference.IsMuted(channel, category)
+}
+
+// IsMuted checks if either the channel or the message category is muted.
+func (preference Preference) IsMuted(channel, category string) bool {
+	return contains(preference.MutedChannels, channel) || contains(preference.MutedCategories, category)
+}
+
+// contains checks if value is in list.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
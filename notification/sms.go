@@ -0,0 +1,73 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SMSNotifier sends messages as text messages through an SMS gateway over HTTP.
+// The message is posted as JSON ({"from", "to", "message"}) to URL with APIKey as bearer token,
+// any response other than 2xx is treated as failed delivery.
+type SMSNotifier struct {
+	URL    string
+	APIKey string
+	Sender string
+	Client *http.Client
+}
+
+// smsRequest struct stores the JSON body posted to the SMS gateway.
+type smsRequest struct {
+	From    string `json:"from,omitempty"`
+	To      string `json:"to"`
+	Message string `json:"message"`
+}
+
+// NewSMSNotifier will return a newly created SMS notifier with a 10 seconds timeout.
+func NewSMSNotifier(url, apiKey, sender string) *SMSNotifier {
+	return &SMSNotifier{
+		URL:    url,
+		APIKey: apiKey,
+		Sender: sender,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify sends the message to the mobile number of the user.
+func (n *SMSNotifier) Notify(msg *Message) error {
+	if msg.MobileNumber == 0 {
+		return ErrNoRecipient
+	}
+	body, err := json.Marshal(smsRequest{
+		From:    n.Sender,
+		To:      strconv.Itoa(msg.MobileNumber),
+		Message: msg.Subject + ": " + msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+n.APIKey)
+	}
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("notification: SMS gateway responded with %v", res.Status)
+	}
+	return nil
+}
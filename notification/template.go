@@ -0,0 +1,54 @@
+package notification
+
+import (
+	"io/ioutil"
+	"strings"
+	"text/template"
+)
+
+// Templates renders messages from text templates, each message is defined
+// as two templates named "<name>.subject" and "<name>.body".
+type Templates struct {
+	tpl *template.Template
+}
+
+// ParseTemplates will open and parse message templates from file.
+func ParseTemplates(path string) (*Templates, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTemplates(string(text))
+}
+
+// parseTemplates parses message templates from text.
+func parseTemplates(text string) (*Templates, error) {
+	tpl, err := template.New("messages").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Templates{tpl: tpl}, nil
+}
+
+// Render executes the subject and body templates of a message with data,
+// leading and trailing spaces are removed.
+func (t *Templates) Render(name string, data interface{}) (subject, body string, err error) {
+	var b strings.Builder
+	if err = t.tpl.ExecuteTemplate(&b, name+".subject", data); err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(b.String())
+	b.Reset()
+	if err = t.tpl.ExecuteTemplate(&b, name+".body", data); err != nil {
+		return "", "", err
+	}
+	return subject, strings.TrimSpace(b.String()), nil
+}
+
+// Has checks if templates of a message are defined, false if templates is nil.
+func (t *Templates) Has(name string) bool {
+	if t == nil {
+		return false
+	}
+	return t.tpl.Lookup(name+".subject") != nil && t.tpl.Lookup(name+".body") != nil
+}
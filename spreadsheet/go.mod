@@ -0,0 +1,3 @@
+module github.com/shiweii/spreadsheet
+
+go 1.18
@@ -0,0 +1,104 @@
+// Package spreadsheet implements reading of CSV files and writing of tables as CSV or Excel (XLSX) files
+// for import and export of clinic data.
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrNoHeader is returned by ReadCSV when the file has no header row.
+var ErrNoHeader = errors.New("spreadsheet: header row is missing")
+
+// Record stores a row of a CSV file by column name, column names are normalized by NormalizeColumn.
+// Line is the line number of the row in the file, the header is line 1.
+type Record struct {
+	Line   int
+	fields map[string]string
+}
+
+// Get returns the trimmed value of a column, empty if the column does not exist.
+func (r Record) Get(column string) string {
+	return strings.TrimSpace(r.fields[NormalizeColumn(column)])
+}
+
+// IsEmpty checks if all values of the row are empty.
+func (r Record) IsEmpty() bool {
+	for _, v := range r.fields {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeColumn returns column name in lower case without spaces, dashes and underscores,
+// "Mobile Number", "mobile_number" and "mobileNumber" are the same column.
+func NormalizeColumn(column string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.TrimSpace(column)))
+}
+
+// ReadCSV reads all rows of a CSV file with a header row, empty rows are skipped.
+// The byte order mark added by Excel is removed and rows may have fewer columns than the header.
+func ReadCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrNoHeader
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		record := Record{Line: line, fields: make(map[string]string)}
+		for k, v := range header {
+			if k < len(row) {
+				record.fields[NormalizeColumn(v)] = row[k]
+			}
+		}
+		if !record.IsEmpty() {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// WriteCSV writes rows as CSV file, the first row is the header.
+// Values starting with =, +, - or @ are prefixed with ' so that spreadsheet applications do not run them as formulas.
+func WriteCSV(w io.Writer, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	for _, row := range rows {
+		safe := make([]string, len(row))
+		for k, v := range row {
+			safe[k] = escapeFormula(v)
+		}
+		if err := writer.Write(safe); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// escapeFormula prefixes values which would be interpreted as formula.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
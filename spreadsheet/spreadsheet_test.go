@@ -0,0 +1,79 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	input := "\ufeffUsername,First Name,mobile_number\npatient1,Amy,91234567\n,,\npatient2,\"Pond, Rory\"\n"
+	records, err := ReadCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("ReadCSV() = %d records; want 2", len(records))
+	}
+	if got := records[0].Get("username"); got != "patient1" {
+		t.Errorf("Get(username) = %q; want patient1", got)
+	}
+	if got := records[0].Get("mobileNumber"); got != "91234567" {
+		t.Errorf("Get(mobileNumber) = %q; want 91234567", got)
+	}
+	if got := records[1].Get("firstName"); got != "Pond, Rory" || records[1].Line != 4 || records[1].Get("mobileNumber") != "" {
+		t.Errorf("records[1] = line %d, %q; want line 4, \"Pond, Rory\"", records[1].Line, got)
+	}
+	if _, err = ReadCSV(strings.NewReader("")); err != ErrNoHeader {
+		t.Errorf("ReadCSV(empty) = %v; want %v", err, ErrNoHeader)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteCSV(&b, [][]string{{"Name", "Note"}, {"Amy", "=HYPERLINK(\"x\")"}, {"-1", "@SUM(A1)"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "Name,Note\nAmy,\"'=HYPERLINK(\"\"x\"\")\"\n'-1,'@SUM(A1)\n"
+	if got := b.String(); got != want {
+		t.Errorf("WriteCSV() = %q; want %q", got, want)
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteXLSX(&b, "Appointments: 2022/06", [][]string{{"Name", "Mobile"}, {"Amy & Rory", "91234567"}}); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	for _, f := range archive.File {
+		r, _ := f.Open()
+		content, _ := ioutil.ReadAll(r)
+		r.Close()
+		parts[f.Name] = string(content)
+	}
+	if len(parts) != 6 {
+		t.Errorf("WriteXLSX() wrote %d parts; want 6", len(parts))
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Appointments 202206"`) {
+		t.Errorf("WriteXLSX() sheet name not sanitized: %v", parts["xl/workbook.xml"])
+	}
+	if !strings.Contains(parts["xl/worksheets/sheet1.xml"], `<c r="A2" t="inlineStr"><is><t xml:space="preserve">Amy &amp; Rory</t></is></c>`) {
+		t.Errorf("WriteXLSX() cell A2 not found: %v", parts["xl/worksheets/sheet1.xml"])
+	}
+}
+
+func TestGetColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"}
+	for index, want := range tests {
+		if got := GetColumnName(index); got != want {
+			t.Errorf("GetColumnName(%d) = %v; want %v", index, got, want)
+		}
+	}
+}
@@ -8,9 +8,10 @@ import (
 	"io/ioutil"
 	"reflect"
 
+	"github.com/shiweii/config"
 	"github.com/shiweii/cryptography"
 	dll "github.com/shiweii/doublylinkedlist"
-	util "github.com/shiweii/utility"
+	"github.com/shiweii/integrity"
 )
 
 // User struct stores user data.
@@ -21,7 +22,10 @@ type User struct {
 	FirstName    string `json:"firstName"`
 	LastName     string `json:"lastName"`
 	MobileNumber int    `json:"mobileNumber,omitempty"`
+	Email        string `json:"email,omitempty"`
 	IsDeleted    bool   `json:"isDeleted,omitempty"`
+	// Locale is the locale of pages chosen by the user, pages are displayed in the language of the browser when empty
+	Locale string `json:"locale,omitempty"`
 }
 
 // DoublyLinkedList extends from doublylinkedlist package for user related processing.
@@ -44,16 +48,17 @@ func New(username, password, role, firstName, lastName string, mobileNumber int)
 
 // GetEncryptedUserData will perform decryption and encryption on user JSON file.
 func GetEncryptedUserData() []*User {
-	cryptography.DecryptFile(util.GetEnvVar("KEY"), util.GetEnvVar("USER_DATA_ENCRYPT"), util.GetEnvVar("USER_DATA"))
+	defer integrity.Expect(config.Get().Data.EncryptedUsers)()
+	cryptography.DecryptFile(config.Get().Key, config.Get().Data.EncryptedUsers, config.Get().Data.Users)
 	users := getUserData()
-	cryptography.EncryptFile(util.GetEnvVar("KEY"), util.GetEnvVar("USER_DATA"), util.GetEnvVar("USER_DATA_ENCRYPT"))
+	cryptography.EncryptFile(config.Get().Key, config.Get().Data.Users, config.Get().Data.EncryptedUsers)
 	return users
 }
 
 // getUserData open, read and unmarshal user data from JSON file.
 func getUserData() []*User {
 	var users []*User
-	JSONData, _ := ioutil.ReadFile(util.GetEnvVar("USER_DATA"))
+	JSONData, _ := ioutil.ReadFile(config.Get().Data.Users)
 	err := json.Unmarshal(JSONData, &users)
 	if err != nil {
 		fmt.Println(err)
@@ -63,21 +68,36 @@ func getUserData() []*User {
 
 // AddUserDate will decrypt, open, marshal, append and encrypt new user data into JSON file.
 func AddUserDate(u *User) {
-	cryptography.DecryptFile(util.GetEnvVar("KEY"), util.GetEnvVar("USER_DATA_ENCRYPT"), util.GetEnvVar("USER_DATA"))
+	defer integrity.Expect(config.Get().Data.EncryptedUsers)()
+	cryptography.DecryptFile(config.Get().Key, config.Get().Data.EncryptedUsers, config.Get().Data.Users)
 	var users []*User
 	users = getUserData()
 	users = append(users, u)
 	JSONData, _ := json.MarshalIndent(users, "", " ")
-	err := ioutil.WriteFile(util.GetEnvVar("USER_DATA"), JSONData, 0644)
+	err := ioutil.WriteFile(config.Get().Data.Users, JSONData, 0644)
 	if err != nil {
 		fmt.Println(err)
 	}
-	cryptography.EncryptFile(util.GetEnvVar("KEY"), util.GetEnvVar("USER_DATA"), util.GetEnvVar("USER_DATA_ENCRYPT"))
+	cryptography.EncryptFile(config.Get().Key, config.Get().Data.Users, config.Get().Data.EncryptedUsers)
+}
+
+// AddUserDataList will decrypt, open, marshal, append and encrypt a list of new users into JSON file in a single write.
+func AddUserDataList(list []*User) {
+	defer integrity.Expect(config.Get().Data.EncryptedUsers)()
+	cryptography.DecryptFile(config.Get().Key, config.Get().Data.EncryptedUsers, config.Get().Data.Users)
+	users := append(getUserData(), list...)
+	JSONData, _ := json.MarshalIndent(users, "", " ")
+	err := ioutil.WriteFile(config.Get().Data.Users, JSONData, 0644)
+	if err != nil {
+		fmt.Println(err)
+	}
+	cryptography.EncryptFile(config.Get().Key, config.Get().Data.Users, config.Get().Data.EncryptedUsers)
 }
 
 // UpdateUserData will decrypt, open, marshal, update and encrypt matching user data into JSON file.
 func UpdateUserData(oldUser *User, newUser *User) {
-	cryptography.DecryptFile(util.GetEnvVar("KEY"), util.GetEnvVar("USER_DATA_ENCRYPT"), util.GetEnvVar("USER_DATA"))
+	defer integrity.Expect(config.Get().Data.EncryptedUsers)()
+	cryptography.DecryptFile(config.Get().Key, config.Get().Data.EncryptedUsers, config.Get().Data.Users)
 	var users = getUserData()
 	for k, v := range users {
 		if reflect.DeepEqual(v, oldUser) {
@@ -85,16 +105,17 @@ func UpdateUserData(oldUser *User, newUser *User) {
 		}
 	}
 	JSONData, _ := json.MarshalIndent(users, "", " ")
-	err := ioutil.WriteFile(util.GetEnvVar("USER_DATA"), JSONData, 0644)
+	err := ioutil.WriteFile(config.Get().Data.Users, JSONData, 0644)
 	if err != nil {
 		fmt.Println(err)
 	}
-	cryptography.EncryptFile(util.GetEnvVar("KEY"), util.GetEnvVar("USER_DATA"), util.GetEnvVar("USER_DATA_ENCRYPT"))
+	cryptography.EncryptFile(config.Get().Key, config.Get().Data.Users, config.Get().Data.EncryptedUsers)
 }
 
 // DeleteUserData will decrypt, open, marshal, delete  and encrypt user data using username from JSON file.
 func DeleteUserData(delUser *User) {
-	cryptography.DecryptFile(util.GetEnvVar("KEY"), util.GetEnvVar("USER_DATA_ENCRYPT"), util.GetEnvVar("USER_DATA"))
+	defer integrity.Expect(config.Get().Data.EncryptedUsers)()
+	cryptography.DecryptFile(config.Get().Key, config.Get().Data.EncryptedUsers, config.Get().Data.Users)
 	var users = getUserData()
 	for k, v := range users {
 		if v.Username == delUser.Username {
@@ -102,11 +123,11 @@ func DeleteUserData(delUser *User) {
 		}
 	}
 	JSONData, _ := json.MarshalIndent(users, "", " ")
-	err := ioutil.WriteFile(util.GetEnvVar("USER_DATA"), JSONData, 0644)
+	err := ioutil.WriteFile(config.Get().Data.Users, JSONData, 0644)
 	if err != nil {
 		fmt.Println(err)
 	}
-	cryptography.EncryptFile(util.GetEnvVar("KEY"), util.GetEnvVar("USER_DATA"), util.GetEnvVar("USER_DATA_ENCRYPT"))
+	cryptography.EncryptFile(config.Get().Key, config.Get().Data.Users, config.Get().Data.EncryptedUsers)
 }
 
 // GetDentistList returns all elements in the linked list.
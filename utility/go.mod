@@ -3,12 +3,20 @@ module github.com/shiweii/utility
 go 1.18
 
 require (
-	github.com/joho/godotenv v1.4.0
+	github.com/shiweii/config v0.0.0-00010101000000-000000000000
 	github.com/shiweii/cryptography v0.0.0-00010101000000-000000000000
 	github.com/shiweii/logger v0.0.0-00010101000000-000000000000
 	golang.org/x/text v0.3.7
 )
 
+require (
+	github.com/BurntSushi/toml v1.2.1 // indirect
+	github.com/joho/godotenv v1.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
 replace github.com/shiweii/logger => ../logger
 
 replace github.com/shiweii/cryptography => ../cryptography
+
+replace github.com/shiweii/config => ../config
@@ -3,14 +3,13 @@ package utility
 
 import (
 	"fmt"
-	"io/ioutil"
 	"math/rand"
 	"os"
 	"runtime"
 	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/shiweii/config"
 	"github.com/shiweii/cryptography"
 	"github.com/shiweii/logger"
 	"golang.org/x/text/cases"
@@ -27,52 +26,14 @@ func CurrFuncName() string {
 	return frame.Function
 }
 
-// GetEnvVar read all vars declared in .env.
-func GetEnvVar(v string) string {
-	err := godotenv.Load()
-	if err != nil {
-		logger.Fatal.Fatal("Error loading .env file")
-	}
-	return os.Getenv(v)
-}
-
-// VerifyCheckSum verify that file was not tempered with by checking
-// against the checksum of the file.
-func VerifyCheckSum() {
-	for {
-		logChecksum, err := ioutil.ReadFile(GetEnvVar("CHECKSUM_FILE"))
-		if err != nil {
-			logger.Error.Println(err)
-		}
-		// convert content to a 'string'
-		str := string(logChecksum)
-		// Compute our current log's SHA256 hash
-		hash, err := cryptography.ComputeSHA512(GetEnvVar("CHECKSUM_FILE_TO_VERIFY"))
-		if err != nil {
-			logger.Error.Println(err)
-		} else {
-			// Compare our calculated hash with our stored hash
-			if str == hash {
-				// Ok the checksums match.
-				logger.Info.Println("File integrity OK.")
-			} else {
-				// The file integrity has been compromised...
-				logger.Warning.Println("File Tampering detected.")
-			}
-		}
-		min, _ := time.ParseDuration(GetEnvVar("CHECKSUM_TIMER"))
-		time.Sleep(min)
-	}
-}
-
 // CheckEncryption check if file is encrypted.
 // Will perform encryption if file is not encrypted.
 func CheckEncryption() {
-	_, err := os.Stat(GetEnvVar("USER_DATA_ENCRYPT"))
+	_, err := os.Stat(config.Get().Data.EncryptedUsers)
 	if err != nil {
 		if os.IsNotExist(err) {
 			logger.Info.Println("File was not encrypted, proceed to encrypt")
-			cryptography.EncryptFile(GetEnvVar("KEY"), GetEnvVar("USER_DATA"), GetEnvVar("USER_DATA_ENCRYPT"))
+			cryptography.EncryptFile(config.Get().Key, config.Get().Data.Users, config.Get().Data.EncryptedUsers)
 		}
 	}
 }
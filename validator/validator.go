@@ -11,6 +11,7 @@ const (
 	name              = "^[a-zA-Z_. ]*$"
 	username          = "^[a-zA-Z0-9][a-zA-Z0-9\\_\\-\\.]*[a-zA-Z0-9]$"
 	mobileNum         = "^[8-9][0-9]{7}$"
+	email             = "^[^@\\s]+@[^@\\s]+\\.[^@\\s]+$"
 	usernameMinLength = 5
 	usernameMaxLength = 20
 	passwordMinLength = 7
@@ -82,3 +83,11 @@ func IsMobileNumber(input string) bool {
 	regex := regexp.MustCompile(mobileNum)
 	return regex.MatchString(input)
 }
+
+// IsEmail validate email address against email regex.
+// Email must have a single @ followed by a domain with at least one dot.
+// Email cannot contain spaces.
+func IsEmail(input string) bool {
+	regex := regexp.MustCompile(email)
+	return regex.MatchString(input)
+}
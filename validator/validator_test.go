@@ -187,3 +187,23 @@ func TestIsMobileNumber(t *testing.T) {
 		t.Errorf("IsMobileNumber(\"<scrip>alert(1);<script>\") = %t; want %t got %t", got, res, got)
 	}
 }
+
+func TestIsEmail(t *testing.T) {
+	got := IsEmail("amy.pond@example.com")
+	res := true
+	if got != res {
+		t.Errorf("IsEmail(amy.pond@example.com) = %t; want %t got %t", got, res, got)
+	}
+
+	got = IsEmail("amy pond@example.com")
+	res = false
+	if got != res {
+		t.Errorf("IsEmail(amy pond@example.com) = %t; want %t got %t", got, res, got)
+	}
+
+	got = IsEmail("amy.pond@example")
+	res = false
+	if got != res {
+		t.Errorf("IsEmail(amy.pond@example) = %t; want %t got %t", got, res, got)
+	}
+}